@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dryrun"
+	"os"
+)
+
+// Compares requests recorded by two agent versions in dry run mode
+// usage: dryrun-diff <old codefresh.ndjson> <new codefresh.ndjson>
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Println("usage: dryrun-diff <old codefresh.ndjson> <new codefresh.ndjson>")
+		os.Exit(2)
+	}
+
+	oldRecords, err := dryrun.ReadRecords(os.Args[1])
+	if err != nil {
+		fmt.Printf("Failed to read \"%s\", reason %v\n", os.Args[1], err)
+		os.Exit(2)
+	}
+
+	newRecords, err := dryrun.ReadRecords(os.Args[2])
+	if err != nil {
+		fmt.Printf("Failed to read \"%s\", reason %v\n", os.Args[2], err)
+		os.Exit(2)
+	}
+
+	differences := dryrun.Diff(oldRecords, newRecords)
+	for _, difference := range differences {
+		switch {
+		case difference.Added:
+			fmt.Printf("+ %s\n", difference.Key)
+		case difference.Removed:
+			fmt.Printf("- %s\n", difference.Key)
+		default:
+			fmt.Printf("~ %s\n", difference.Key)
+			for _, change := range difference.Changes {
+				fmt.Printf("    %s\n", change)
+			}
+		}
+	}
+
+	if len(differences) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,139 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Kind says what caller can do with failed request
+type Kind string
+
+const (
+	NotFound     Kind = "NotFound"
+	Unauthorized Kind = "Unauthorized"
+	Forbidden    Kind = "Forbidden"
+	RateLimited  Kind = "RateLimited"
+	// Transient errors are network errors and server side errors, request can succeed later
+	Transient Kind = "Transient"
+	// Permanent errors won't disappear without change of request or configuration
+	Permanent Kind = "Permanent"
+)
+
+// Backends, that errors are produced by
+const (
+	ArgoBackend      = "argocd"
+	CodefreshBackend = "codefresh"
+)
+
+// Error is failed request to argocd or codefresh
+type Error struct {
+	Kind       Kind
+	Backend    string
+	Method     string
+	Path       string
+	StatusCode int
+	// Code is error code of codefresh
+	Code    string
+	Message string
+	// RetryAfter is set from Retry-After header of rate limited response
+	RetryAfter time.Duration
+	// Err is cause of network error
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s request %s %s failed, reason %v", e.Backend, e.Method, e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s request %s %s failed with status %v, message: %v", e.Backend, e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOfStatus maps http status to kind of error
+func KindOfStatus(status int) Kind {
+	switch {
+	case status == http.StatusNotFound:
+		return NotFound
+	case status == http.StatusUnauthorized:
+		return Unauthorized
+	case status == http.StatusForbidden:
+		return Forbidden
+	case status == http.StatusTooManyRequests:
+		return RateLimited
+	case status == http.StatusRequestTimeout || status >= 500:
+		return Transient
+	}
+	return Permanent
+}
+
+// FromResponse creates error of non 2xx response
+func FromResponse(backend string, resp *http.Response, message string) *Error {
+	err := &Error{
+		Kind:       KindOfStatus(resp.StatusCode),
+		Backend:    backend,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+	}
+	if resp.Request != nil {
+		err.Method = resp.Request.Method
+		err.Path = resp.Request.URL.RequestURI()
+	}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+// FromNetwork creates error of request, that didn't get response
+func FromNetwork(backend string, req *http.Request, cause error) *Error {
+	return &Error{
+		Kind:    Transient,
+		Backend: backend,
+		Method:  req.Method,
+		Path:    req.URL.RequestURI(),
+		Err:     cause,
+	}
+}
+
+// KindOf returns kind of error, errors, that aren't produced by api clients, like decoding errors, are permanent
+func KindOf(err error) Kind {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	return Permanent
+}
+
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// IsRetryable says if same request can succeed later
+func IsRetryable(err error) bool {
+	kind := KindOf(err)
+	return err != nil && (kind == Transient || kind == RateLimited)
+}
+
+// BackendOf returns backend, that rejected request, it's empty for other errors
+func BackendOf(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Backend
+	}
+	return ""
+}
+
+// RetryAfterOf returns delay requested by backend, it's zero when backend didn't request it
+func RetryAfterOf(err error) time.Duration {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestKindOfStatus(t *testing.T) {
+	cases := map[int]Kind{
+		http.StatusNotFound:            NotFound,
+		http.StatusUnauthorized:        Unauthorized,
+		http.StatusForbidden:           Forbidden,
+		http.StatusTooManyRequests:     RateLimited,
+		http.StatusRequestTimeout:      Transient,
+		http.StatusBadGateway:          Transient,
+		http.StatusInternalServerError: Transient,
+		http.StatusBadRequest:          Permanent,
+		http.StatusConflict:            Permanent,
+	}
+	for status, expected := range cases {
+		if kind := KindOfStatus(status); kind != expected {
+			t.Errorf("'KindOfStatus' failed for status %v, expected '%v', got '%v'", status, expected, kind)
+		}
+	}
+}
+
+func TestFromResponse(t *testing.T) {
+	requestUrl, _ := url.Parse("http://argocd/api/v1/applications?project=default")
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"7"}},
+		Request:    &http.Request{Method: http.MethodGet, URL: requestUrl},
+	}
+
+	err := FromResponse(ArgoBackend, resp, "slow down")
+
+	if err.Kind != RateLimited {
+		t.Errorf("'FromResponse' failed, expected '%v', got '%v'", RateLimited, err.Kind)
+	}
+	if err.Path != "/api/v1/applications?project=default" {
+		t.Errorf("'FromResponse' failed, expected '%v', got '%v'", "/api/v1/applications?project=default", err.Path)
+	}
+	if err.RetryAfter != 7*time.Second {
+		t.Errorf("'FromResponse' failed, expected '%v', got '%v'", 7*time.Second, err.RetryAfter)
+	}
+}
+
+func TestKindOfWrappedError(t *testing.T) {
+	requestUrl, _ := url.Parse("http://codefresh/api/environments")
+	cause := FromNetwork(CodefreshBackend, &http.Request{Method: http.MethodPost, URL: requestUrl}, errors.New("connection refused"))
+	err := fmt.Errorf("failed to send environment, reason %w", cause)
+
+	if !Is(err, Transient) {
+		t.Errorf("'KindOf' failed, expected '%v', got '%v'", Transient, KindOf(err))
+	}
+	if BackendOf(err) != CodefreshBackend {
+		t.Errorf("'BackendOf' failed, expected '%v', got '%v'", CodefreshBackend, BackendOf(err))
+	}
+	if KindOf(errors.New("decode failed")) != Permanent {
+		t.Errorf("'KindOf' failed, expected '%v', got '%v'", Permanent, KindOf(errors.New("decode failed")))
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{&Error{Kind: Transient}, true},
+		{&Error{Kind: RateLimited}, true},
+		{&Error{Kind: Unauthorized}, false},
+		{&Error{Kind: NotFound}, false},
+		{errors.New("unknown"), false},
+	}
+	for _, c := range cases {
+		if IsRetryable(c.err) != c.expected {
+			t.Errorf("'IsRetryable' failed for '%v', expected '%v', got '%v'", c.err, c.expected, !c.expected)
+		}
+	}
+}
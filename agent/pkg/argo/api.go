@@ -2,25 +2,36 @@ package argo
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/httpclient"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/replay"
 	store2 "github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"io"
 	"net/http"
+	"net/url"
+	"sync"
 )
 
 type ArgoApi interface {
 	GetApplicationsWithCredentialsFromStorage() ([]ApplicationItem, error)
-	GetResourceTree(applicationName string) (*ResourceTree, error)
-	GetResourceTreeAll(applicationName string) (interface{}, error)
-	GetManagedResources(applicationName string) (*ManagedResource, error)
+	GetResourceTreeAll(ctx context.Context, applicationName string, applicationNamespace string) (interface{}, error)
+	GetManagedResources(ctx context.Context, applicationName string, applicationNamespace string) (*ManagedResource, error)
+	GetProject(ctx context.Context, name string) (*ProjectItem, error)
 	GetVersion() (string, error)
+	SyncApplication(applicationName string, applicationNamespace string) error
+	RefreshApplication(applicationName string, applicationNamespace string, hard bool) error
+	RollbackApplication(applicationName string, applicationNamespace string, historyId int64) error
+	TerminateOperation(applicationName string, applicationNamespace string) error
 }
 
 type Api struct {
-	Token string
-	Host  string
+	Host string
 }
 
 var api *Api
@@ -32,160 +43,182 @@ func GetInstance() *Api {
 
 	argoConfig := store2.GetStore().Argo
 	api = &Api{
-		Token: argoConfig.Token,
-		Host:  argoConfig.Host,
+		Host: argoConfig.Host,
 	}
 	return api
 }
 
-func buildHttpClient() *http.Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	return &http.Client{Transport: tr}
-}
+var (
+	httpClient           *http.Client
+	httpClientOnce       sync.Once
+	streamHttpClient     *http.Client
+	streamHttpClientOnce sync.Once
+)
 
-func GetToken(username string, password string, host string) (string, error) {
+// getHttpClient returns client shared by all requests to argocd, so connections are reused
+func getHttpClient() *http.Client {
+	httpClientOnce.Do(func() {
+		config := httpclient.DefaultConfig()
+		config.InsecureSkipVerify = true
+		httpClient = httpclient.New(config, func(tr http.RoundTripper) http.RoundTripper {
+			return tracing.NewTransport(replay.Transport(tr))
+		})
+	})
+	return httpClient
+}
 
-	client := buildHttpClient()
+// getStreamHttpClient returns client without overall timeout and retries, stream is never finished and reconnected by watcher,
+// its responses aren't recorded for replay
+func getStreamHttpClient() *http.Client {
+	streamHttpClientOnce.Do(func() {
+		config := httpclient.DefaultConfig()
+		config.InsecureSkipVerify = true
+		config.Timeout = 0
+		config.Retries = 0
+		streamHttpClient = httpclient.New(config, func(tr http.RoundTripper) http.RoundTripper {
+			return tracing.NewTransport(tr)
+		})
+	})
+	return streamHttpClient
+}
 
-	message := map[string]interface{}{
-		"username": username,
-		"password": password,
+// doRequest sends request to argocd and decodes json response to target, non 2xx status is returned as error
+func doRequest(ctx context.Context, method string, requestUrl string, token string, body interface{}, target interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		bytesRepresentation, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(bytesRepresentation)
 	}
 
-	bytesRepresentation, err := json.Marshal(message)
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl, payload)
 	if err != nil {
-		return "", errors.New("application error, cant retrieve argo token")
+		return err
 	}
-
-	resp, err := client.Post(host+"/api/v1/session", "application/json", bytes.NewBuffer(bytesRepresentation))
-	if err != nil {
-		return "", err
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
 	}
-
-	if resp.StatusCode == 401 {
-		return "", errors.New("cant retrieve argocd token, permission denied")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
 	}
 
-	var result map[string]interface{}
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-
+	resp, err := getHttpClient().Do(req)
 	if err != nil {
-		return "", err
+		return apierrors.FromNetwork(apierrors.ArgoBackend, req, err)
 	}
-
 	defer resp.Body.Close()
 
-	return result["token"].(string), nil
-}
-
-func (api *Api) CheckToken() error {
-	client := buildHttpClient()
-	req, err := http.NewRequest("GET", api.Host+"/api/v1/account", nil)
-
+	err = checkResponse(resp)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+api.Token)
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return err
+	if target == nil {
+		return nil
 	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
 
-	var result map[string]interface{}
-
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-
-	if err != nil {
-		return err
+// checkResponse returns typed error with message of argocd for non 2xx status
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
 	}
+	var result struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result)
+	return apierrors.FromResponse(apierrors.ArgoBackend, resp, result.Message)
+}
 
-	return nil
+// applicationUrl builds url of application endpoint, application namespace passed as "appNamespace" query param
+// because argocd allows applications with same name in different namespaces
+func applicationUrl(host string, applicationName string, applicationNamespace string, suffix string) string {
+	return applicationUrlWithQuery(host, applicationName, applicationNamespace, suffix, url.Values{})
 }
 
-func (api *Api) GetResourceTree(applicationName string) (*ResourceTree, error) {
-	client := buildHttpClient()
+func applicationUrlWithQuery(host string, applicationName string, applicationNamespace string, suffix string, query url.Values) string {
+	result := host + "/api/v1/applications/" + url.PathEscape(applicationName) + suffix
+	if applicationNamespace != "" {
+		query.Set("appNamespace", applicationNamespace)
+	}
+	if len(query) > 0 {
+		result += "?" + query.Encode()
+	}
+	return result
+}
 
-	req, err := http.NewRequest("GET", api.Host+"/api/v1/applications/"+applicationName+"/resource-tree", nil)
+func GetToken(username string, password string, host string) (string, error) {
+	message := map[string]interface{}{
+		"username": username,
+		"password": password,
+	}
 
+	var result struct {
+		Token string `json:"token"`
+	}
+	err := doRequest(context.Background(), "POST", host+"/api/v1/session", "", message, &result)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("cant retrieve argocd token, reason %w", err)
+	}
+	if result.Token == "" {
+		return "", errors.New("cant retrieve argocd token, session without token")
 	}
 
-	req.Header.Add("Authorization", "Bearer "+api.Token)
-	resp, err := client.Do(req)
+	return result.Token, nil
+}
 
+func (api *Api) CheckToken() error {
+	var result map[string]interface{}
+	err := doRequest(context.Background(), "GET", api.Host+"/api/v1/account", currentToken(), nil, &result)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to check argocd token, reason %w", err)
 	}
+	return nil
+}
 
-	var result *ResourceTree
-
-	defer resp.Body.Close()
+// GetResourceTreeAll returns nodes of resource tree of application as is
+func (api *Api) GetResourceTreeAll(ctx context.Context, applicationName string, applicationNamespace string) (interface{}, error) {
+	ctx, span := tracing.Start(ctx, "argo.GetResourceTreeAll", tracing.KindInternal)
+	defer span.End()
 
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	applicationLogger(applicationName, applicationNamespace).Debug("Retrieve argo resource tree")
 
+	var result map[string]interface{}
+	err := doRequest(ctx, "GET", applicationUrl(api.Host, applicationName, applicationNamespace, "/resource-tree"), currentToken(), nil, &result)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
-	return result, nil
+	return result["nodes"], nil
 }
 
-//  TODO: refactor
-func (api *Api) GetResourceTreeAll(applicationName string) (interface{}, error) {
-	client := buildHttpClient()
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/applications/%s/resource-tree", api.Host, applicationName), nil)
-	if err != nil {
-		return nil, err
+// DecodeResourceTree converts nodes returned by GetResourceTreeAll to typed tree, so tree is requested once per update
+func DecodeResourceTree(nodes interface{}) (*ResourceTree, error) {
+	var tree ResourceTree
+	if nodes == nil {
+		return &tree, nil
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", api.Token))
-	resp, err := client.Do(req)
-
+	payload, err := json.Marshal(nodes)
 	if err != nil {
 		return nil, err
 	}
-
-	var result interface{}
-
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-
+	err = json.Unmarshal(payload, &tree.Nodes)
 	if err != nil {
 		return nil, err
 	}
-
-	return result.(map[string]interface{})["nodes"], nil
+	return &tree, nil
 }
 
 func (api *Api) GetVersion() (string, error) {
-	token := store2.GetStore().Argo.Token
+	token := currentToken()
 	host := store2.GetStore().Argo.Host
 
-	client := buildHttpClient()
-
-	req, err := http.NewRequest("GET", host+"/api/version", nil)
-	req.Header.Add("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return "", err
-	}
-
 	var result ServerInfo
-
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-
+	err := doRequest(context.Background(), "GET", host+"/api/version", token, nil, &result)
 	if err != nil {
 		return "", err
 	}
@@ -193,27 +226,19 @@ func (api *Api) GetVersion() (string, error) {
 	return result.Version, nil
 }
 
-func (api *Api) GetManagedResources(applicationName string) (*ManagedResource, error) {
-	token := store2.GetStore().Argo.Token
-	host := store2.GetStore().Argo.Host
-
-	client := buildHttpClient()
+func (api *Api) GetManagedResources(ctx context.Context, applicationName string, applicationNamespace string) (*ManagedResource, error) {
+	ctx, span := tracing.Start(ctx, "argo.GetManagedResources", tracing.KindInternal)
+	defer span.End()
 
-	req, err := http.NewRequest("GET", host+"/api/v1/applications/"+applicationName+"/managed-resources", nil)
-	req.Header.Add("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
+	token := currentToken()
+	host := store2.GetStore().Argo.Host
 
-	if err != nil {
-		return nil, err
-	}
+	applicationLogger(applicationName, applicationNamespace).Debug("Retrieve argo managed resources")
 
 	var result ManagedResource
-
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-
+	err := doRequest(ctx, "GET", applicationUrl(host, applicationName, applicationNamespace, "/managed-resources"), token, nil, &result)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
@@ -221,93 +246,129 @@ func (api *Api) GetManagedResources(applicationName string) (*ManagedResource, e
 }
 
 func GetProjects(token string, host string) ([]ProjectItem, error) {
-	client := buildHttpClient()
-
-	req, err := http.NewRequest("GET", host+"/api/v1/projects", nil)
-	req.Header.Add("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
-
+	var result Project
+	err := doRequest(context.Background(), "GET", host+"/api/v1/projects", token, nil, &result)
 	if err != nil {
 		return nil, err
 	}
 
-	var result Project
+	return result.Items, nil
+}
 
-	defer resp.Body.Close()
+// GetProject returns project from cache of informer, without informer project requested from argocd is reused for a minute
+func (api *Api) GetProject(ctx context.Context, name string) (*ProjectItem, error) {
+	ctx, span := tracing.Start(ctx, "argo.GetProject", tracing.KindInternal)
+	defer span.End()
+
+	var result ProjectItem
+	if cached, ok := cachedProject(name); ok {
+		payload, err := json.Marshal(cached)
+		if err == nil && json.Unmarshal(payload, &result) == nil {
+			return &result, nil
+		}
+	}
+	if requested, ok := recentlyRequestedProject(name); ok {
+		return requested, nil
+	}
 
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	token := currentToken()
+	host := store2.GetStore().Argo.Host
 
+	err := doRequest(ctx, "GET", host+"/api/v1/projects/"+url.PathEscape(name), token, nil, &result)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	rememberRequestedProject(name, result)
 
-	return result.Items, nil
+	return &result, nil
 }
 
 func GetProjectsWithCredentialsFromStorage() ([]ProjectItem, error) {
-	token := store2.GetStore().Argo.Token
+	token := currentToken()
 	host := store2.GetStore().Argo.Host
 
 	return GetProjects(token, host)
 }
 
-func GetApplication(application string) (map[string]interface{}, error) {
-	token := store2.GetStore().Argo.Token
+// GetApplication returns application from cache of event source, argocd is requested only when it isn't cached
+func GetApplication(application string, applicationNamespace string) (map[string]interface{}, error) {
+	if cached, ok := cachedApplication(application, applicationNamespace); ok {
+		return cached, nil
+	}
+
+	token := currentToken()
 	host := store2.GetStore().Argo.Host
 
-	client := buildHttpClient()
+	applicationLogger(application, applicationNamespace).Debug("Retrieve argo application")
 
 	var result map[string]interface{}
-
-	req, err := http.NewRequest("GET", host+"/api/v1/applications/"+application, nil)
-	req.Header.Add("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
-
-	if resp.StatusCode != 200 {
-		// TODO: add error handling and move it to common place
-		return nil, errors.New(fmt.Sprintf("Failed to retrieve application, reason %v", resp.Status))
-	}
-
+	err := doRequest(context.Background(), "GET", applicationUrl(host, application, applicationNamespace, ""), token, nil, &result)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to retrieve application, reason %w", err)
 	}
 
-	defer resp.Body.Close()
+	return result, nil
+}
 
-	err = json.NewDecoder(resp.Body).Decode(&result)
+func (api *Api) GetApplicationsWithCredentialsFromStorage() ([]ApplicationItem, error) {
+	return GetApplications(currentToken(), api.Host)
+}
 
+func GetApplications(token string, host string) ([]ApplicationItem, error) {
+	var result Application
+	err := doRequest(context.Background(), "GET", host+"/api/v1/applications", token, nil, &result)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return result.Items, nil
 }
 
-func (api *Api) GetApplicationsWithCredentialsFromStorage() ([]ApplicationItem, error) {
-	return GetApplications(api.Token, api.Host)
+func applicationLogger(applicationName string, applicationNamespace string) *logger.Logger {
+	return logger.GetLogger().WithFields(logger.Fields{
+		logger.AppField:       applicationName,
+		logger.NamespaceField: applicationNamespace,
+	})
 }
 
-func GetApplications(token string, host string) ([]ApplicationItem, error) {
-
-	client := buildHttpClient()
+// doApplicationAction executes request that changes application state, response body is ignored
+func (api *Api) doApplicationAction(method string, requestUrl string, body interface{}) error {
+	logger.GetLogger().Debugf("Send argo request %s %s", method, requestUrl)
 
-	req, err := http.NewRequest("GET", host+"/api/v1/applications", nil)
-	req.Header.Add("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
+	return doRequest(context.Background(), method, requestUrl, currentToken(), body, nil)
+}
 
-	if err != nil {
-		return nil, err
+func (api *Api) SyncApplication(applicationName string, applicationNamespace string) error {
+	body := map[string]interface{}{
+		"name": applicationName,
 	}
+	if applicationNamespace != "" {
+		body["appNamespace"] = applicationNamespace
+	}
+	return api.doApplicationAction("POST", applicationUrl(api.Host, applicationName, applicationNamespace, "/sync"), body)
+}
 
-	var result Application
-
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
+func (api *Api) RefreshApplication(applicationName string, applicationNamespace string, hard bool) error {
+	refresh := "normal"
+	if hard {
+		refresh = "hard"
+	}
+	query := url.Values{"refresh": []string{refresh}}
+	return api.doApplicationAction("GET", applicationUrlWithQuery(api.Host, applicationName, applicationNamespace, "", query), nil)
+}
 
-	if err != nil {
-		return nil, err
+func (api *Api) RollbackApplication(applicationName string, applicationNamespace string, historyId int64) error {
+	body := map[string]interface{}{
+		"name": applicationName,
+		"id":   historyId,
+	}
+	if applicationNamespace != "" {
+		body["appNamespace"] = applicationNamespace
 	}
+	return api.doApplicationAction("POST", applicationUrl(api.Host, applicationName, applicationNamespace, "/rollback"), body)
+}
 
-	return result.Items, nil
+func (api *Api) TerminateOperation(applicationName string, applicationNamespace string) error {
+	return api.doApplicationAction("DELETE", applicationUrl(api.Host, applicationName, applicationNamespace, "/operation"), nil)
 }
@@ -0,0 +1,52 @@
+package argo
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetApplicationErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"not found","message":"applications.argoproj.io \"app\" not found"}`))
+	}))
+	defer server.Close()
+
+	store.SetArgo("token", server.URL)
+	_, err := GetApplication("app", "")
+	if !apierrors.Is(err, apierrors.NotFound) || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("'GetApplication' failed, expected '%v', got '%v'", "not found error with message", err)
+	}
+
+	// invalid host fails before request is sent, it used to panic
+	store.SetArgo("token", "http://[::1")
+	_, err = GetApplication("app", "")
+	if err == nil {
+		t.Errorf("'GetApplication' failed, expected error for invalid host")
+	}
+}
+
+func TestGetProjectIsReused(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"metadata":{"name":"team"},"spec":{"syncWindows":[{"kind":"deny","schedule":"0 22 * * *","duration":"1h"}]}}`))
+	}))
+	defer server.Close()
+
+	store.SetArgo("token", server.URL)
+	for i := 0; i < 3; i++ {
+		project, err := GetInstance().GetProject(context.Background(), "team")
+		if err != nil || len(project.Spec.SyncWindows) != 1 {
+			t.Errorf("'GetProject' failed, unexpected '%v', error '%v'", project, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("'GetProject' failed, expected '%v' request to argocd, got '%v'", 1, requests)
+	}
+}
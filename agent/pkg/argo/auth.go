@@ -0,0 +1,78 @@
+package argo
+
+import (
+	"errors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	store2 "github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reauthenticationInterval prevents every failed request from creating new session
+const reauthenticationInterval = 10 * time.Second
+
+var (
+	reauthenticationLock sync.Mutex
+	reauthenticatedAt    time.Time
+	// renewedToken is token of session, that replaced configured token, requests of all goroutines read it with currentToken
+	renewedToken atomic.Value
+)
+
+type renewal struct {
+	configured string
+	token      string
+}
+
+// currentToken returns token of renewed session, unless configured token was changed after renewal
+func currentToken() string {
+	configured := store2.GetStore().Argo.Token
+	if renewed, ok := renewedToken.Load().(renewal); ok && renewed.configured == configured {
+		return renewed.token
+	}
+	return configured
+}
+
+// Reauthenticate creates new session of argocd, it's possible only when agent was configured with username and password
+func Reauthenticate() error {
+	reauthenticationLock.Lock()
+	defer reauthenticationLock.Unlock()
+
+	argoConfig := store2.GetStore().Argo
+	if argoConfig.Username == "" {
+		return errors.New("argocd token can't be renewed, agent is configured with ARGO_TOKEN")
+	}
+	if time.Since(reauthenticatedAt) < reauthenticationInterval {
+		// session was renewed by concurrent request
+		return nil
+	}
+
+	token, err := GetToken(argoConfig.Username, argoConfig.Password, argoConfig.Host)
+	if err != nil {
+		return err
+	}
+
+	renewedToken.Store(renewal{configured: argoConfig.Token, token: token})
+	reauthenticatedAt = time.Now()
+	logger.GetLogger().Info("Argocd session is renewed")
+	return nil
+}
+
+// WithReauthentication repeats call once with new session, when argocd rejected token
+func WithReauthentication(call func() error) error {
+	err := call()
+	if !IsUnauthorized(err) {
+		return err
+	}
+	if authErr := Reauthenticate(); authErr != nil {
+		logger.GetLogger().Errorf("Failed to renew argocd session, reason %v", authErr)
+		return err
+	}
+	return call()
+}
+
+// IsUnauthorized says if argocd rejected token
+func IsUnauthorized(err error) bool {
+	return apierrors.Is(err, apierrors.Unauthorized) && apierrors.BackendOf(err) == apierrors.ArgoBackend
+}
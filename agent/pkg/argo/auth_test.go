@@ -0,0 +1,62 @@
+package argo
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReauthenticateConcurrently(t *testing.T) {
+	var sessions int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/session" {
+			atomic.AddInt32(&sessions, 1)
+			_, _ = w.Write([]byte(`{"token":"renewed"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer renewed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+
+	store.SetArgo("expired", server.URL)
+	store.SetArgoCredentials("admin", "password")
+	defer store.SetArgoCredentials("", "")
+	reauthenticatedAt = time.Time{}
+
+	var wg sync.WaitGroup
+	var failures int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := WithReauthentication(func() error {
+				_, _, err := ListApplications()
+				return err
+			})
+			if err != nil {
+				atomic.AddInt32(&failures, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failures != 0 || sessions != 1 {
+		t.Errorf("'Reauthenticate' failed, expected one session and no failures, got '%v' sessions and '%v' failures", sessions, failures)
+	}
+	if currentToken() != "renewed" {
+		t.Errorf("'currentToken' failed, expected '%v', got '%v'", "renewed", currentToken())
+	}
+
+	store.SetArgo("configured", server.URL)
+	if currentToken() != "configured" {
+		t.Errorf("'currentToken' failed, expected configured token to replace renewed one, got '%v'", currentToken())
+	}
+}
@@ -0,0 +1,85 @@
+package argo
+
+import (
+	"sync"
+	"time"
+)
+
+// ApplicationCache returns manifest of application, that event source already holds, namespace is never empty
+type ApplicationCache func(name string, namespace string) (map[string]interface{}, bool)
+
+// ProjectCache returns manifest of project, that informer already holds, projects live in argocd control plane namespace
+type ProjectCache func(name string, namespace string) (map[string]interface{}, bool)
+
+// requestedProjectTTL is how long project, that was requested from argocd, is reused, when informer doesn't hold projects
+const requestedProjectTTL = time.Minute
+
+type requestedProject struct {
+	project     ProjectItem
+	requestedAt time.Time
+}
+
+var (
+	applicationCache     ApplicationCache
+	applicationCacheLock sync.RWMutex
+	projectCache         ProjectCache
+	projectCacheLock     sync.RWMutex
+	requestedProjects    = make(map[string]requestedProject)
+	requestedLock        sync.Mutex
+)
+
+// SetApplicationCache is called by informer or stream watcher, when they start to hold applications
+func SetApplicationCache(cache ApplicationCache) {
+	applicationCacheLock.Lock()
+	defer applicationCacheLock.Unlock()
+	applicationCache = cache
+}
+
+func cachedApplication(name string, namespace string) (map[string]interface{}, bool) {
+	applicationCacheLock.RLock()
+	cache := applicationCache
+	applicationCacheLock.RUnlock()
+
+	if cache == nil {
+		return nil, false
+	}
+	if namespace == "" {
+		namespace = controlPlaneNamespace()
+	}
+	return cache(name, namespace)
+}
+
+// SetProjectCache is called by informer, when it starts to hold projects
+func SetProjectCache(cache ProjectCache) {
+	projectCacheLock.Lock()
+	defer projectCacheLock.Unlock()
+	projectCache = cache
+}
+
+func cachedProject(name string) (map[string]interface{}, bool) {
+	projectCacheLock.RLock()
+	cache := projectCache
+	projectCacheLock.RUnlock()
+
+	if cache == nil {
+		return nil, false
+	}
+	return cache(name, controlPlaneNamespace())
+}
+
+func recentlyRequestedProject(name string) (*ProjectItem, bool) {
+	requestedLock.Lock()
+	defer requestedLock.Unlock()
+	requested, ok := requestedProjects[name]
+	if !ok || time.Since(requested.requestedAt) > requestedProjectTTL {
+		return nil, false
+	}
+	project := requested.project
+	return &project, true
+}
+
+func rememberRequestedProject(name string, project ProjectItem) {
+	requestedLock.Lock()
+	defer requestedLock.Unlock()
+	requestedProjects[name] = requestedProject{project: project, requestedAt: time.Now()}
+}
@@ -0,0 +1,48 @@
+package argo
+
+import (
+	store2 "github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"strings"
+)
+
+// DefaultNamespace is the namespace argocd control plane is installed to by default
+const DefaultNamespace = "argocd"
+
+// qualifiedNameSeparator can't appear in kubernetes object names, so qualified names never collide
+const qualifiedNameSeparator = "_"
+
+// ApplicationKey returns "namespace/name" key that identifies application inside agent (filter state, queue)
+func ApplicationKey(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// QualifiedName returns name that is used for application on codefresh side.
+// Applications from argocd control plane namespace keep plain name, so already created environments stay untouched,
+// applications from other namespaces are prefixed with their namespace
+func QualifiedName(namespace string, name string) string {
+	if namespace == "" || namespace == controlPlaneNamespace() {
+		return name
+	}
+	return namespace + qualifiedNameSeparator + name
+}
+
+// ParseQualifiedName splits name produced by QualifiedName to application namespace and name,
+// namespace is empty for applications from argocd control plane namespace
+func ParseQualifiedName(qualifiedName string) (string, string) {
+	parts := strings.SplitN(qualifiedName, qualifiedNameSeparator, 2)
+	if len(parts) != 2 {
+		return "", qualifiedName
+	}
+	return parts[0], parts[1]
+}
+
+func controlPlaneNamespace() string {
+	namespace := store2.GetStore().Argo.Namespace
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
@@ -0,0 +1,42 @@
+package argo
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"testing"
+)
+
+func TestQualifiedName(t *testing.T) {
+	store.SetArgoNamespace("argocd")
+
+	cases := []struct {
+		namespace string
+		name      string
+		expected  string
+	}{
+		{"", "app", "app"},
+		{"argocd", "app", "app"},
+		{"team-a", "app", "team-a_app"},
+		{"team-b", "app", "team-b_app"},
+	}
+
+	for _, c := range cases {
+		qualifiedName := QualifiedName(c.namespace, c.name)
+		if qualifiedName != c.expected {
+			t.Errorf("'QualifiedName' failed, expected '%v', got '%v'", c.expected, qualifiedName)
+		}
+	}
+}
+
+func TestParseQualifiedName(t *testing.T) {
+	store.SetArgoNamespace("argocd")
+
+	namespace, name := ParseQualifiedName(QualifiedName("team-a", "app"))
+	if namespace != "team-a" || name != "app" {
+		t.Errorf("'ParseQualifiedName' failed, expected '%v/%v', got '%v/%v'", "team-a", "app", namespace, name)
+	}
+
+	namespace, name = ParseQualifiedName(QualifiedName("argocd", "app"))
+	if namespace != "" || name != "app" {
+		t.Errorf("'ParseQualifiedName' failed, expected '%v', got '%v/%v'", "app", namespace, name)
+	}
+}
@@ -0,0 +1,147 @@
+package argo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	store2 "github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Types of application events in argocd stream, they are same as kubernetes watch event types
+const (
+	AddedEvent    = "ADDED"
+	ModifiedEvent = "MODIFIED"
+	DeletedEvent  = "DELETED"
+)
+
+// maxStreamMessageSize limits single message of stream, message contains whole application with status
+const maxStreamMessageSize = 64 * 1024 * 1024
+
+type ApplicationEvent struct {
+	Type        string                 `json:"type"`
+	Application map[string]interface{} `json:"application"`
+}
+
+type streamMessage struct {
+	Result *ApplicationEvent `json:"result"`
+	Error  *struct {
+		GrpcCode int    `json:"grpc_code"`
+		Message  string `json:"message"`
+	} `json:"error"`
+}
+
+type applicationList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []map[string]interface{} `json:"items"`
+}
+
+// ListApplications returns manifests of all applications and resource version, that stream can be started from
+func ListApplications() ([]map[string]interface{}, string, error) {
+	token := currentToken()
+	host := store2.GetStore().Argo.Host
+
+	var result applicationList
+	err := doRequest(context.Background(), "GET", host+"/api/v1/applications", token, nil, &result)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result.Items, result.Metadata.ResourceVersion, nil
+}
+
+// StreamApplications calls handler for every event of argocd applications stream, started after resourceVersion,
+// it returns when stream is broken or ctx is done
+func StreamApplications(ctx context.Context, resourceVersion string, handler func(event ApplicationEvent)) error {
+	token := currentToken()
+	host := store2.GetStore().Argo.Host
+
+	streamUrl := host + "/api/v1/stream/applications"
+	if resourceVersion != "" {
+		streamUrl += "?resourceVersion=" + url.QueryEscape(resourceVersion)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", streamUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Accept", "text/event-stream")
+
+	resp, err := getStreamHttpClient().Do(req)
+	if err != nil {
+		return apierrors.FromNetwork(apierrors.ArgoBackend, req, err)
+	}
+	defer resp.Body.Close()
+
+	err = checkResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	return readStream(resp.Body, handler)
+}
+
+// kindOfGrpcCode maps code of error, that argocd sends inside of stream
+func kindOfGrpcCode(code int) apierrors.Kind {
+	switch code {
+	case 5:
+		return apierrors.NotFound
+	case 7:
+		return apierrors.Forbidden
+	case 8:
+		return apierrors.RateLimited
+	case 16:
+		return apierrors.Unauthorized
+	case 4, 10, 14:
+		return apierrors.Transient
+	}
+	return apierrors.Permanent
+}
+
+// readStream supports both server-sent events ("data: {...}" lines) and newline delimited json
+func readStream(body io.Reader, handler func(event ApplicationEvent)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamMessageSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if bytes.HasPrefix(line, []byte("data:")) {
+			line = bytes.TrimSpace(line[len("data:"):])
+		}
+		if len(line) == 0 || line[0] != '{' {
+			// empty line between events, comment or other field of event
+			continue
+		}
+
+		var message streamMessage
+		err := json.Unmarshal(line, &message)
+		if err != nil {
+			return err
+		}
+		if message.Error != nil {
+			return &apierrors.Error{
+				Kind:    kindOfGrpcCode(message.Error.GrpcCode),
+				Backend: apierrors.ArgoBackend,
+				Method:  "GET",
+				Path:    "/api/v1/stream/applications",
+				Message: message.Error.Message,
+			}
+		}
+		if message.Result != nil && message.Result.Application != nil {
+			handler(*message.Result)
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
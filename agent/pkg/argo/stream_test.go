@@ -0,0 +1,78 @@
+package argo
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadStream(t *testing.T) {
+	body := `data: {"result":{"type":"ADDED","application":{"metadata":{"name":"a","resourceVersion":"1"}}}}
+
+: keep alive
+data: {"result":{"type":"MODIFIED","application":{"metadata":{"name":"a","resourceVersion":"2"}}}}
+{"result":{"type":"DELETED","application":{"metadata":{"name":"a","resourceVersion":"3"}}}}
+`
+	var types []string
+	err := readStream(strings.NewReader(body), func(event ApplicationEvent) {
+		types = append(types, event.Type)
+	})
+
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("'readStream' failed, expected '%v', got '%v'", io.ErrUnexpectedEOF, err)
+	}
+	if strings.Join(types, ",") != "ADDED,MODIFIED,DELETED" {
+		t.Errorf("'readStream' failed, expected '%v', got '%v'", "ADDED,MODIFIED,DELETED", types)
+	}
+
+	err = readStream(strings.NewReader(`data: {"error":{"grpc_code":16,"message":"invalid session"}}`), func(event ApplicationEvent) {})
+	if !apierrors.Is(err, apierrors.Unauthorized) || !strings.Contains(err.Error(), "invalid session") {
+		t.Errorf("'readStream' failed, expected '%v', got '%v'", "unauthorized error", err)
+	}
+}
+
+func TestStreamApplications(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/applications":
+			_, _ = w.Write([]byte(`{"metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"a"}}]}`))
+		case "/api/v1/stream/applications":
+			_, _ = w.Write([]byte(`data: {"result":{"type":"MODIFIED","application":{"metadata":{"name":"a","resourceVersion":"` + r.URL.Query().Get("resourceVersion") + `"}}}}` + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	store.SetArgo("token", server.URL)
+
+	items, resourceVersion, err := ListApplications()
+	if err != nil || len(items) != 1 || resourceVersion != "10" {
+		t.Fatalf("'ListApplications' failed, expected '%v', got '%v %v %v'", "1 item of version 10", len(items), resourceVersion, err)
+	}
+
+	var events []ApplicationEvent
+	_ = StreamApplications(context.Background(), resourceVersion, func(event ApplicationEvent) {
+		events = append(events, event)
+	})
+	if len(events) != 1 {
+		t.Fatalf("'StreamApplications' failed, expected '%v', got '%v'", 1, len(events))
+	}
+	metadata := events[0].Application["metadata"].(map[string]interface{})
+	if metadata["resourceVersion"] != "10" {
+		t.Errorf("'StreamApplications' failed, expected stream from resource version '%v', got '%v'", "10", metadata["resourceVersion"])
+	}
+
+	store.SetArgo("wrong", server.URL)
+	err = StreamApplications(context.Background(), "", func(event ApplicationEvent) {})
+	if !apierrors.Is(err, apierrors.Unauthorized) {
+		t.Errorf("'StreamApplications' failed, expected error for unauthorized stream")
+	}
+}
@@ -0,0 +1,226 @@
+package argo
+
+import (
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kinds of sync windows
+const (
+	AllowWindow = "allow"
+	DenyWindow  = "deny"
+)
+
+// syncWindowBlockedMessage is part of message of operation, that argocd didn't start because of sync window
+const syncWindowBlockedMessage = "blocked by sync window"
+
+// syncSlotSearchLimit is amount of window starts and ends, that are checked for next allowed sync
+const syncSlotSearchLimit = 1000
+
+// ActiveSyncWindow is window, that is active at time of evaluation
+type ActiveSyncWindow struct {
+	SyncWindow
+	StartedAt time.Time
+	EndsAt    time.Time
+}
+
+// SyncWindowsState is result of evaluation of sync windows of project for application
+type SyncWindowsState struct {
+	// Windows are windows of project, that match application
+	Windows           []SyncWindow
+	Active            []ActiveSyncWindow
+	SyncAllowed       bool
+	ManualSyncAllowed bool
+	// NextSyncAt is when automated sync is allowed again, it's zero when sync is allowed now or no slot was found
+	NextSyncAt time.Time
+	// ChangesAt is nearest start or end of matching window, when state should be evaluated again, it's zero without windows
+	ChangesAt time.Time
+}
+
+type scheduledWindow struct {
+	window   SyncWindow
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// IsBlockedBySyncWindow says if message of operation state tells, that argocd didn't start operation because of sync window
+func IsBlockedBySyncWindow(message string) bool {
+	return strings.Contains(strings.ToLower(message), syncWindowBlockedMessage)
+}
+
+// Matches says if window applies to application, like argocd does: by name of application, destination namespace or cluster
+func (window SyncWindow) Matches(name string, destination ApplicationSpecDestination) bool {
+	for _, pattern := range window.Applications {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	for _, pattern := range window.Namespaces {
+		if matchGlob(pattern, destination.Namespace) {
+			return true
+		}
+	}
+	for _, pattern := range window.Clusters {
+		if matchGlob(pattern, destination.Server) || (destination.Name != "" && matchGlob(pattern, destination.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches value to pattern with "*" and "?" wildcards, unlike path.Match "*" matches "/" of cluster urls
+func matchGlob(pattern string, value string) bool {
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	matched, err := regexp.MatchString("^"+expression+"$", value)
+	return err == nil && matched
+}
+
+func parseSyncWindow(window SyncWindow) (*scheduledWindow, error) {
+	if window.Kind != AllowWindow && window.Kind != DenyWindow {
+		return nil, fmt.Errorf("invalid kind \"%s\" of sync window, should be %s or %s", window.Kind, AllowWindow, DenyWindow)
+	}
+	schedule, err := cron.ParseStandard(window.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule \"%s\" of sync window, reason %v", window.Schedule, err)
+	}
+	duration, err := time.ParseDuration(window.Duration)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("invalid duration \"%s\" of sync window", window.Duration)
+	}
+	location, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone \"%s\" of sync window, reason %v", window.TimeZone, err)
+	}
+	return &scheduledWindow{window: window, schedule: schedule, duration: duration, location: location}, nil
+}
+
+// activeSince returns last start of window, when window is active at time
+func (w *scheduledWindow) activeSince(at time.Time) (time.Time, bool) {
+	at = at.In(w.location)
+	start := w.schedule.Next(at.Add(-w.duration))
+	// schedule returns zero time, when it never starts again
+	if start.IsZero() || start.After(at) {
+		return time.Time{}, false
+	}
+	// window can start again before its previous duration is over
+	for next := w.schedule.Next(start); !next.IsZero() && !next.After(at); next = w.schedule.Next(next) {
+		start = next
+	}
+	return start, true
+}
+
+// nextBoundary returns nearest time after at, when window starts or ends
+func (w *scheduledWindow) nextBoundary(at time.Time) time.Time {
+	nextStart := w.schedule.Next(at.In(w.location))
+	if start, active := w.activeSince(at); active {
+		if end := start.Add(w.duration); nextStart.IsZero() || end.Before(nextStart) {
+			return end
+		}
+	}
+	return nextStart
+}
+
+// EvaluateSyncWindows evaluates windows of project for application at time
+func EvaluateSyncWindows(windows []SyncWindow, name string, destination ApplicationSpecDestination, at time.Time) (*SyncWindowsState, error) {
+	state := &SyncWindowsState{
+		Windows: make([]SyncWindow, 0),
+		Active:  make([]ActiveSyncWindow, 0),
+	}
+
+	var scheduled []*scheduledWindow
+	for _, window := range windows {
+		if !window.Matches(name, destination) {
+			continue
+		}
+		parsed, err := parseSyncWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, parsed)
+		state.Windows = append(state.Windows, window)
+
+		if boundary := parsed.nextBoundary(at); !boundary.IsZero() && (state.ChangesAt.IsZero() || boundary.Before(state.ChangesAt)) {
+			state.ChangesAt = boundary
+		}
+
+		if start, active := parsed.activeSince(at); active {
+			state.Active = append(state.Active, ActiveSyncWindow{
+				SyncWindow: window,
+				StartedAt:  start,
+				EndsAt:     start.Add(parsed.duration),
+			})
+		}
+	}
+
+	state.SyncAllowed = canSync(scheduled, at, false)
+	state.ManualSyncAllowed = canSync(scheduled, at, true)
+	if !state.SyncAllowed {
+		state.NextSyncAt = nextSyncSlot(scheduled, at)
+	}
+	return state, nil
+}
+
+// canSync follows argocd: active deny window blocks sync, otherwise active allow window allows it,
+// otherwise sync is blocked when application has allow windows. Manual sync is allowed by blocking windows with manualSync
+func canSync(windows []*scheduledWindow, at time.Time, manual bool) bool {
+	var activeDeny, activeAllow, inactiveAllow []*scheduledWindow
+	for _, window := range windows {
+		_, active := window.activeSince(at)
+		switch {
+		case window.window.Kind == DenyWindow && active:
+			activeDeny = append(activeDeny, window)
+		case window.window.Kind == AllowWindow && active:
+			activeAllow = append(activeAllow, window)
+		case window.window.Kind == AllowWindow:
+			inactiveAllow = append(inactiveAllow, window)
+		}
+	}
+
+	if len(activeDeny) > 0 {
+		return manual && manualSyncEnabled(activeDeny)
+	}
+	if len(activeAllow) > 0 {
+		return true
+	}
+	if len(inactiveAllow) > 0 {
+		return manual && manualSyncEnabled(inactiveAllow)
+	}
+	return true
+}
+
+func manualSyncEnabled(windows []*scheduledWindow) bool {
+	for _, window := range windows {
+		if window.window.ManualSync {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSyncSlot checks starts and ends of windows after time one by one, until automated sync is allowed
+func nextSyncSlot(windows []*scheduledWindow, at time.Time) time.Time {
+	current := at
+	for i := 0; i < syncSlotSearchLimit; i++ {
+		var next time.Time
+		for _, window := range windows {
+			boundary := window.nextBoundary(current)
+			if !boundary.IsZero() && (next.IsZero() || boundary.Before(next)) {
+				next = boundary
+			}
+		}
+		if next.IsZero() {
+			return time.Time{}
+		}
+		current = next
+		if canSync(windows, current, false) {
+			return current
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,119 @@
+package argo
+
+import (
+	"testing"
+	"time"
+)
+
+func parseTestTime(value string) time.Time {
+	parsed, _ := time.Parse(time.RFC3339, value)
+	return parsed
+}
+
+func TestSyncWindowMatches(t *testing.T) {
+	destination := ApplicationSpecDestination{Server: "https://kubernetes.default.svc", Namespace: "prod-payments"}
+
+	cases := []struct {
+		window   SyncWindow
+		expected bool
+	}{
+		{SyncWindow{Applications: []string{"pay*"}}, true},
+		{SyncWindow{Applications: []string{"other"}}, false},
+		{SyncWindow{Namespaces: []string{"prod-*"}}, true},
+		{SyncWindow{Clusters: []string{"*"}}, true},
+		{SyncWindow{Clusters: []string{"in-cluster"}}, false},
+		{SyncWindow{}, false},
+	}
+	for _, item := range cases {
+		if item.window.Matches("payments", destination) != item.expected {
+			t.Errorf("'Matches' failed, expected '%v' for window '%+v'", item.expected, item.window)
+		}
+	}
+}
+
+func TestEvaluateDenyWindow(t *testing.T) {
+	windows := []SyncWindow{
+		{Kind: DenyWindow, Schedule: "0 22 * * *", Duration: "10h", Applications: []string{"*"}, ManualSync: true},
+		{Kind: DenyWindow, Schedule: "0 0 * * *", Duration: "1h", Applications: []string{"other"}},
+	}
+
+	state, err := EvaluateSyncWindows(windows, "app", ApplicationSpecDestination{}, parseTestTime("2021-01-10T23:00:00Z"))
+	if err != nil {
+		t.Errorf("'EvaluateSyncWindows' failed, unexpected error '%v'", err)
+		return
+	}
+	if len(state.Windows) != 1 || len(state.Active) != 1 {
+		t.Errorf("'EvaluateSyncWindows' failed, expected one matching and active window, got '%v' '%v'", len(state.Windows), len(state.Active))
+		return
+	}
+	if !state.Active[0].StartedAt.Equal(parseTestTime("2021-01-10T22:00:00Z")) || !state.Active[0].EndsAt.Equal(parseTestTime("2021-01-11T08:00:00Z")) {
+		t.Errorf("'EvaluateSyncWindows' failed, unexpected active window '%+v'", state.Active[0])
+	}
+	if state.SyncAllowed || !state.ManualSyncAllowed {
+		t.Errorf("'EvaluateSyncWindows' failed, expected only manual sync to be allowed, got '%v' '%v'", state.SyncAllowed, state.ManualSyncAllowed)
+	}
+	if !state.NextSyncAt.Equal(parseTestTime("2021-01-11T08:00:00Z")) {
+		t.Errorf("'EvaluateSyncWindows' failed, expected next sync at '%v', got '%v'", "2021-01-11T08:00:00Z", state.NextSyncAt)
+	}
+	if !state.ChangesAt.Equal(parseTestTime("2021-01-11T08:00:00Z")) {
+		t.Errorf("'EvaluateSyncWindows' failed, expected change of state at '%v', got '%v'", "2021-01-11T08:00:00Z", state.ChangesAt)
+	}
+
+	state, _ = EvaluateSyncWindows(windows, "app", ApplicationSpecDestination{}, parseTestTime("2021-01-10T12:00:00Z"))
+	if !state.SyncAllowed || len(state.Active) != 0 || !state.NextSyncAt.IsZero() {
+		t.Errorf("'EvaluateSyncWindows' failed, expected sync to be allowed outside of deny window, got '%+v'", state)
+	}
+}
+
+func TestEvaluateAllowWindow(t *testing.T) {
+	windows := []SyncWindow{
+		{Kind: AllowWindow, Schedule: "0 9 * * 1-5", Duration: "8h", Namespaces: []string{"*"}},
+		// deny window inside of allow window takes precedence
+		{Kind: DenyWindow, Schedule: "0 12 * * *", Duration: "1h", Namespaces: []string{"*"}},
+	}
+
+	// saturday
+	state, _ := EvaluateSyncWindows(windows, "app", ApplicationSpecDestination{Namespace: "default"}, parseTestTime("2021-01-09T10:00:00Z"))
+	if state.SyncAllowed || state.ManualSyncAllowed {
+		t.Errorf("'EvaluateSyncWindows' failed, expected sync to be blocked outside of allow window")
+	}
+	if !state.NextSyncAt.Equal(parseTestTime("2021-01-11T09:00:00Z")) {
+		t.Errorf("'EvaluateSyncWindows' failed, expected next sync at '%v', got '%v'", "2021-01-11T09:00:00Z", state.NextSyncAt)
+	}
+
+	// monday, inside of deny window
+	state, _ = EvaluateSyncWindows(windows, "app", ApplicationSpecDestination{Namespace: "default"}, parseTestTime("2021-01-11T12:30:00Z"))
+	if state.SyncAllowed || len(state.Active) != 2 {
+		t.Errorf("'EvaluateSyncWindows' failed, expected sync to be blocked by active deny window, got '%+v'", state)
+	}
+	if !state.NextSyncAt.Equal(parseTestTime("2021-01-11T13:00:00Z")) {
+		t.Errorf("'EvaluateSyncWindows' failed, expected next sync at '%v', got '%v'", "2021-01-11T13:00:00Z", state.NextSyncAt)
+	}
+}
+
+func TestEvaluateInvalidWindow(t *testing.T) {
+	invalid := []SyncWindow{
+		{Kind: "freeze", Schedule: "* * * * *", Duration: "1h", Applications: []string{"*"}},
+		{Kind: DenyWindow, Schedule: "every day", Duration: "1h", Applications: []string{"*"}},
+		{Kind: DenyWindow, Schedule: "* * * * *", Duration: "1d", Applications: []string{"*"}},
+	}
+	for _, window := range invalid {
+		_, err := EvaluateSyncWindows([]SyncWindow{window}, "app", ApplicationSpecDestination{}, time.Now())
+		if err == nil {
+			t.Errorf("'EvaluateSyncWindows' failed, expected error for window '%+v'", window)
+		}
+	}
+
+	// windows, that don't match application, aren't validated
+	other := []SyncWindow{{Kind: "freeze", Schedule: "every day", Duration: "1d", Applications: []string{"other"}}}
+	state, err := EvaluateSyncWindows(other, "app", ApplicationSpecDestination{}, time.Now())
+	if err != nil || !state.SyncAllowed {
+		t.Errorf("'EvaluateSyncWindows' failed, unexpected error '%v' for window of other application", err)
+	}
+}
+
+func TestIsBlockedBySyncWindow(t *testing.T) {
+	if !IsBlockedBySyncWindow("Sync operation blocked by sync window") || IsBlockedBySyncWindow("successfully synced") {
+		t.Errorf("'IsBlockedBySyncWindow' failed")
+	}
+}
@@ -5,9 +5,23 @@ type ResourceTree struct {
 }
 
 type Node struct {
-	Kind   string
-	Uid    string
-	Health Health
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+	Uid       string
+	Health    Health
+	// ParentRefs is empty for resources, that are managed by application directly
+	ParentRefs []ResourceRef
+}
+
+type ResourceRef struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	Uid       string
 }
 
 type Health struct {
@@ -69,6 +83,23 @@ type Project struct {
 
 type ProjectItem struct {
 	Metadata ProjectMetadata `json:"metadata"`
+	Spec     ProjectSpec     `json:"spec"`
+}
+
+type ProjectSpec struct {
+	SyncWindows []SyncWindow `json:"syncWindows"`
+}
+
+// SyncWindow allows or denies syncs of matching applications for duration after every start by cron schedule
+type SyncWindow struct {
+	Kind         string   `json:"kind"`
+	Schedule     string   `json:"schedule"`
+	Duration     string   `json:"duration"`
+	Applications []string `json:"applications"`
+	Namespaces   []string `json:"namespaces"`
+	Clusters     []string `json:"clusters"`
+	ManualSync   bool     `json:"manualSync"`
+	TimeZone     string   `json:"timeZone"`
 }
 
 type ProjectMetadata struct {
@@ -86,10 +117,30 @@ type ApplicationItem struct {
 }
 
 type ApplicationMetadata struct {
-	Name        string `json:"name"`
-	UID         string `json:"uid"`
-	Namespace   string `json:"namespace"`
-	ClusterName string `json:"clusterName"`
+	Name            string            `json:"name"`
+	UID             string            `json:"uid"`
+	Namespace       string            `json:"namespace"`
+	ClusterName     string            `json:"clusterName"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences"`
+}
+
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+}
+
+// ApplicationSetName returns name of applicationset that generated application, empty if application was created directly
+func (metadata ApplicationMetadata) ApplicationSetName() string {
+	for _, owner := range metadata.OwnerReferences {
+		if owner.Kind == "ApplicationSet" {
+			return owner.Name
+		}
+	}
+	return ""
 }
 
 type ApplicationSpecDestination struct {
@@ -111,15 +162,33 @@ type ApplicationResource struct {
 	Health    Health `json:"health"`
 }
 
+// ResourceResult is result of sync of single resource, hook fields are filled for hook resources only
+type ResourceResult struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	HookType  string `json:"hookType"`
+	HookPhase string `json:"hookPhase"`
+	SyncPhase string `json:"syncPhase"`
+}
+
 type ArgoApplicationHistoryItem struct {
-	Id       int64
-	Revision string
+	Id              int64
+	Revision        string
+	DeployStartedAt string
+	DeployedAt      string
 }
 
 type ArgoApplication struct {
 	Status struct {
 		Health struct {
 			Status string
+			// LastTransitionTime is reported by newer versions of argocd
+			LastTransitionTime string
 		}
 		Sync struct {
 			Status   string
@@ -127,9 +196,20 @@ type ArgoApplication struct {
 		}
 		History        []ArgoApplicationHistoryItem
 		OperationState struct {
+			Phase      string
+			Message    string
+			StartedAt  string
 			FinishedAt string
+			RetryCount int64
+			Operation  struct {
+				InitiatedBy struct {
+					Username  string
+					Automated bool
+				}
+			}
 			SyncResult struct {
-				Revision string
+				Revision  string
+				Resources []ResourceResult
 			}
 		}
 	}
@@ -137,12 +217,43 @@ type ArgoApplication struct {
 		Source struct {
 			RepoURL string
 		}
-		Project    string
-		SyncPolicy struct {
+		Project     string
+		Destination ApplicationSpecDestination
+		SyncPolicy  struct {
 			Automated interface{}
 		}
 	}
-	Metadata struct {
-		Name string
+	Metadata ApplicationMetadata
+}
+
+type ApplicationSource struct {
+	RepoURL        string `json:"repoURL"`
+	Path           string `json:"path"`
+	Chart          string `json:"chart"`
+	TargetRevision string `json:"targetRevision"`
+}
+
+type ApplicationSetCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+}
+
+type ArgoApplicationSet struct {
+	Metadata ApplicationMetadata
+	Spec     struct {
+		Generators []map[string]interface{}
+		Template   struct {
+			Spec struct {
+				Project     string
+				Source      ApplicationSource
+				Destination ApplicationSpecDestination
+			}
+		}
+	}
+	Status struct {
+		Conditions []ApplicationSetCondition
 	}
 }
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/git"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/kube"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"strings"
+)
+
+const (
+	checkOk      = "OK"
+	checkWarning = "WARNING"
+	checkFailed  = "FAILED"
+)
+
+type checkResult struct {
+	name    string
+	status  string
+	message string
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validate configuration and connectivity of agent",
+	Long:  `Validate configuration, connectivity to argocd and codefresh, RBAC and git token, exits with non zero status if any check failed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		results := runChecks()
+
+		failed := false
+		for _, result := range results {
+			fmt.Printf("[%s] %s: %s\n", result.status, result.name, result.message)
+			if result.status == checkFailed {
+				failed = true
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func result(name string, err error, message string) checkResult {
+	if err != nil {
+		return checkResult{name: name, status: checkFailed, message: err.Error()}
+	}
+	return checkResult{name: name, status: checkOk, message: message}
+}
+
+func runChecks() []checkResult {
+	err := configureLogger()
+	if err == nil {
+		err = configure()
+	}
+	if err != nil {
+		// nothing else can be checked with invalid configuration
+		return []checkResult{result("config", err, "")}
+	}
+
+	results := []checkResult{result("config", nil, "configuration is valid")}
+	results = append(results, checkArgo())
+	results = append(results, checkCodefresh())
+	results = append(results, checkRBAC()...)
+	results = append(results, checkGit())
+	return results
+}
+
+func checkArgo() checkResult {
+	err := configureArgo()
+	if err == nil {
+		err = argo.GetInstance().CheckToken()
+	}
+	if err != nil {
+		return result("argo", err, "")
+	}
+
+	version, err := argo.GetInstance().GetVersion()
+	if err != nil {
+		return result("argo", err, "")
+	}
+	return result("argo", nil, fmt.Sprintf("connected to argocd %s at %s", version, store.GetStore().Argo.Host))
+}
+
+func checkCodefresh() checkResult {
+	integration := store.GetStore().Codefresh.Integration
+	_, err := codefresh2.GetInstance().GetIntegrationByName(integration)
+	if err != nil {
+		return result("codefresh", fmt.Errorf("failed to get integration \"%s\", reason %v", integration, err), "")
+	}
+	return result("codefresh", nil, fmt.Sprintf("integration \"%s\" exists at %s", integration, store.GetStore().Codefresh.Host))
+}
+
+// checkRBAC verifies that agent service account is allowed to watch argocd resources in all namespaces
+func checkRBAC() []checkResult {
+	config, err := kube.BuildConfig()
+	if err != nil {
+		return []checkResult{result("rbac", err, "")}
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return []checkResult{result("rbac", err, "")}
+	}
+
+	var results []checkResult
+	for _, resource := range []string{"applications", "appprojects", "applicationsets"} {
+		var denied []string
+		for _, verb := range []string{"get", "list", "watch"} {
+			review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Group:    "argoproj.io",
+						Resource: resource,
+						Verb:     verb,
+					},
+				},
+			})
+			if err != nil {
+				return append(results, result("rbac", err, ""))
+			}
+			if !review.Status.Allowed {
+				denied = append(denied, verb)
+			}
+		}
+
+		name := "rbac " + resource
+		switch {
+		case len(denied) == 0:
+			results = append(results, result(name, nil, "get, list and watch are allowed"))
+		case resource == "applicationsets":
+			// applicationsets are optional, they are reported only when available
+			results = append(results, checkResult{name: name, status: checkWarning, message: "not allowed to " + strings.Join(denied, ", ") + ", applicationsets won't be reported"})
+		default:
+			results = append(results, result(name, errors.New("not allowed to "+strings.Join(denied, ", ")), ""))
+		}
+	}
+	return results
+}
+
+func checkGit() checkResult {
+	token := store.GetStore().Git.Token
+	if token == "" {
+		return checkResult{name: "git", status: checkWarning, message: "GIT_PASSWORD isn't set, commits and pull requests won't be reported"}
+	}
+
+	scopes, err := git.GetTokenScopes(context.Background(), token)
+	if err != nil {
+		return result("git", err, "")
+	}
+	if len(scopes) == 0 {
+		return checkResult{name: "git", status: checkWarning, message: "token is valid, but its scopes can't be verified"}
+	}
+	if !util.Contains(scopes, "repo") {
+		return checkResult{name: "git", status: checkWarning, message: fmt.Sprintf("token has scopes %s, \"repo\" scope is required for private repositories", strings.Join(scopes, ", "))}
+	}
+	return result("git", nil, fmt.Sprintf("token has scopes %s", strings.Join(scopes, ", ")))
+}
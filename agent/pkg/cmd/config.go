@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/handler"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/httpclient"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/reconcile"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/redact"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func configureLogger() error {
+	logLevel, logLevelExistence := os.LookupEnv("LOG_LEVEL")
+	if !logLevelExistence || logLevel == "" {
+		logLevel = "info"
+	}
+	level, err := logger.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	logFormat, _ := os.LookupEnv("LOG_FORMAT")
+	logger.Configure(level, logFormat)
+	return nil
+}
+
+// configureArgo resolves argocd token, it's separate step because it requires connection to argocd
+func configureArgo() error {
+	argoHost, argoHostExistence := os.LookupEnv("ARGO_HOST")
+	if !argoHostExistence {
+		return errors.New("ARGO_HOST variable doesnt exist")
+	}
+
+	argoToken, argoTokenExistence := os.LookupEnv("ARGO_TOKEN")
+	if !argoTokenExistence || argoToken == "" {
+
+		argoUsername, argoUsernameExistence := os.LookupEnv("ARGO_USERNAME")
+		if !argoUsernameExistence {
+			return errors.New("ARGO_USERNAME variable doesnt exist")
+		}
+
+		argoPassword, argoPasswordExistence := os.LookupEnv("ARGO_PASSWORD")
+		if !argoPasswordExistence {
+			return errors.New("ARGO_PASSWORD variable doesnt exist")
+		}
+
+		token, err := argo.GetToken(argoUsername, argoPassword, argoHost)
+
+		if err != nil {
+			return err
+		}
+
+		store.SetArgo(token, argoHost)
+		store.SetArgoCredentials(argoUsername, argoPassword)
+
+	} else {
+		store.SetArgo(argoToken, argoHost)
+	}
+
+	return nil
+}
+
+// configure reads configuration of agent from environment variables to store
+func configure() error {
+	argoNamespace, argoNamespaceExistence := os.LookupEnv("ARGO_NAMESPACE")
+	if !argoNamespaceExistence || argoNamespace == "" {
+		argoNamespace = argo.DefaultNamespace
+	}
+
+	store.SetArgoNamespace(argoNamespace)
+
+	codefreshToken, codefreshTokenExistence := os.LookupEnv("CODEFRESH_TOKEN")
+	if !codefreshTokenExistence {
+		return errors.New("CODEFRESH_TOKEN variable doesnt exist")
+	}
+
+	codefreshHost, codefreshHostExistance := os.LookupEnv("CODEFRESH_HOST")
+	if !codefreshHostExistance {
+		codefreshHost = "https://g.codefresh.io"
+	}
+
+	codefreshIntegrationName, codefreshIntegrationNameExistence := os.LookupEnv("CODEFRESH_INTEGRATION")
+	if !codefreshIntegrationNameExistence {
+		return errors.New("CODEFRESH_INTEGRATION variable doesnt exist")
+	}
+
+	var applications []string
+	syncMode, _ := os.LookupEnv("SYNC_MODE")
+	if syncMode == codefresh2.SelectSync {
+		applicationsToSyncEncodedJson, _ := os.LookupEnv("APPLICATIONS_FOR_SYNC")
+		applicationsToSyncJson, _ := base64.StdEncoding.DecodeString(applicationsToSyncEncodedJson)
+		_ = json.Unmarshal(applicationsToSyncJson, &applications)
+	}
+
+	store.SetSyncOptions(syncMode, applications)
+
+	var syncRules []store.SyncRule
+	syncRulesEncodedJson, syncRulesExistence := os.LookupEnv("SYNC_RULES")
+	if syncMode == codefresh2.SelectSync && syncRulesExistence && syncRulesEncodedJson != "" {
+		syncRulesJson, err := base64.StdEncoding.DecodeString(syncRulesEncodedJson)
+		if err == nil {
+			syncRules, err = handler.ParseSyncRules(syncRulesJson)
+		}
+		if err != nil {
+			return fmt.Errorf("SYNC_RULES variable is invalid, reason %v", err)
+		}
+	}
+
+	var redactRules []redact.Rule
+	redactRulesEncodedJson, redactRulesExistence := os.LookupEnv("REDACT_RULES")
+	if redactRulesExistence && redactRulesEncodedJson != "" {
+		redactRulesJson, err := base64.StdEncoding.DecodeString(redactRulesEncodedJson)
+		if err == nil {
+			err = json.Unmarshal(redactRulesJson, &redactRules)
+		}
+		if err != nil {
+			return fmt.Errorf("REDACT_RULES variable is invalid, reason %v", err)
+		}
+	}
+	redactEnvPattern, _ := os.LookupEnv("REDACT_ENV_PATTERN")
+	err := redact.Init(redactRules, redactEnvPattern)
+	if err != nil {
+		return fmt.Errorf("redaction is misconfigured, reason %v", err)
+	}
+
+	store.SetSyncRules(syncRules)
+	if len(syncRules) > 0 {
+		diagnostics.EnableFeature("sync-rules")
+	}
+
+	store.SetCodefresh(codefreshHost, codefreshToken, codefreshIntegrationName)
+
+	agentVersion, agentVersionExistence := os.LookupEnv("AGENT_VERSION")
+	if !agentVersionExistence {
+		logger.GetLogger().Errorf("No agent version!")
+	} else {
+		store.SetAgent(agentVersion)
+	}
+
+	password, passwordExistence := os.LookupEnv("GIT_PASSWORD")
+	if !passwordExistence {
+		logger.GetLogger().Errorf("No git context")
+	} else {
+		store.SetGit(password)
+	}
+
+	commandsSecret, _ := os.LookupEnv("COMMANDS_SECRET")
+	commandsAllowed, _ := os.LookupEnv("COMMANDS_ALLOWED")
+	var allowedCommands []string
+	for _, commandType := range strings.Split(commandsAllowed, ",") {
+		if strings.TrimSpace(commandType) != "" {
+			allowedCommands = append(allowedCommands, strings.TrimSpace(commandType))
+		}
+	}
+	store.SetCommands(commandsSecret, allowedCommands)
+
+	reconcileInterval, reconcileIntervalExistence := os.LookupEnv("RECONCILE_INTERVAL")
+	if !reconcileIntervalExistence {
+		reconcileInterval = "10m"
+	}
+	reconcileRepair, _ := os.LookupEnv("RECONCILE_REPAIR")
+	var repairCategories []string
+	for _, category := range strings.Split(reconcileRepair, ",") {
+		category = strings.TrimSpace(category)
+		if category == "" {
+			continue
+		}
+		if category != reconcile.MissingCategory && category != reconcile.OrphanedCategory && category != reconcile.StaleCategory {
+			return fmt.Errorf("unknown RECONCILE_REPAIR category \"%s\", should be missing, orphaned or stale", category)
+		}
+		repairCategories = append(repairCategories, category)
+	}
+	store.SetReconcile(reconcileInterval, repairCategories)
+
+	httpConfig := httpclient.DefaultConfig()
+	httpTimeout, httpTimeoutExistence := os.LookupEnv("HTTP_TIMEOUT")
+	if httpTimeoutExistence && httpTimeout != "" {
+		timeout, err := time.ParseDuration(httpTimeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid HTTP_TIMEOUT \"%s\", should be positive duration like 30s", httpTimeout)
+		}
+		httpConfig.Timeout = timeout
+	}
+	httpRetries, httpRetriesExistence := os.LookupEnv("HTTP_RETRIES")
+	if httpRetriesExistence && httpRetries != "" {
+		retries, err := strconv.Atoi(httpRetries)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("invalid HTTP_RETRIES \"%s\", should be non negative number", httpRetries)
+		}
+		httpConfig.Retries = retries
+	}
+	httpclient.Configure(httpConfig.Timeout, httpConfig.Retries)
+
+	codefreshGzip, _ := os.LookupEnv("CODEFRESH_GZIP")
+	var batchInterval time.Duration
+	codefreshBatchInterval, codefreshBatchIntervalExistence := os.LookupEnv("CODEFRESH_BATCH_INTERVAL")
+	if codefreshBatchIntervalExistence && codefreshBatchInterval != "" {
+		interval, err := time.ParseDuration(codefreshBatchInterval)
+		if err != nil || interval < 0 {
+			return fmt.Errorf("invalid CODEFRESH_BATCH_INTERVAL \"%s\", should be duration like 5s", codefreshBatchInterval)
+		}
+		batchInterval = interval
+	}
+	maxPayloadSize := codefresh2.DefaultMaxPayloadSize
+	codefreshMaxPayloadSize, codefreshMaxPayloadSizeExistence := os.LookupEnv("CODEFRESH_MAX_PAYLOAD_SIZE")
+	if codefreshMaxPayloadSizeExistence && codefreshMaxPayloadSize != "" {
+		size, err := strconv.Atoi(codefreshMaxPayloadSize)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("invalid CODEFRESH_MAX_PAYLOAD_SIZE \"%s\", should be positive number of bytes", codefreshMaxPayloadSize)
+		}
+		maxPayloadSize = size
+	}
+	codefreshChunking, _ := os.LookupEnv("CODEFRESH_CHUNKING")
+	store.SetCodefreshUploads(codefreshGzip == "true", batchInterval, maxPayloadSize, codefreshChunking == "true")
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/extract"
+	"github.com/spf13/cobra"
+)
+
+var dumpStateCmd = &cobra.Command{
+	Use:   "dump-state <application>",
+	Short: "Print environment, that agent would send to codefresh for application, without sending it",
+	Long: `Print environment, that agent would send to codefresh for application, without sending it.
+Application from namespace other than argocd control plane one is passed as "<namespace>_<name>"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := configureLogger()
+		if err == nil {
+			err = configure()
+		}
+		if err == nil {
+			err = configureArgo()
+		}
+		if err != nil {
+			return err
+		}
+
+		env, err := extract.ExtractNewApplication(context.Background(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to prepare environment of \"%s\", reason %v", args[0], err)
+		}
+
+		output, err := json.MarshalIndent(env, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dumpStateCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dryrun"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/extract"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/queue"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/replay"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"time"
+)
+
+// replayArgoHost is never requested, responses of argocd are taken from archive
+const replayArgoHost = "http://argocd.replay"
+
+var replayOutput string
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <archive>",
+	Short: "Feed archive recorded with RECORD through agent and print requests, that would be sent to codefresh",
+	Long: `Feed informer events of archive recorded with RECORD through agent and print requests, that would be sent to codefresh.
+Argocd and github aren't requested, their responses are taken from archive, so same archive always produces same requests`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := configureLogger()
+		if err != nil {
+			return err
+		}
+
+		entries, err := replay.ReadArchive(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read archive \"%s\", reason %v", args[0], err)
+		}
+
+		return replayEntries(entries, replayOutput)
+	},
+}
+
+func init() {
+	replayCmd.Flags().StringVar(&replayOutput, "output", dryrun.Stdout, "\"stdout\" or directory, that requests file is written to")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func replayEntries(entries []replay.Entry, output string) error {
+	var config *replay.Config
+	var events []replay.Event
+	var responses []replay.Response
+	for _, entry := range entries {
+		switch entry.Kind {
+		case replay.ConfigEntry:
+			config = entry.Config
+		case replay.EventEntry:
+			events = append(events, *entry.Event)
+		case replay.ResponseEntry:
+			responses = append(responses, *entry.Response)
+		}
+	}
+	if config == nil {
+		return errors.New("archive doesn't contain config of recorded agent")
+	}
+
+	store.SetArgo("", replayArgoHost)
+	store.SetArgoNamespace(config.ArgoNamespace)
+	store.SetCodefresh("", "", config.Integration)
+	store.SetSyncOptions(config.SyncMode, config.ApplicationsForSync)
+	store.SetSyncRules(config.SyncRules)
+
+	err := dryrun.Init(output)
+	if err != nil {
+		return fmt.Errorf("failed to init output \"%s\", reason %v", output, err)
+	}
+	replay.UseResponses(responses)
+
+	queueProcessor := queue.EnvQueueProcessor{}
+	for i, event := range events {
+		eventTime, err := time.Parse(time.RFC3339Nano, event.Time)
+		if err != nil {
+			return fmt.Errorf("invalid time of event %v, reason %v", i, err)
+		}
+		clock := func() time.Time {
+			return eventTime
+		}
+		dryrun.SetClock(clock)
+		transform.SetClock(clock)
+
+		obj := &unstructured.Unstructured{Object: event.Object}
+		logger.GetLogger().Debugf("Replay %s event of application \"%s\"", event.Type, obj.GetName())
+		switch event.Type {
+		case replay.AddEvent:
+			extract.OnApplicationAdd(obj)
+		case replay.UpdateEvent:
+			extract.OnApplicationUpdate(&unstructured.Unstructured{Object: event.OldObject}, obj)
+		case replay.DeleteEvent:
+			extract.OnApplicationDelete(obj)
+		default:
+			return fmt.Errorf("unknown type \"%s\" of event %v", event.Type, i)
+		}
+		// items are processed right after event, as agent would do without load
+		queueProcessor.Drain()
+	}
+
+	return nil
+}
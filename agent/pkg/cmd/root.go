@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "argocd-listener",
+	Short: "Codefresh argocd agent",
+	Long:  `Codefresh argocd agent, runs agent when no command is passed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		run()
+	},
+}
+
+// Execute executes the root command.
+func Execute() error {
+	return rootCmd.Execute()
+}
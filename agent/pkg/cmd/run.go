@@ -0,0 +1,372 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dora"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dryrun"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/extract"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/handler"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/heartbeat"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/kube"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/queue"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/redact"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/replay"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/scheduler"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/sink"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run agent, default command",
+	Run: func(cmd *cobra.Command, args []string) {
+		run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
+
+// initServices creates optional parts of agent, that are enabled by environment variables
+func initServices() error {
+	dryRunOutput, dryRunExistence := os.LookupEnv("DRY_RUN")
+	if dryRunExistence && dryRunOutput != "" {
+		err := dryrun.Init(dryRunOutput)
+		if err != nil {
+			return fmt.Errorf("failed to init dry run output \"%s\", reason %v", dryRunOutput, err)
+		}
+		logger.GetLogger().Infof("Dry run mode, requests to codefresh and sinks are written to \"%s\"", dryRunOutput)
+		diagnostics.EnableFeature("dry-run")
+	}
+
+	var err error
+	stateMaxSize := state.DefaultMaxSize
+	stateMaxSizeStr, stateMaxSizeExistence := os.LookupEnv("STATE_MAX_SIZE")
+	if stateMaxSizeExistence && stateMaxSizeStr != "" {
+		stateMaxSize, err = strconv.Atoi(stateMaxSizeStr)
+		if err != nil {
+			return fmt.Errorf("invalid STATE_MAX_SIZE \"%s\", reason %v", stateMaxSizeStr, err)
+		}
+	}
+	stateBackend, _ := os.LookupEnv("STATE_BACKEND")
+	switch stateBackend {
+	case "", "memory":
+		err = state.Init(stateMaxSize, nil, 0)
+	case "file":
+		statePath, _ := os.LookupEnv("STATE_FILE")
+		if statePath == "" {
+			return errors.New("STATE_FILE variable is required for \"file\" state backend")
+		}
+		err = state.Init(stateMaxSize, &state.FileBackend{Path: statePath}, 10*time.Second)
+	case "configmap":
+		var clientset *kubernetes.Clientset
+		config, configErr := kube.BuildConfig()
+		if configErr == nil {
+			clientset, configErr = kubernetes.NewForConfig(config)
+		}
+		if configErr != nil {
+			return fmt.Errorf("failed to create kubernetes client for state backend, reason %v", configErr)
+		}
+		stateConfigMap, _ := os.LookupEnv("STATE_CONFIGMAP")
+		if stateConfigMap == "" {
+			stateConfigMap = "cf-argocd-agent-state"
+		}
+		stateNamespace := store.GetStore().Argo.Namespace
+		if stateNamespace == "" {
+			stateNamespace = argo.DefaultNamespace
+		}
+		err = state.Init(stateMaxSize, &state.ConfigMapBackend{Client: clientset, Namespace: stateNamespace, Name: stateConfigMap}, 10*time.Second)
+	default:
+		return fmt.Errorf("unknown STATE_BACKEND \"%s\", should be memory, file or configmap", stateBackend)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to init state, reason %v", err)
+	}
+	if stateBackend != "" && stateBackend != "memory" {
+		diagnostics.EnableFeature("state-" + stateBackend)
+	}
+
+	otlpEndpoint, otlpEndpointExistence := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if otlpEndpointExistence && otlpEndpoint != "" {
+		sampleRatio := 1.0
+		sampleRatioStr, sampleRatioExistence := os.LookupEnv("OTEL_TRACES_SAMPLER_ARG")
+		if sampleRatioExistence && sampleRatioStr != "" {
+			sampleRatio, err = strconv.ParseFloat(sampleRatioStr, 64)
+			if err != nil || sampleRatio < 0 || sampleRatio > 1 {
+				return fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG \"%s\", should be number from 0 to 1", sampleRatioStr)
+			}
+		}
+		serviceName, _ := os.LookupEnv("OTEL_SERVICE_NAME")
+		otlpHeaders, _ := os.LookupEnv("OTEL_EXPORTER_OTLP_HEADERS")
+		tracing.Init(tracing.Config{
+			Endpoint:    otlpEndpoint,
+			ServiceName: serviceName,
+			SampleRatio: sampleRatio,
+			Headers:     tracing.ParseHeaders(otlpHeaders),
+		})
+		logger.GetLogger().Infof("Tracing is enabled, spans are exported to \"%s\" with sample ratio %v", otlpEndpoint, sampleRatio)
+		diagnostics.EnableFeature("tracing")
+	}
+
+	sinksConfigPath, sinksConfigExistence := os.LookupEnv("SINKS_CONFIG")
+	if sinksConfigExistence && sinksConfigPath != "" {
+		var sinksConfig []sink.Config
+		sinksConfigJson, err := ioutil.ReadFile(sinksConfigPath)
+		if err == nil {
+			err = json.Unmarshal(sinksConfigJson, &sinksConfig)
+		}
+		if err == nil {
+			err = sink.Init(sinksConfig)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to init sinks from \"%s\", reason %v", sinksConfigPath, err)
+		}
+		diagnostics.EnableFeature("sinks")
+	}
+
+	workloadsFromCluster, _ := os.LookupEnv("WORKLOADS_FROM_CLUSTER")
+	if workloadsFromCluster == "true" {
+		err = extract.WatchWorkloads(make(chan struct{}))
+		if err != nil {
+			return fmt.Errorf("failed to watch workloads of cluster, reason %v", err)
+		}
+		diagnostics.EnableFeature("cluster-workloads")
+	}
+
+	if redact.GetInstance().HasCustomRules() {
+		diagnostics.EnableFeature("redact-rules")
+	}
+
+	doraSummaryInterval, _ := os.LookupEnv("DORA_SUMMARY_INTERVAL")
+	metricsListenAddr, _ := os.LookupEnv("METRICS_LISTEN_ADDR")
+	if doraSummaryInterval != "" || metricsListenAddr != "" {
+		doraWindows, _ := os.LookupEnv("DORA_WINDOWS")
+		windows, err := dora.ParseWindows(doraWindows)
+		if err != nil {
+			return fmt.Errorf("invalid DORA_WINDOWS \"%s\", reason %v", doraWindows, err)
+		}
+		tracker := dora.Start(windows)
+		if doraSummaryInterval != "" {
+			err = scheduler.StartDoraSummaries(doraSummaryInterval, tracker)
+			if err != nil {
+				return fmt.Errorf("invalid DORA_SUMMARY_INTERVAL \"%s\", reason %v", doraSummaryInterval, err)
+			}
+		}
+		if metricsListenAddr != "" {
+			go func() {
+				err := dora.ServeMetrics(metricsListenAddr, tracker)
+				logger.GetLogger().Errorf("Cant serve prometheus metrics because %v", err.Error())
+			}()
+		}
+		diagnostics.EnableFeature("dora")
+	}
+
+	recordPath, recordExistence := os.LookupEnv("RECORD")
+	if recordExistence && recordPath != "" {
+		codefreshConfig := store.GetStore().Codefresh
+		err = replay.StartRecording(recordPath, replay.Config{
+			Integration:         codefreshConfig.Integration,
+			SyncMode:            codefreshConfig.SyncMode,
+			ArgoNamespace:       store.GetStore().Argo.Namespace,
+			ApplicationsForSync: codefreshConfig.ApplicationsForSync,
+			SyncRules:           codefreshConfig.SyncRules,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start recording to \"%s\", reason %v", recordPath, err)
+		}
+		logger.GetLogger().Warnf("Recording informer events and responses of argocd and github to \"%s\", archive contains application manifests and isn't rotated", recordPath)
+		diagnostics.EnableFeature("record")
+	}
+
+	return nil
+}
+
+func run() {
+	err := configureLogger()
+	if err != nil {
+		panic(err)
+	}
+
+	err = configure()
+	if err != nil {
+		panic(err)
+	}
+
+	err = configureArgo()
+	if err != nil {
+		// send heartbeat to codefresh before die
+		heartbeat.SendFatalError(diagnostics.ArgoSubsystem, err)
+		panic(err)
+	}
+
+	err = initServices()
+	if err != nil {
+		panic(err)
+	}
+
+	sources, err := configureEventSources()
+	if err != nil {
+		panic(err)
+	}
+
+	scheduler.StartHeartBeat()
+	scheduler.StartEnvInitializer()
+
+	reconcileConfig := store.GetStore().Reconcile
+	if reconcileConfig.Interval != "" && reconcileConfig.Interval != "0" {
+		logger.GetLogger().Infof("Start reconciliation every %s, repair: %v", reconcileConfig.Interval, strings.Join(reconcileConfig.Repair, ","))
+		err = scheduler.StartReconciliation(reconcileConfig.Interval)
+		if err != nil {
+			panic(fmt.Errorf("invalid RECONCILE_INTERVAL \"%s\", reason %v", reconcileConfig.Interval, err))
+		}
+		diagnostics.EnableFeature("reconcile")
+		if len(reconcileConfig.Repair) > 0 {
+			diagnostics.EnableFeature("reconcile-repair")
+		}
+	}
+
+	commandsConfig := store.GetStore().Commands
+	if commandsConfig.Secret != "" && len(commandsConfig.Allowed) > 0 {
+		logger.GetLogger().Infof("Start polling codefresh commands, allowed commands: %v", strings.Join(commandsConfig.Allowed, ","))
+		scheduler.StartCommandsPoller()
+		diagnostics.EnableFeature("commands")
+	}
+
+	err = handler.GetSyncHandlerInstance(codefresh2.GetInstance(), argo.GetInstance()).Handle()
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to run sync handler, reason %v", err)
+	}
+
+	codefreshConfig := store.GetStore().Codefresh
+	if codefreshConfig.Gzip {
+		diagnostics.EnableFeature("gzip")
+	}
+	if codefreshConfig.Chunking {
+		diagnostics.EnableFeature("chunking")
+	}
+	if codefreshConfig.BatchInterval > 0 {
+		logger.GetLogger().Infof("Send environment updates to codefresh in batches every %v", codefreshConfig.BatchInterval)
+		dispatcher := sink.GetDispatcherInstance()
+		codefresh2.StartBatching(codefreshConfig.BatchInterval, dispatcher.Delivered, func(environment codefresh2.Environment) {
+			dispatcher.Dropped(environment)
+			queue.ForgetEnvironment(environment.Name)
+		})
+		diagnostics.EnableFeature("batching")
+	}
+
+	queueProcessor := queue.EnvQueueProcessor{}
+	go queueProcessor.Run()
+
+	diagnostics.RegisterCounter("timelines", transform.KnownTimelines)
+
+	// environments are sent again, when sync windows of their applications open or close
+	transform.SetSyncWindowsRefresh(extract.RefreshApplication)
+
+	if sources.notifications != nil {
+		diagnostics.EnableFeature("notifications")
+		if !sources.informer && !sources.stream {
+			err = extract.ServeNotifications(*sources.notifications)
+			logger.GetLogger().Errorf("Cant receive argocd notifications because %v", err.Error())
+			panic(err)
+		}
+		go func() {
+			err := extract.ServeNotifications(*sources.notifications)
+			logger.GetLogger().Errorf("Cant receive argocd notifications because %v", err.Error())
+		}()
+	}
+
+	if sources.stream {
+		diagnostics.EnableFeature("stream")
+		err = extract.WatchStream()
+		logger.GetLogger().Errorf("Cant run agent because %v", err.Error())
+		panic(err)
+	}
+
+	err = extract.Watch()
+	if err != nil {
+		logger.GetLogger().Errorf("Cant run agent because %v", err.Error())
+		heartbeat.SendFatalError(diagnostics.InformerSubsystem, err)
+		panic(err)
+	}
+
+}
+
+// eventSources says how agent learns about changes of applications
+type eventSources struct {
+	informer bool
+	stream   bool
+	// notifications is nil when notifications of argocd aren't received
+	notifications *extract.NotificationsConfig
+}
+
+// configureEventSources reads comma separated list of sources, informer and stream can't be used together,
+// because every change would be reported twice
+func configureEventSources() (*eventSources, error) {
+	eventSource, _ := os.LookupEnv("EVENT_SOURCE")
+	if eventSource == "" {
+		eventSource = "informer"
+	}
+
+	sources := &eventSources{}
+	for _, source := range strings.Split(eventSource, ",") {
+		switch strings.TrimSpace(source) {
+		case "all":
+			// shorthand for informer,notifications
+			sources.informer = true
+			sources.notifications = &extract.NotificationsConfig{}
+		case "informer":
+			sources.informer = true
+		case "stream":
+			sources.stream = true
+		case "notifications":
+			sources.notifications = &extract.NotificationsConfig{}
+		default:
+			return nil, fmt.Errorf("unknown EVENT_SOURCE \"%s\", should be comma separated list of informer, stream and notifications", source)
+		}
+	}
+	if sources.informer && sources.stream {
+		return nil, errors.New("EVENT_SOURCE can't contain both informer and stream")
+	}
+	if sources.notifications == nil {
+		return sources, nil
+	}
+
+	token, _ := os.LookupEnv("NOTIFICATIONS_TOKEN")
+	if token == "" {
+		return nil, errors.New("NOTIFICATIONS_TOKEN variable is required for receiving argocd notifications")
+	}
+	listenAddr, _ := os.LookupEnv("NOTIFICATIONS_LISTEN_ADDR")
+	if listenAddr == "" {
+		listenAddr = ":8090"
+	}
+	tlsCert, _ := os.LookupEnv("NOTIFICATIONS_TLS_CERT")
+	tlsKey, _ := os.LookupEnv("NOTIFICATIONS_TLS_KEY")
+	if (tlsCert == "") != (tlsKey == "") {
+		return nil, errors.New("NOTIFICATIONS_TLS_CERT and NOTIFICATIONS_TLS_KEY should be set together")
+	}
+
+	sources.notifications = &extract.NotificationsConfig{
+		ListenAddr: listenAddr,
+		Token:      token,
+		TLSCert:    tlsCert,
+		TLSKey:     tlsKey,
+	}
+	return sources, nil
+}
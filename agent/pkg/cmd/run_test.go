@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConfigureEventSources(t *testing.T) {
+	defer os.Unsetenv("EVENT_SOURCE")
+	defer os.Unsetenv("NOTIFICATIONS_TOKEN")
+	_ = os.Setenv("NOTIFICATIONS_TOKEN", "secret")
+
+	cases := []struct {
+		value         string
+		informer      bool
+		stream        bool
+		notifications bool
+	}{
+		{"", true, false, false},
+		{"stream", false, true, false},
+		{"informer,notifications", true, false, true},
+		{"all", true, false, true},
+		{"stream, notifications", false, true, true},
+	}
+
+	for _, c := range cases {
+		_ = os.Setenv("EVENT_SOURCE", c.value)
+		sources, err := configureEventSources()
+		if err != nil {
+			t.Errorf("'configureEventSources' failed for '%v', unexpected error '%v'", c.value, err)
+			continue
+		}
+		if sources.informer != c.informer || sources.stream != c.stream || (sources.notifications != nil) != c.notifications {
+			t.Errorf("'configureEventSources' failed for '%v', unexpected sources '%+v'", c.value, sources)
+		}
+	}
+
+	for _, value := range []string{"informer,stream", "all,stream", "kafka"} {
+		_ = os.Setenv("EVENT_SOURCE", value)
+		if _, err := configureEventSources(); err == nil {
+			t.Errorf("'configureEventSources' failed, expected error for '%v'", value)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"runtime"
+)
+
+// Version is set on build with -ldflags "-X github.com/codefresh-io/argocd-listener/agent/pkg/cmd.Version=..."
+var Version = "dev"
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version of agent",
+	Run: func(cmd *cobra.Command, args []string) {
+		version := Version
+		if agentVersion, ok := os.LookupEnv("AGENT_VERSION"); ok && agentVersion != "" {
+			version = agentVersion
+		}
+		fmt.Printf("Version: %s\nGo version: %s\n", version, runtime.Version())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
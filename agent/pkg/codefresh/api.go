@@ -2,14 +2,24 @@ package codefresh
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dryrun"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/httpclient"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/redact"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
 	"github.com/guregu/null"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 type Api struct {
@@ -22,6 +32,17 @@ type CodefreshApi interface {
 	CreateEnvironment(name string, project string, application string) error
 }
 
+type EnvironmentsApi interface {
+	GetEnvironments() ([]CFEnvironment, error)
+	CreateEnvironment(name string, project string, application string) error
+	DeleteEnvironment(name string) error
+}
+
+type CommandsApi interface {
+	GetCommands() ([]Command, error)
+	SendCommandResult(result CommandResult) error
+}
+
 var api *Api
 
 func GetInstance() *Api {
@@ -78,9 +99,10 @@ func (a *Api) GetGitContextByName(name string) (error, *ContextPayload) {
 	}
 
 	err := a.requestAPI(&requestOptions{
-		method: "GET",
-		path:   "/contexts/" + name,
-		qs:     qs,
+		method:    "GET",
+		path:      "/contexts/" + name,
+		qs:        qs,
+		operation: "get-git-context",
 	}, &result)
 	if err != nil {
 		return err, nil
@@ -89,19 +111,39 @@ func (a *Api) GetGitContextByName(name string) (error, *ContextPayload) {
 	return nil, &result
 }
 
-func (a *Api) SendEnvironment(environment Environment) (map[string]interface{}, error) {
-
-	logger.GetLogger().Infof("Successfully sent environment \"%v\" update to codefresh, services count %v", environment.Name, len(environment.Activities))
+func (a *Api) SendEnvironment(ctx context.Context, environment Environment) (map[string]interface{}, error) {
 
 	var result map[string]interface{}
-	err := a.requestAPI(&requestOptions{method: "POST", path: "/environments-v2/argo/events", body: environment}, &result)
+	err := a.requestAPI(&requestOptions{method: "POST", path: "/environments-v2/argo/events", body: environment, ctx: ctx}, &result)
 	if err != nil {
 		return nil, err
 	}
 
+	logger.GetLogger().WithFields(logger.Fields{
+		logger.AppField:      environment.Name,
+		logger.RevisionField: environment.SyncRevision,
+	}).Infof("Successfully sent environment \"%v\" update to codefresh, services count %v", environment.Name, len(environment.Activities))
+
 	return result, nil
 }
 
+// SendEnvironments sends several environment updates with single request
+func (a *Api) SendEnvironments(ctx context.Context, environments []Environment) error {
+	err := a.requestAPI(&requestOptions{
+		method: "POST",
+		path:   "/environments-v2/argo/events/batch",
+		body:   &EnvironmentsBatch{Environments: environments},
+		ctx:    ctx,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	logger.GetLogger().Infof("Successfully sent batch of %v environment updates to codefresh", len(environments))
+
+	return nil
+}
+
 func (a *Api) SendResources(kind string, items interface{}, amount int) error {
 	if items == nil {
 		return nil
@@ -109,20 +151,54 @@ func (a *Api) SendResources(kind string, items interface{}, amount int) error {
 
 	logger.GetLogger().Infof("Trying sent resources with type: \"%s\" to codefresh, amount: \"%v\"", kind, amount)
 
-	err := a.requestAPI(&requestOptions{
-		method: "POST",
-		path:   fmt.Sprintf("/argo-agent/%s", a.Integration),
-		body:   &AgentState{Kind: kind, Items: items},
-	}, nil)
+	// resources, like helm values of applications, can contain secrets too
+	var redacted interface{}
+	_, err := redact.GetInstance().Redact(items, &redacted)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to redact resources with type \"%s\", reason %w", kind, err)
 	}
+	items = redacted
 
-	logger.GetLogger().Infof("Successfully sent type: \"%s\" to codefresh", kind)
+	// codefresh, that doesn't reassemble chunks, would keep only last one, so chunking is enabled explicitly
+	var chunks [][]json.RawMessage
+	if store.GetStore().Codefresh.Chunking {
+		chunks, err = chunkItems(items, maxPayloadSize())
+		if err != nil {
+			return err
+		}
+	}
+
+	if chunks == nil {
+		err = a.sendAgentState(&AgentState{Kind: kind, Items: items})
+		if err != nil {
+			return err
+		}
+		logger.GetLogger().Infof("Successfully sent type: \"%s\" to codefresh", kind)
+		return nil
+	}
+
+	snapshotId := newRequestId()
+	for i, chunk := range chunks {
+		err = a.sendAgentState(&AgentState{Kind: kind, Items: chunk, SnapshotId: snapshotId, Chunk: i + 1, Chunks: len(chunks)})
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.GetLogger().Infof("Successfully sent type: \"%s\" to codefresh in %v chunks, snapshot %s", kind, len(chunks), snapshotId)
 
 	return nil
 }
 
+func (a *Api) sendAgentState(state *AgentState) error {
+	return a.requestAPI(&requestOptions{
+		method:    "POST",
+		path:      fmt.Sprintf("/argo-agent/%s", a.Integration),
+		body:      state,
+		operation: "send-" + state.Kind,
+	}, nil)
+}
+
 func (a *Api) SendEvent(name string, props map[string]string) error {
 	event := CodefreshEvent{Event: name, Props: props}
 
@@ -138,13 +214,8 @@ func (a *Api) SendEvent(name string, props map[string]string) error {
 	return nil
 }
 
-func (a *Api) HeartBeat(error string) error {
+func (a *Api) HeartBeat(body Heartbeat) error {
 	agentConfig := store.GetStore().Agent
-	var body = Heartbeat{}
-
-	if error != "" {
-		body.Error = error
-	}
 
 	if agentConfig.Version != "" {
 		body.AgentVersion = agentConfig.Version
@@ -162,6 +233,42 @@ func (a *Api) HeartBeat(error string) error {
 	return nil
 }
 
+// SendDoraSummary sends DORA metrics computed by agent
+func (a *Api) SendDoraSummary(summary DoraSummary) error {
+	return a.requestAPI(&requestOptions{
+		method: "POST",
+		path:   fmt.Sprintf("/argo-agent/%s/dora", a.Integration),
+		body:   summary,
+	}, nil)
+}
+
+func (a *Api) GetCommands() ([]Command, error) {
+	var result []Command
+	err := a.requestAPI(&requestOptions{
+		method: "GET",
+		path:   fmt.Sprintf("/argo-agent/%s/commands", a.Integration),
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (a *Api) SendCommandResult(result CommandResult) error {
+	err := a.requestAPI(&requestOptions{
+		method:    "POST",
+		path:      fmt.Sprintf("/argo-agent/%s/commands/%s/result", a.Integration, result.Id),
+		body:      result,
+		operation: "send-command-result",
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (a *Api) GetEnvironments() ([]CFEnvironment, error) {
 	var result MongoCFEnvWrapper
 	err := a.requestAPI(&requestOptions{
@@ -272,6 +379,17 @@ func (a *Api) DeleteIntegrationByName(name string) error {
 
 func (a *Api) requestAPI(opt *requestOptions, target interface{}) error {
 
+	if recorder := dryrun.GetRecorder(); recorder != nil {
+		// dry run mode, nothing is sent and responses are empty
+		return recorder.Record(opt.method, opt.path, opt.qs, opt.body)
+	}
+
+	// success of request clears only errors of same operation, failed environment update stays visible after heartbeat
+	operation := opt.operation
+	if operation == "" {
+		operation = opt.method + " " + opt.path
+	}
+
 	var body []byte
 	finalURL := fmt.Sprintf("%s%s", a.Host+"/api", opt.path)
 	if opt.qs != nil {
@@ -282,36 +400,62 @@ func (a *Api) requestAPI(opt *requestOptions, target interface{}) error {
 		body, _ = json.Marshal(opt.body)
 	}
 
-	request, err := http.NewRequest(opt.method, finalURL, bytes.NewBuffer(body))
+	ctx := opt.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	body, contentEncoding, err := compress(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, opt.method, finalURL, bytes.NewBuffer(body))
 
 	if err != nil {
 		return err
 	}
 
+	if contentEncoding != "" {
+		request.Header.Set("Content-Encoding", contentEncoding)
+	}
+
+	requestId := newRequestId()
+	log := logger.GetLogger().WithField(logger.RequestIdField, requestId)
+
 	request.Header.Set("Authorization", "Bearer "+a.Token)
 	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Request-ID", requestId)
 
-	response, err := a.buildHttpClient().Do(request)
+	log.Debugf("Send request %s %s", opt.method, opt.path)
+
+	response, err := a.getHttpClient().Do(request)
 
 	if err != nil {
-		return err
+		log.Warnf("Request %s %s failed, reason %v", opt.method, opt.path, err)
+		apiErr := apierrors.FromNetwork(apierrors.CodefreshBackend, request, err)
+		diagnostics.ReportError(diagnostics.CodefreshSubsystem, operation, apiErr)
+		return apiErr
 	}
 
 	defer response.Body.Close()
 
+	log.Debugf("Request %s %s finished with status %v", opt.method, opt.path, response.StatusCode)
+
 	if response.StatusCode < 200 || response.StatusCode > 299 {
+		// body of proxy or load balancer isn't codefresh error, then only status is known
 		cfError := &CodefreshError{}
-		err = json.NewDecoder(response.Body).Decode(cfError)
+		_ = json.NewDecoder(io.LimitReader(response.Body, 64*1024)).Decode(cfError)
 
-		if err != nil {
-			return err
-		}
+		apiErr := apierrors.FromResponse(apierrors.CodefreshBackend, response, cfError.Message)
+		apiErr.Code = cfError.Code
+		diagnostics.ReportError(diagnostics.CodefreshSubsystem, operation, apiErr)
 
-		cfError.URL = finalURL
-
-		return cfError
+		return apiErr
 	}
 
+	diagnostics.ReportError(diagnostics.CodefreshSubsystem, operation, nil)
+
 	if target == nil {
 		return nil
 	}
@@ -325,6 +469,12 @@ func (a *Api) requestAPI(opt *requestOptions, target interface{}) error {
 	return nil
 }
 
+func newRequestId() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
 func (a *Api) getQs(qs map[string]string) string {
 	var arr []string
 	for k, v := range qs {
@@ -361,8 +511,9 @@ func (a *Api) CreateEnvironment(name string, project string, application string)
 
 func (a *Api) DeleteEnvironment(name string) error {
 	err := a.requestAPI(&requestOptions{
-		method: "DELETE",
-		path:   fmt.Sprintf("/environments-v2/%s", name),
+		method:    "DELETE",
+		path:      fmt.Sprintf("/environments-v2/%s", name),
+		operation: "delete-environment",
 	}, nil)
 	if err != nil {
 		return err
@@ -371,9 +522,20 @@ func (a *Api) DeleteEnvironment(name string) error {
 	return nil
 }
 
-func (a *Api) buildHttpClient() *http.Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	return &http.Client{Transport: tr}
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+)
+
+// getHttpClient returns client shared by all requests to codefresh, so connections are reused
+func (a *Api) getHttpClient() *http.Client {
+	httpClientOnce.Do(func() {
+		config := httpclient.DefaultConfig()
+		config.InsecureSkipVerify = true
+		// traceparent header is added by transport, so codefresh side can continue trace of event
+		httpClient = httpclient.New(config, func(tr http.RoundTripper) http.RoundTripper {
+			return tracing.NewTransport(tr)
+		})
+	})
+	return httpClient
 }
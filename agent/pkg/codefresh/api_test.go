@@ -0,0 +1,45 @@
+package codefresh
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestErrorsAreKeptPerOperation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/events") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	api := &Api{Host: server.URL, Integration: "argocd"}
+	_, err := api.SendEnvironment(context.Background(), Environment{Name: "app"})
+	if err == nil {
+		t.Errorf("'SendEnvironment' failed, expected error")
+	}
+
+	err = api.HeartBeat(Heartbeat{})
+	if err != nil {
+		t.Errorf("'HeartBeat' failed, reason %v", err)
+	}
+
+	reported, ok := diagnostics.Errors()[diagnostics.CodefreshSubsystem]
+	if !ok || !strings.Contains(reported.Source, "/events") {
+		t.Errorf("'requestAPI' failed, expected error of environment update to stay after heartbeat, got '%v'", diagnostics.Errors())
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	})
+	_, _ = api.SendEnvironment(context.Background(), Environment{Name: "app"})
+	if _, ok := diagnostics.Errors()[diagnostics.CodefreshSubsystem]; ok {
+		t.Errorf("'requestAPI' failed, expected error to be cleared by success of same operation, got '%v'", diagnostics.Errors())
+	}
+}
@@ -0,0 +1,203 @@
+package codefresh
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"sync"
+	"time"
+)
+
+// batchMaxAttempts limits how many times environment is returned to batch after failed delivery
+const batchMaxAttempts = 5
+
+type batchItem struct {
+	environment Environment
+	size        int
+	attempts    int
+}
+
+// Batcher collects environment updates and sends them with single request, when batch is old or big enough.
+// Only latest update of environment is kept in batch
+type Batcher struct {
+	send     func(ctx context.Context, environments []Environment) error
+	interval time.Duration
+	maxSize  int
+	// OnSent is called with every environment of batch, that codefresh accepted
+	OnSent func(environment Environment)
+	// OnDrop is called with environment, that wasn't delivered to codefresh
+	OnDrop func(environment Environment)
+
+	lock  sync.Mutex
+	items []*batchItem
+	size  int
+	full  chan struct{}
+}
+
+var batcher *Batcher
+
+// NewBatcher creates batcher, that sends batches with send, it doesn't flush until Run is called
+func NewBatcher(send func(ctx context.Context, environments []Environment) error, interval time.Duration, maxSize int) *Batcher {
+	return &Batcher{
+		send:     send,
+		interval: interval,
+		maxSize:  maxSize,
+		full:     make(chan struct{}, 1),
+	}
+}
+
+// StartBatching enables batching of environment updates, that are sent to codefresh
+func StartBatching(interval time.Duration, onSent func(environment Environment), onDrop func(environment Environment)) *Batcher {
+	batcher = NewBatcher(GetInstance().SendEnvironments, interval, maxPayloadSize())
+	batcher.OnSent = onSent
+	batcher.OnDrop = onDrop
+	diagnostics.RegisterCounter("batchedEnvironments", batcher.Len)
+	go batcher.Run(nil)
+	return batcher
+}
+
+// GetBatcher returns batcher of environment updates, it's nil when batching is disabled
+func GetBatcher() *Batcher {
+	return batcher
+}
+
+// Add puts environment to batch, it replaces previous update of same environment
+func (b *Batcher) Add(environment Environment) {
+	data, _ := json.Marshal(environment)
+	item := &batchItem{environment: environment, size: len(data)}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.put(item)
+	if b.size >= b.maxSize {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *Batcher) put(item *batchItem) {
+	for i, existing := range b.items {
+		if existing.environment.Name == item.environment.Name {
+			b.size += item.size - existing.size
+			b.items[i] = item
+			return
+		}
+	}
+	b.items = append(b.items, item)
+	b.size += item.size
+}
+
+func (b *Batcher) contains(name string) bool {
+	for _, item := range b.items {
+		if item.environment.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns amount of environments, that wait for sending
+func (b *Batcher) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.items)
+}
+
+func (b *Batcher) take() []*batchItem {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	items := b.items
+	b.items = nil
+	b.size = 0
+	return items
+}
+
+// restore returns environments of failed batch, unless they were updated meanwhile
+func (b *Batcher) restore(items []*batchItem) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, item := range items {
+		if b.contains(item.environment.Name) {
+			continue
+		}
+		item.attempts++
+		if item.attempts >= batchMaxAttempts {
+			b.drop(item.environment)
+			continue
+		}
+		b.put(item)
+	}
+}
+
+func (b *Batcher) drop(environment Environment) {
+	logger.GetLogger().WithField(logger.AppField, environment.Name).Errorf("Drop update of environment \"%s\", it wasn't sent to codefresh", environment.Name)
+	if b.OnDrop != nil {
+		b.OnDrop(environment)
+	}
+}
+
+// Flush sends all collected environments, environments of batch, that failed with temporary error, are sent with next batch
+func (b *Batcher) Flush() error {
+	items := b.take()
+	if len(items) == 0 {
+		return nil
+	}
+
+	environments := make([]Environment, 0, len(items))
+	for _, item := range items {
+		environments = append(environments, item.environment)
+	}
+
+	ctx, span := tracing.Start(context.Background(), "codefresh.SendEnvironments", tracing.KindInternal)
+	span.SetAttribute("batch.size", len(environments))
+	err := b.send(ctx, environments)
+	span.RecordError(err)
+	span.End()
+
+	if err == nil {
+		diagnostics.MarkEventSent()
+		if b.OnSent != nil {
+			for _, environment := range environments {
+				b.OnSent(environment)
+			}
+		}
+		return nil
+	}
+
+	if apierrors.IsRetryable(err) {
+		b.restore(items)
+		return err
+	}
+
+	for _, item := range items {
+		b.drop(item.environment)
+	}
+	return err
+}
+
+// Run flushes batch every interval and when it's full, remaining environments are flushed when stop is closed
+func (b *Batcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.full:
+		case <-stop:
+			if err := b.Flush(); err != nil {
+				logger.GetLogger().Errorf("Failed to send environments batch to codefresh, reason %v", err)
+			}
+			return
+		}
+		if err := b.Flush(); err != nil {
+			logger.GetLogger().Errorf("Failed to send environments batch to codefresh, reason %v", err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package codefresh
+
+const (
+	SyncCommand               = "sync"
+	HardRefreshCommand        = "hard-refresh"
+	RollbackCommand           = "rollback"
+	TerminateOperationCommand = "terminate-operation"
+)
+
+const (
+	CommandSucceeded = "succeeded"
+	CommandFailed    = "failed"
+	CommandRejected  = "rejected"
+)
@@ -1,7 +1,8 @@
 package codefresh
 
 import (
-	"fmt"
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/git"
 	"github.com/guregu/null"
 )
@@ -16,6 +17,8 @@ type CFEnvironment struct {
 	} `json:"metadata"`
 	Spec struct {
 		Type        string `json:"type"`
+		Context     string `json:"context"`
+		Project     string `json:"project"`
 		Application string `json:"application"`
 	} `json:"spec"`
 }
@@ -23,6 +26,8 @@ type CFEnvironment struct {
 type Commit struct {
 	Message *string `json:"message"`
 	Avatar  *string `json:"avatar"`
+	// Date is time of commit in RFC3339, it's used for lead time of changes
+	Date *string `json:"date,omitempty"`
 }
 
 type SyncPolicy struct {
@@ -30,19 +35,93 @@ type SyncPolicy struct {
 }
 
 type Environment struct {
-	Gitops       git.Gitops            `json:"gitops"`
-	FinishedAt   string                `json:"finishedAt"`
-	HealthStatus string                `json:"healthStatus"`
-	SyncStatus   string                `json:"status"`
-	HistoryId    int64                 `json:"historyId"`
-	SyncRevision string                `json:"revision"`
-	Name         string                `json:"name"`
-	Activities   []EnvironmentActivity `json:"activities"`
-	Resources    interface{}           `json:"resources"`
-	RepoUrl      string                `json:"repoUrl"`
-	Commit       Commit                `json:"commit"`
-	SyncPolicy   SyncPolicy            `json:"syncPolicy"`
-	Date         string                `json:"date"`
+	Gitops         git.Gitops            `json:"gitops"`
+	FinishedAt     string                `json:"finishedAt"`
+	HealthStatus   string                `json:"healthStatus"`
+	SyncStatus     string                `json:"status"`
+	HistoryId      int64                 `json:"historyId"`
+	SyncRevision   string                `json:"revision"`
+	Name           string                `json:"name"`
+	Activities     []EnvironmentActivity `json:"activities"`
+	Resources      interface{}           `json:"resources"`
+	RepoUrl        string                `json:"repoUrl"`
+	Commit         Commit                `json:"commit"`
+	SyncPolicy     SyncPolicy            `json:"syncPolicy"`
+	Date           string                `json:"date"`
+	ApplicationSet string                `json:"applicationSet"`
+	Operation      EnvironmentOperation  `json:"operation"`
+	Timeline       EnvironmentTimeline   `json:"timeline"`
+	// SyncWindows is nil, when sync windows of project couldn't be evaluated
+	SyncWindows *EnvironmentSyncWindows `json:"syncWindows,omitempty"`
+}
+
+// EnvironmentSyncWindows is deploy freeze status of application given by sync windows of its project
+type EnvironmentSyncWindows struct {
+	// Windows are windows of project, that match application
+	Windows           []SyncWindow `json:"windows"`
+	Active            []SyncWindow `json:"active"`
+	SyncAllowed       bool         `json:"syncAllowed"`
+	ManualSyncAllowed bool         `json:"manualSyncAllowed"`
+	// NextSyncAt is when automated sync is allowed again, it's empty when sync is allowed now
+	NextSyncAt string `json:"nextSyncAt,omitempty"`
+	// OperationBlocked is set when argocd didn't start last operation because of sync window
+	OperationBlocked bool `json:"operationBlocked"`
+}
+
+// SyncWindow is sync window of project, StartedAt and EndsAt are set for active windows
+type SyncWindow struct {
+	Kind         string   `json:"kind"`
+	Schedule     string   `json:"schedule"`
+	Duration     string   `json:"duration"`
+	TimeZone     string   `json:"timeZone,omitempty"`
+	ManualSync   bool     `json:"manualSync"`
+	Applications []string `json:"applications,omitempty"`
+	Namespaces   []string `json:"namespaces,omitempty"`
+	Clusters     []string `json:"clusters,omitempty"`
+	StartedAt    string   `json:"startedAt,omitempty"`
+	EndsAt       string   `json:"endsAt,omitempty"`
+}
+
+// EnvironmentTimeline describes duration and direction of last sync, durations are in seconds.
+// TimeToHealthy is known only when HealthyAt is set
+type EnvironmentTimeline struct {
+	SyncStartedAt  string `json:"syncStartedAt"`
+	SyncFinishedAt string `json:"syncFinishedAt"`
+	SyncDuration   int64  `json:"syncDuration"`
+	HealthyAt      string `json:"healthyAt,omitempty"`
+	TimeToHealthy  int64  `json:"timeToHealthy"`
+	// Rollback is set when application was synced back to revision of older history item
+	Rollback         bool   `json:"rollback"`
+	PreviousRevision string `json:"previousRevision,omitempty"`
+}
+
+type OperationInitiator struct {
+	Username  string `json:"username"`
+	Automated bool   `json:"automated"`
+}
+
+type EnvironmentResourceResult struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	HookType  string `json:"hookType"`
+	HookPhase string `json:"hookPhase"`
+	SyncPhase string `json:"syncPhase"`
+}
+
+// EnvironmentOperation describes last sync operation of application
+type EnvironmentOperation struct {
+	Phase       string                      `json:"phase"`
+	Message     string                      `json:"message"`
+	StartedAt   string                      `json:"startedAt"`
+	FinishedAt  string                      `json:"finishedAt"`
+	RetryCount  int64                       `json:"retryCount"`
+	InitiatedBy OperationInitiator          `json:"initiatedBy"`
+	Resources   []EnvironmentResourceResult `json:"resources"`
 }
 
 type EnvironmentActivity struct {
@@ -63,13 +142,13 @@ type EnvironmentActivityRS struct {
 	To   ReplicaState `json:"to"`
 }
 
+// CodefreshError is body of error response of codefresh api, api methods return it as apierrors.Error
 type CodefreshError struct {
 	Status  int         `json:"status"`
 	Code    string      `json:"code"`
 	Name    string      `json:"name"`
 	Message string      `json:"message"`
 	Context interface{} `json:"context"`
-	URL     string
 }
 
 type CodefreshEvent struct {
@@ -77,16 +156,40 @@ type CodefreshEvent struct {
 	Props map[string]string `json:"props"`
 }
 
-func (e *CodefreshError) Error() string {
-	return fmt.Sprintf("Request failed to %s, %s - %s", e.URL, e.Code, e.Message)
+type AgentApplication struct {
+	Name           string `json:"name"`
+	AppNamespace   string `json:"appNamespace"`
+	UID            string `json:"uid"`
+	Project        string `json:"project"`
+	Namespace      string `json:"namespace"`
+	Server         string `json:"server"`
+	ApplicationSet string `json:"applicationSet"`
 }
 
-type AgentApplication struct {
-	Name      string `json:"name"`
-	UID       string `json:"uid"`
-	Project   string `json:"project"`
-	Namespace string `json:"namespace"`
-	Server    string `json:"server"`
+type AgentApplicationSetTemplate struct {
+	Project        string `json:"project"`
+	RepoUrl        string `json:"repoUrl"`
+	Path           string `json:"path"`
+	Chart          string `json:"chart"`
+	TargetRevision string `json:"targetRevision"`
+}
+
+type AgentApplicationSetCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+}
+
+type AgentApplicationSet struct {
+	Name           string                         `json:"name"`
+	UID            string                         `json:"uid"`
+	Namespace      string                         `json:"namespace"`
+	GeneratorTypes []string                       `json:"generatorTypes"`
+	Generators     []map[string]interface{}       `json:"generators"`
+	Template       AgentApplicationSetTemplate    `json:"template"`
+	Conditions     []AgentApplicationSetCondition `json:"conditions"`
 }
 
 type AgentProject struct {
@@ -97,6 +200,15 @@ type AgentProject struct {
 type AgentState struct {
 	Kind  string      `json:"type"`
 	Items interface{} `json:"items"`
+	// SnapshotId, Chunk and Chunks are set when list is sent in several requests, codefresh reassembles it by snapshot id
+	SnapshotId string `json:"snapshotId,omitempty"`
+	Chunk      int    `json:"chunk,omitempty"`
+	Chunks     int    `json:"chunks,omitempty"`
+}
+
+// EnvironmentsBatch is body of request with several environment updates
+type EnvironmentsBatch struct {
+	Environments []Environment `json:"environments"`
 }
 
 type IntegrationPayloadData struct {
@@ -131,8 +243,61 @@ type EnvironmentPayload struct {
 }
 
 type Heartbeat struct {
-	Error        string `json:"error"`
-	AgentVersion string `json:"agentVersion"`
+	// Error is summary of current errors, kept for older codefresh versions
+	Error             string                                `json:"error"`
+	AgentVersion      string                                `json:"agentVersion"`
+	ArgoVersion       string                                `json:"argoVersion,omitempty"`
+	KubernetesVersion string                                `json:"kubernetesVersion,omitempty"`
+	Applications      int                                   `json:"applications"`
+	Projects          int                                   `json:"projects"`
+	QueueDepth        int                                   `json:"queueDepth"`
+	OutboxBacklog     int                                   `json:"outboxBacklog"`
+	LastEventAt       string                                `json:"lastEventAt,omitempty"`
+	Errors            map[string]diagnostics.SubsystemError `json:"errors"`
+	SyncMode          string                                `json:"syncMode"`
+	Features          []string                              `json:"features"`
+	// Redactions are amounts of values redacted by every rule since start of agent
+	Redactions map[string]int `json:"redactions,omitempty"`
+}
+
+// DoraMetrics are DORA metrics of application or project over rolling window, durations are in seconds
+type DoraMetrics struct {
+	Window            string `json:"window"`
+	Deployments       int    `json:"deployments"`
+	FailedDeployments int    `json:"failedDeployments"`
+	// DeploymentFrequency is amount of deployments per day
+	DeploymentFrequency float64 `json:"deploymentFrequency"`
+	// LeadTime is median time from commit to deployment, it's zero when commits times are unknown
+	LeadTime          float64 `json:"leadTime"`
+	ChangeFailureRate float64 `json:"changeFailureRate"`
+	// Incidents are resolved degradations, MTTR is their mean duration
+	Incidents int     `json:"incidents"`
+	MTTR      float64 `json:"mttr"`
+}
+
+// DoraSummary is periodic report of DORA metrics of all applications and projects
+type DoraSummary struct {
+	Time         string                   `json:"time"`
+	Applications map[string][]DoraMetrics `json:"applications"`
+	Projects     map[string][]DoraMetrics `json:"projects"`
+}
+
+// Command is action on argocd application requested from codefresh
+type Command struct {
+	Id          string `json:"id"`
+	Type        string `json:"type"`
+	Application string `json:"application"`
+	HistoryId   int64  `json:"historyId"`
+	IssuedBy    string `json:"issuedBy"`
+	ExpiresAt   string `json:"expiresAt"`
+	Signature   string `json:"signature"`
+}
+
+type CommandResult struct {
+	Id         string `json:"id"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	FinishedAt string `json:"finishedAt"`
 }
 
 type requestOptions struct {
@@ -140,6 +305,11 @@ type requestOptions struct {
 	method string
 	body   interface{}
 	qs     map[string]string
+	// ctx carries trace of request, background context is used when empty
+	ctx context.Context
+	// operation is source of errors of request in diagnostics, it's method and path when empty,
+	// so requests with ids in path or with different payloads to same path should set it
+	operation string
 }
 
 type ContextPayload struct {
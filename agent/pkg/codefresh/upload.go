@@ -0,0 +1,78 @@
+package codefresh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+)
+
+// DefaultMaxPayloadSize is used when size of payload isn't configured
+const DefaultMaxPayloadSize = 1024 * 1024
+
+// gzipMinSize skips compression of small bodies, where gzip header costs more than it saves
+const gzipMinSize = 1024
+
+func maxPayloadSize() int {
+	size := store.GetStore().Codefresh.MaxPayloadSize
+	if size <= 0 {
+		return DefaultMaxPayloadSize
+	}
+	return size
+}
+
+// compress returns gzipped body and its content encoding, body is returned as is when compression is disabled or useless
+func compress(body []byte) ([]byte, string, error) {
+	if !store.GetStore().Codefresh.Gzip || len(body) < gzipMinSize {
+		return body, "", nil
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), "gzip", nil
+}
+
+// chunkItems splits json list of items to chunks, that fit to maxSize when serialized,
+// it returns nil when whole list fits, item bigger than maxSize is sent in its own chunk
+func chunkItems(items interface{}, maxSize int) ([][]json.RawMessage, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= maxSize {
+		return nil, nil
+	}
+
+	var rawItems []json.RawMessage
+	err = json.Unmarshal(data, &rawItems)
+	if err != nil {
+		return nil, fmt.Errorf("resources aren't list, reason %v", err)
+	}
+
+	var chunks [][]json.RawMessage
+	var chunk []json.RawMessage
+	// brackets of list
+	size := 2
+	for _, item := range rawItems {
+		// item and comma separator
+		itemSize := len(item) + 1
+		if len(chunk) > 0 && size+itemSize > maxSize {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			size = 2
+		}
+		chunk = append(chunk, item)
+		size += itemSize
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,194 @@
+package codefresh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkItems(t *testing.T) {
+	items := []string{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 40)}
+
+	chunks, err := chunkItems(items, 1000)
+	if err != nil || chunks != nil {
+		t.Errorf("'chunkItems' failed, expected no chunks, got '%v', error '%v'", chunks, err)
+	}
+
+	chunks, err = chunkItems(items, 100)
+	if err != nil {
+		t.Errorf("'chunkItems' failed, reason %v", err)
+		return
+	}
+	if len(chunks) != 2 || len(chunks[0]) != 2 || len(chunks[1]) != 1 {
+		t.Errorf("'chunkItems' failed, expected '%v', got '%v'", "[2 1]", chunks)
+	}
+
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(chunk)
+		if len(data) > 100 {
+			t.Errorf("'chunkItems' failed, chunk size %v is bigger than %v", len(data), 100)
+		}
+	}
+}
+
+func TestChunkItemsRejectsObject(t *testing.T) {
+	_, err := chunkItems(map[string]string{"name": strings.Repeat("a", 100)}, 10)
+	if err == nil {
+		t.Errorf("'chunkItems' failed, expected error for object")
+	}
+}
+
+func TestSendResourcesChunking(t *testing.T) {
+	var states []AgentState
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var state AgentState
+		_ = json.NewDecoder(r.Body).Decode(&state)
+		states = append(states, state)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	defer store.SetCodefreshUploads(false, 0, DefaultMaxPayloadSize, false)
+
+	items := []string{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 40)}
+	api := &Api{Host: server.URL}
+
+	store.SetCodefreshUploads(false, 0, 100, false)
+	err := api.SendResources("applications", items, len(items))
+	if err != nil || len(states) != 1 || states[0].SnapshotId != "" {
+		t.Errorf("'SendResources' failed, expected one request without chunks, got '%v', error '%v'", states, err)
+	}
+
+	states = nil
+	store.SetCodefreshUploads(false, 0, 100, true)
+	err = api.SendResources("applications", items, len(items))
+	if err != nil || len(states) != 2 || states[0].SnapshotId == "" || states[1].Chunks != 2 {
+		t.Errorf("'SendResources' failed, expected two chunks of same snapshot, got '%v', error '%v'", states, err)
+	}
+}
+
+func TestSendResourcesRedacted(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	items := []map[string]interface{}{{
+		"metadata": map[string]interface{}{
+			"name":        "app",
+			"annotations": map[string]interface{}{"kubectl.kubernetes.io/last-applied-configuration": "secret-manifest"},
+		},
+	}}
+	err := (&Api{Host: server.URL}).SendResources("applications", items, len(items))
+	if err != nil || strings.Contains(body, "secret-manifest") || !strings.Contains(body, "\"app\"") {
+		t.Errorf("'SendResources' failed, expected redacted resources, got '%v', error '%v'", body, err)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	store.SetCodefreshUploads(true, 0, DefaultMaxPayloadSize, false)
+	defer store.SetCodefreshUploads(false, 0, DefaultMaxPayloadSize, false)
+
+	small := []byte(`{"name":"app"}`)
+	body, encoding, _ := compress(small)
+	if encoding != "" || !bytes.Equal(body, small) {
+		t.Errorf("'compress' failed, expected small body to stay as is, got encoding '%v'", encoding)
+	}
+
+	big := []byte(strings.Repeat(`{"name":"app"},`, 200))
+	body, encoding, _ = compress(big)
+	if encoding != "gzip" {
+		t.Errorf("'compress' failed, expected '%v', got '%v'", "gzip", encoding)
+		return
+	}
+	reader, _ := gzip.NewReader(bytes.NewReader(body))
+	decompressed, _ := ioutil.ReadAll(reader)
+	if !bytes.Equal(decompressed, big) {
+		t.Errorf("'compress' failed, decompressed body differs from original")
+	}
+}
+
+func TestBatcherKeepsLatestUpdate(t *testing.T) {
+	var sent [][]Environment
+	batcher := NewBatcher(func(ctx context.Context, environments []Environment) error {
+		sent = append(sent, environments)
+		return nil
+	}, time.Minute, DefaultMaxPayloadSize)
+	var delivered []string
+	batcher.OnSent = func(environment Environment) {
+		delivered = append(delivered, environment.Name)
+	}
+
+	batcher.Add(Environment{Name: "app1", SyncRevision: "1"})
+	batcher.Add(Environment{Name: "app2", SyncRevision: "1"})
+	batcher.Add(Environment{Name: "app1", SyncRevision: "2"})
+
+	err := batcher.Flush()
+	if err != nil {
+		t.Errorf("'Flush' failed, reason %v", err)
+	}
+	if len(delivered) != 2 {
+		t.Errorf("'Flush' failed, expected '%v' delivered environments, got '%v'", 2, delivered)
+	}
+	if len(sent) != 1 || len(sent[0]) != 2 {
+		t.Errorf("'Flush' failed, expected one batch of two environments, got '%v'", sent)
+		return
+	}
+	if sent[0][0].SyncRevision != "2" {
+		t.Errorf("'Flush' failed, expected '%v', got '%v'", "2", sent[0][0].SyncRevision)
+	}
+	if batcher.Len() != 0 {
+		t.Errorf("'Flush' failed, expected empty batch, got '%v'", batcher.Len())
+	}
+}
+
+func TestBatcherRetriesTransientErrors(t *testing.T) {
+	var dropped []string
+	batcher := NewBatcher(func(ctx context.Context, environments []Environment) error {
+		return &apierrors.Error{Kind: apierrors.Transient, Err: errors.New("connection refused")}
+	}, time.Minute, DefaultMaxPayloadSize)
+	batcher.OnDrop = func(environment Environment) {
+		dropped = append(dropped, environment.Name)
+	}
+
+	batcher.Add(Environment{Name: "app1"})
+	_ = batcher.Flush()
+	if batcher.Len() != 1 {
+		t.Errorf("'Flush' failed, expected environment to stay in batch, got '%v'", batcher.Len())
+	}
+
+	for i := 0; i < batchMaxAttempts; i++ {
+		_ = batcher.Flush()
+	}
+	if batcher.Len() != 0 || len(dropped) != 1 {
+		t.Errorf("'Flush' failed, expected environment to be dropped, got '%v'", dropped)
+	}
+}
+
+func TestBatcherDropsPermanentErrors(t *testing.T) {
+	var dropped []string
+	batcher := NewBatcher(func(ctx context.Context, environments []Environment) error {
+		return &apierrors.Error{Kind: apierrors.Permanent, StatusCode: 400}
+	}, time.Minute, DefaultMaxPayloadSize)
+	batcher.OnDrop = func(environment Environment) {
+		dropped = append(dropped, environment.Name)
+	}
+
+	batcher.Add(Environment{Name: "app1"})
+	_ = batcher.Flush()
+	if batcher.Len() != 0 || len(dropped) != 1 {
+		t.Errorf("'Flush' failed, expected environment to be dropped, got '%v'", dropped)
+	}
+}
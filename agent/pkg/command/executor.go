@@ -0,0 +1,166 @@
+package command
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// executedItemType is type of state keys, that are remembered till expiration of executed commands,
+// so command isn't executed twice even after restart of agent with persisted state
+const executedItemType = "command"
+
+type Executor struct {
+	codefreshApi codefresh.CommandsApi
+	argoApi      argo.ArgoApi
+	lock         sync.Mutex
+}
+
+var executor *Executor
+
+func GetExecutorInstance(codefreshApi codefresh.CommandsApi, argoApi argo.ArgoApi) *Executor {
+	if executor != nil {
+		return executor
+	}
+	executor = &Executor{
+		codefreshApi: codefreshApi,
+		argoApi:      argoApi,
+	}
+	return executor
+}
+
+// Sign returns signature of command, codefresh signs every command with secret shared with agent
+func Sign(command codefresh.Command, secret string) string {
+	payload := strings.Join([]string{
+		command.Id,
+		command.Type,
+		command.Application,
+		strconv.FormatInt(command.HistoryId, 10),
+		command.IssuedBy,
+		command.ExpiresAt,
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (executor *Executor) verify(command codefresh.Command, now time.Time) error {
+	commandsConfig := store.GetStore().Commands
+
+	if commandsConfig.Secret == "" || !hmac.Equal([]byte(Sign(command, commandsConfig.Secret)), []byte(command.Signature)) {
+		return errors.New("invalid signature")
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, command.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("invalid expiration time \"%s\"", command.ExpiresAt)
+	}
+
+	if now.After(expiresAt) {
+		return errors.New("command expired")
+	}
+
+	if !util.Contains(commandsConfig.Allowed, command.Type) {
+		return fmt.Errorf("command type \"%s\" isn't allowed by agent configuration", command.Type)
+	}
+
+	if state.GetInstance().Remembers(executedKey(command.Id)) {
+		return errors.New("command already executed")
+	}
+
+	return nil
+}
+
+func executedKey(id string) string {
+	return util.StateKey(executedItemType, &id)
+}
+
+// remember keeps id of command till its expiration, state is flushed before execution, so crash of agent can't repeat command
+func (executor *Executor) remember(command codefresh.Command) {
+	instance := state.GetInstance()
+	expiresAt, _ := time.Parse(time.RFC3339, command.ExpiresAt)
+	instance.Remember(executedKey(command.Id), expiresAt)
+	err := instance.Flush()
+	if err != nil {
+		logger.GetLogger().Warnf("Failed to persist executed command \"%s\", reason %v", command.Id, err)
+	}
+}
+
+func (executor *Executor) execute(command codefresh.Command) error {
+	namespace, name := argo.ParseQualifiedName(command.Application)
+
+	switch command.Type {
+	case codefresh.SyncCommand:
+		return executor.argoApi.SyncApplication(name, namespace)
+	case codefresh.HardRefreshCommand:
+		return executor.argoApi.RefreshApplication(name, namespace, true)
+	case codefresh.RollbackCommand:
+		return executor.argoApi.RollbackApplication(name, namespace, command.HistoryId)
+	case codefresh.TerminateOperationCommand:
+		return executor.argoApi.TerminateOperation(name, namespace)
+	}
+
+	return fmt.Errorf("unknown command type \"%s\"", command.Type)
+}
+
+// Handle verifies and executes command, result is written to audit log
+func (executor *Executor) Handle(command codefresh.Command) codefresh.CommandResult {
+	executor.lock.Lock()
+	defer executor.lock.Unlock()
+
+	now := time.Now()
+	result := codefresh.CommandResult{Id: command.Id}
+
+	err := executor.verify(command, now)
+	if err != nil {
+		result.Status = codefresh.CommandRejected
+		result.Message = err.Error()
+	} else {
+		executor.remember(command)
+
+		err = executor.execute(command)
+		if err != nil {
+			result.Status = codefresh.CommandFailed
+			result.Message = err.Error()
+		} else {
+			result.Status = codefresh.CommandSucceeded
+		}
+	}
+
+	result.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	logger.GetLogger().Infof("Audit: command \"%s\" with type \"%s\" for application \"%s\" issued by \"%s\" finished with status \"%s\" %s",
+		command.Id, command.Type, command.Application, command.IssuedBy, result.Status, result.Message)
+
+	return result
+}
+
+// Poll retrieves pending commands from codefresh, executes them and reports results back
+func (executor *Executor) Poll() {
+	commands, err := executor.codefreshApi.GetCommands()
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to retrieve commands from codefresh, reason %v", err)
+		return
+	}
+
+	for _, command := range commands {
+		result := executor.Handle(command)
+		err = executor.codefreshApi.SendCommandResult(result)
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to send result of command \"%s\" to codefresh, reason %v", command.Id, err)
+		}
+	}
+}
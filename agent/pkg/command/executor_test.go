@@ -0,0 +1,113 @@
+package command
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var syncedApps []string
+
+type MockArgoApi struct {
+	argo.ArgoApi
+}
+
+func (api *MockArgoApi) SyncApplication(applicationName string, applicationNamespace string) error {
+	syncedApps = append(syncedApps, argo.ApplicationKey(applicationNamespace, applicationName))
+	return nil
+}
+
+func newCommand(id string, commandType string, expiresAt time.Time, secret string) codefresh.Command {
+	command := codefresh.Command{
+		Id:          id,
+		Type:        commandType,
+		Application: "team-a_app",
+		IssuedBy:    "release-manager",
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+	}
+	command.Signature = Sign(command, secret)
+	return command
+}
+
+func TestHandleCommands(t *testing.T) {
+	syncedApps = make([]string, 0)
+	store.SetCommands("secret", []string{codefresh.SyncCommand})
+
+	executor := GetExecutorInstance(nil, &MockArgoApi{})
+	future := time.Now().Add(time.Minute)
+
+	cases := []struct {
+		command  codefresh.Command
+		expected string
+	}{
+		{newCommand("1", codefresh.SyncCommand, future, "secret"), codefresh.CommandSucceeded},
+		{newCommand("1", codefresh.SyncCommand, future, "secret"), codefresh.CommandRejected},
+		{newCommand("2", codefresh.SyncCommand, future, "wrong-secret"), codefresh.CommandRejected},
+		{newCommand("3", codefresh.SyncCommand, time.Now().Add(-time.Minute), "secret"), codefresh.CommandRejected},
+		{newCommand("4", codefresh.RollbackCommand, future, "secret"), codefresh.CommandRejected},
+	}
+
+	for _, c := range cases {
+		result := executor.Handle(c.command)
+		if result.Status != c.expected {
+			t.Errorf("Command \"%s\" with type \"%s\" expected to be %s, got %s (%s)", c.command.Id, c.command.Type, c.expected, result.Status, result.Message)
+		}
+	}
+
+	if len(syncedApps) != 1 || syncedApps[0] != "team-a/app" {
+		t.Errorf("Application should be synced exactly once, got %v", syncedApps)
+	}
+}
+
+func TestExecutedCommandsSurviveRestart(t *testing.T) {
+	syncedApps = make([]string, 0)
+	store.SetCommands("secret", []string{codefresh.SyncCommand})
+
+	dir, _ := ioutil.TempDir("", "state")
+	defer os.RemoveAll(dir)
+	backend := &state.FileBackend{Path: filepath.Join(dir, "state.json")}
+	defer state.Init(0, nil, 0)
+
+	_ = state.Init(0, backend, time.Hour)
+	command := newCommand("restart-1", codefresh.SyncCommand, time.Now().Add(time.Minute), "secret")
+	result := GetExecutorInstance(nil, &MockArgoApi{}).Handle(command)
+	if result.Status != codefresh.CommandSucceeded {
+		t.Errorf("'Handle' failed, expected '%v', got '%v' (%s)", codefresh.CommandSucceeded, result.Status, result.Message)
+	}
+
+	// agent is restarted with state, that was persisted before execution
+	executor = nil
+	_ = state.Init(0, backend, time.Hour)
+	result = GetExecutorInstance(nil, &MockArgoApi{}).Handle(command)
+	if result.Status != codefresh.CommandRejected {
+		t.Errorf("'Handle' failed, expected '%v' after restart, got '%v'", codefresh.CommandRejected, result.Status)
+	}
+	if len(syncedApps) != 1 {
+		t.Errorf("Application should be synced exactly once, got %v", syncedApps)
+	}
+}
+
+func TestExecutedCommandsAreNotEvicted(t *testing.T) {
+	syncedApps = make([]string, 0)
+	store.SetCommands("secret", []string{codefresh.SyncCommand})
+	_ = state.Init(1, nil, 0)
+	defer state.Init(0, nil, 0)
+
+	command := newCommand("evicted-1", codefresh.SyncCommand, time.Now().Add(time.Minute), "secret")
+	GetExecutorInstance(nil, &MockArgoApi{}).Handle(command)
+
+	// hashes of environments fill store
+	state.GetInstance().Set("environment.argocd/app1", "1")
+	state.GetInstance().Set("environment.argocd/app2", "2")
+
+	result := GetExecutorInstance(nil, &MockArgoApi{}).Handle(command)
+	if result.Status != codefresh.CommandRejected || len(syncedApps) != 1 {
+		t.Errorf("'Handle' failed, expected command to be rejected, got '%v', synced '%v'", result.Status, syncedApps)
+	}
+}
@@ -0,0 +1,119 @@
+package diagnostics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Subsystems, that report their errors to heartbeat
+const (
+	ArgoSubsystem      = "argo"
+	GitSubsystem       = "git"
+	CodefreshSubsystem = "codefresh"
+	InformerSubsystem  = "informer"
+	TracingSubsystem   = "tracing"
+)
+
+type SubsystemError struct {
+	Message string `json:"message"`
+	Time    string `json:"time"`
+	// Source is operation of subsystem, that failed, like request of resource tree
+	Source string `json:"source"`
+	at     time.Time
+}
+
+var (
+	subsystemErrors = make(map[string]map[string]SubsystemError)
+	counters        = make(map[string]func() int)
+	features        = make(map[string]bool)
+	lastEventAt     time.Time
+	lock            sync.RWMutex
+)
+
+// ReportError keeps last error of source of subsystem, nil error means source works again and clears only its error,
+// so success of one operation doesn't hide failures of others
+func ReportError(subsystem string, source string, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if err == nil {
+		delete(subsystemErrors[subsystem], source)
+		if len(subsystemErrors[subsystem]) == 0 {
+			delete(subsystemErrors, subsystem)
+		}
+		return
+	}
+	if subsystemErrors[subsystem] == nil {
+		subsystemErrors[subsystem] = make(map[string]SubsystemError)
+	}
+	now := time.Now().UTC()
+	subsystemErrors[subsystem][source] = SubsystemError{Message: err.Error(), Time: now.Format(time.RFC3339), Source: source, at: now}
+}
+
+// Errors returns latest current error of every subsystem
+func Errors() map[string]SubsystemError {
+	lock.RLock()
+	defer lock.RUnlock()
+	result := make(map[string]SubsystemError, len(subsystemErrors))
+	for subsystem, sources := range subsystemErrors {
+		for _, err := range sources {
+			if latest, ok := result[subsystem]; !ok || err.at.After(latest.at) || (err.at.Equal(latest.at) && err.Source < latest.Source) {
+				result[subsystem] = err
+			}
+		}
+	}
+	return result
+}
+
+// RegisterCounter registers function, that counts watched objects of kind, like applications from informer cache
+func RegisterCounter(kind string, counter func() int) {
+	lock.Lock()
+	defer lock.Unlock()
+	counters[kind] = counter
+}
+
+// Count returns amount of watched objects of kind, 0 if kind isn't watched
+func Count(kind string) int {
+	lock.RLock()
+	counter, ok := counters[kind]
+	lock.RUnlock()
+	if !ok {
+		return 0
+	}
+	return counter()
+}
+
+func EnableFeature(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	features[name] = true
+}
+
+// Features returns sorted list of enabled features
+func Features() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+	result := make([]string, 0, len(features))
+	for name := range features {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// MarkEventSent remembers time of last event, that was successfully delivered to codefresh
+func MarkEventSent() {
+	lock.Lock()
+	defer lock.Unlock()
+	lastEventAt = time.Now().UTC()
+}
+
+// LastEventAt returns time of last delivered event in RFC3339, empty if nothing was delivered yet
+func LastEventAt() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	if lastEventAt.IsZero() {
+		return ""
+	}
+	return lastEventAt.Format(time.RFC3339)
+}
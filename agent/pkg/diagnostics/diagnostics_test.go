@@ -0,0 +1,61 @@
+package diagnostics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReportError(t *testing.T) {
+	ReportError(ArgoSubsystem, "stream", errors.New("connection refused"))
+	ReportError(GitSubsystem, "gitops", errors.New("rate limited"))
+
+	if len(Errors()) != 2 || Errors()[ArgoSubsystem].Message != "connection refused" || Errors()[ArgoSubsystem].Time == "" {
+		t.Errorf("'ReportError' failed, got '%v'", Errors())
+	}
+
+	ReportError(ArgoSubsystem, "stream", nil)
+	if _, ok := Errors()[ArgoSubsystem]; ok || len(Errors()) != 1 {
+		t.Errorf("'ReportError' failed, error should be cleared, got '%v'", Errors())
+	}
+	ReportError(GitSubsystem, "gitops", nil)
+}
+
+func TestReportErrorKeepsFailuresOfOtherSources(t *testing.T) {
+	ReportError(ArgoSubsystem, "resource-tree", errors.New("connection refused"))
+	ReportError(ArgoSubsystem, "stream", nil)
+
+	err, ok := Errors()[ArgoSubsystem]
+	if !ok || err.Source != "resource-tree" {
+		t.Errorf("'ReportError' failed, expected error of '%v' to stay, got '%v'", "resource-tree", Errors())
+	}
+
+	ReportError(ArgoSubsystem, "project", errors.New("forbidden"))
+	if Errors()[ArgoSubsystem].Message == "" {
+		t.Errorf("'ReportError' failed, expected error of subsystem, got '%v'", Errors())
+	}
+
+	ReportError(ArgoSubsystem, "resource-tree", nil)
+	if Errors()[ArgoSubsystem].Source != "project" {
+		t.Errorf("'ReportError' failed, expected '%v', got '%v'", "project", Errors()[ArgoSubsystem].Source)
+	}
+
+	ReportError(ArgoSubsystem, "project", nil)
+	if _, ok := Errors()[ArgoSubsystem]; ok {
+		t.Errorf("'ReportError' failed, error should be cleared, got '%v'", Errors())
+	}
+}
+
+func TestCountersAndFeatures(t *testing.T) {
+	RegisterCounter("applications", func() int { return 3 })
+	EnableFeature("tracing")
+	EnableFeature("commands")
+
+	if Count("applications") != 3 || Count("projects") != 0 {
+		t.Errorf("'Count' failed, expected '3' and '0', got '%v' and '%v'", Count("applications"), Count("projects"))
+	}
+
+	features := Features()
+	if len(features) != 2 || features[0] != "commands" {
+		t.Errorf("'Features' failed, expected '[commands tracing]', got '%v'", features)
+	}
+}
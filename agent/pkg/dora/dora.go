@@ -0,0 +1,373 @@
+package dora
+
+import (
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	healthyStatus  = "Healthy"
+	degradedStatus = "Degraded"
+)
+
+// Phases of failed sync operation
+const (
+	failedPhase = "Failed"
+	errorPhase  = "Error"
+)
+
+// DefaultWindows are rolling windows of metrics, when they aren't configured
+var DefaultWindows = []time.Duration{7 * 24 * time.Hour, 30 * 24 * time.Hour}
+
+// Observation is state of application, that agent saw with environment update
+type Observation struct {
+	Application string
+	Project     string
+	HistoryId   int64
+	Revision    string
+	// Phase and FinishedAt are of last sync operation
+	Phase      string
+	FinishedAt string
+	// CommitDate is time of commit of revision in RFC3339, empty when it's unknown
+	CommitDate   string
+	HealthStatus string
+	Rollback     bool
+	History      []argo.ArgoApplicationHistoryItem
+}
+
+type deployment struct {
+	// key identifies deployment: history id of successful sync or finish time of failed one
+	key        string
+	revision   string
+	finishedAt time.Time
+	leadTime   time.Duration
+	// failed deployment failed itself, degraded application before next deployment or was rolled back
+	failed bool
+}
+
+type incident struct {
+	startedAt  time.Time
+	resolvedAt time.Time
+}
+
+type applicationRecord struct {
+	project      string
+	health       string
+	deployments  []*deployment
+	incidents    []*incident
+	lastFinished string
+}
+
+// Tracker computes DORA metrics from observations of applications
+type Tracker struct {
+	windows      []time.Duration
+	lock         sync.Mutex
+	applications map[string]*applicationRecord
+}
+
+var (
+	tracker *Tracker
+	now     = time.Now
+)
+
+// Start enables tracking of DORA metrics over windows
+func Start(windows []time.Duration) *Tracker {
+	tracker = NewTracker(windows)
+	return tracker
+}
+
+// GetTracker returns tracker of DORA metrics, it's nil when metrics are disabled
+func GetTracker() *Tracker {
+	return tracker
+}
+
+func NewTracker(windows []time.Duration) *Tracker {
+	if len(windows) == 0 {
+		windows = DefaultWindows
+	}
+	sorted := append([]time.Duration(nil), windows...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return &Tracker{
+		windows:      sorted,
+		applications: make(map[string]*applicationRecord),
+	}
+}
+
+// ParseWindows parses comma separated list of windows like "1d,7d,30d" or "12h"
+func ParseWindows(value string) ([]time.Duration, error) {
+	var result []time.Duration
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var window time.Duration
+		var err error
+		if strings.HasSuffix(item, "d") {
+			var days int
+			days, err = strconv.Atoi(strings.TrimSuffix(item, "d"))
+			window = time.Duration(days) * 24 * time.Hour
+		} else {
+			window, err = time.ParseDuration(item)
+		}
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid window \"%s\", should be positive duration like 7d or 12h", item)
+		}
+		result = append(result, window)
+	}
+	return result, nil
+}
+
+// FormatWindow formats window in days when it's whole amount of days
+func FormatWindow(window time.Duration) string {
+	day := 24 * time.Hour
+	if window%day == 0 {
+		return fmt.Sprintf("%dd", window/day)
+	}
+	return window.String()
+}
+
+func parseTime(value string) (time.Time, bool) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	return parsed, err == nil
+}
+
+// Forget removes application, that was deleted, its deployments aren't counted in project anymore
+func (t *Tracker) Forget(application string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.applications, application)
+}
+
+// Observe records deployments and health transitions of application
+func (t *Tracker) Observe(observation Observation) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	current := now()
+	record, known := t.applications[observation.Application]
+	if !known {
+		record = &applicationRecord{}
+		t.applications[observation.Application] = record
+		// history of argocd gives deployments, that happened before start of agent
+		record.backfill(observation.History)
+	}
+	record.project = observation.Project
+
+	record.observeDeployment(observation)
+	record.observeHealth(observation.HealthStatus, current)
+	record.prune(current.Add(-t.windows[len(t.windows)-1]))
+}
+
+func (record *applicationRecord) find(key string) *deployment {
+	for _, item := range record.deployments {
+		if item.key == key {
+			return item
+		}
+	}
+	return nil
+}
+
+func (record *applicationRecord) backfill(history []argo.ArgoApplicationHistoryItem) {
+	for _, item := range history {
+		deployedAt, ok := parseTime(item.DeployedAt)
+		if !ok {
+			continue
+		}
+		record.deployments = append(record.deployments, &deployment{
+			key:        strconv.FormatInt(item.Id, 10),
+			revision:   item.Revision,
+			finishedAt: deployedAt,
+		})
+	}
+	sort.Slice(record.deployments, func(i, j int) bool {
+		return record.deployments[i].finishedAt.Before(record.deployments[j].finishedAt)
+	})
+}
+
+func (record *applicationRecord) observeDeployment(observation Observation) {
+	finishedAt, ok := parseTime(observation.FinishedAt)
+	if !ok || observation.FinishedAt == record.lastFinished {
+		return
+	}
+
+	var key string
+	failed := false
+	switch observation.Phase {
+	case failedPhase, errorPhase:
+		key = "failed/" + observation.FinishedAt
+		failed = true
+	default:
+		if observation.HistoryId < 0 {
+			return
+		}
+		key = strconv.FormatInt(observation.HistoryId, 10)
+	}
+
+	record.lastFinished = observation.FinishedAt
+
+	item := record.find(key)
+	if item == nil {
+		item = &deployment{key: key, revision: observation.Revision, finishedAt: finishedAt, failed: failed}
+		record.deployments = append(record.deployments, item)
+	}
+	if observation.Rollback {
+		record.markRolledBack(key, item.finishedAt)
+	}
+
+	// deployments from history of argocd get lead time, when agent sees them
+	if commitDate, ok := parseTime(observation.CommitDate); ok && item.leadTime == 0 && !commitDate.After(finishedAt) {
+		item.leadTime = finishedAt.Sub(commitDate)
+	}
+}
+
+// markRolledBack marks deployment, that was live before rollback, as failed change
+func (record *applicationRecord) markRolledBack(key string, finishedAt time.Time) {
+	var previous *deployment
+	for _, item := range record.deployments {
+		if item.key != key && item.finishedAt.Before(finishedAt) && (previous == nil || item.finishedAt.After(previous.finishedAt)) {
+			previous = item
+		}
+	}
+	if previous != nil {
+		previous.failed = true
+	}
+}
+
+func (record *applicationRecord) observeHealth(health string, current time.Time) {
+	if health == "" || health == record.health {
+		return
+	}
+	previous := record.health
+	record.health = health
+
+	var open *incident
+	if len(record.incidents) > 0 && record.incidents[len(record.incidents)-1].resolvedAt.IsZero() {
+		open = record.incidents[len(record.incidents)-1]
+	}
+
+	switch {
+	case health == degradedStatus && open == nil:
+		record.incidents = append(record.incidents, &incident{startedAt: current})
+		// change, that is live, degraded application
+		if previous != "" && len(record.deployments) > 0 {
+			record.deployments[len(record.deployments)-1].failed = true
+		}
+	case health == healthyStatus && open != nil:
+		open.resolvedAt = current
+	}
+}
+
+// prune drops deployments and resolved incidents, that are older than biggest window
+func (record *applicationRecord) prune(since time.Time) {
+	deployments := record.deployments[:0]
+	for _, item := range record.deployments {
+		if item.finishedAt.After(since) {
+			deployments = append(deployments, item)
+		}
+	}
+	record.deployments = deployments
+
+	incidents := record.incidents[:0]
+	for _, item := range record.incidents {
+		if item.resolvedAt.IsZero() || item.resolvedAt.After(since) {
+			incidents = append(incidents, item)
+		}
+	}
+	record.incidents = incidents
+}
+
+func metrics(records []*applicationRecord, window time.Duration, current time.Time) codefresh.DoraMetrics {
+	since := current.Add(-window)
+	result := codefresh.DoraMetrics{Window: FormatWindow(window)}
+
+	var leadTimes []time.Duration
+	var restoreTime time.Duration
+	for _, record := range records {
+		for _, item := range record.deployments {
+			if !item.finishedAt.After(since) {
+				continue
+			}
+			result.Deployments++
+			if item.failed {
+				result.FailedDeployments++
+			}
+			if item.leadTime > 0 {
+				leadTimes = append(leadTimes, item.leadTime)
+			}
+		}
+		for _, item := range record.incidents {
+			if item.resolvedAt.IsZero() || !item.resolvedAt.After(since) {
+				continue
+			}
+			result.Incidents++
+			restoreTime += item.resolvedAt.Sub(item.startedAt)
+		}
+	}
+
+	result.DeploymentFrequency = float64(result.Deployments) / window.Hours() * 24
+	if result.Deployments > 0 {
+		result.ChangeFailureRate = float64(result.FailedDeployments) / float64(result.Deployments)
+	}
+	if len(leadTimes) > 0 {
+		sort.Slice(leadTimes, func(i, j int) bool {
+			return leadTimes[i] < leadTimes[j]
+		})
+		result.LeadTime = median(leadTimes).Seconds()
+	}
+	if result.Incidents > 0 {
+		result.MTTR = (restoreTime / time.Duration(result.Incidents)).Seconds()
+	}
+	return result
+}
+
+func median(sorted []time.Duration) time.Duration {
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
+
+// Summary computes metrics of every application and project for every window
+func (t *Tracker) Summary() codefresh.DoraSummary {
+	summary, _ := t.compute()
+	return summary
+}
+
+// compute returns summary and projects of applications
+func (t *Tracker) compute() (codefresh.DoraSummary, map[string]string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	current := now()
+	applicationProjects := make(map[string]string, len(t.applications))
+	summary := codefresh.DoraSummary{
+		Time:         current.UTC().Format(time.RFC3339),
+		Applications: make(map[string][]codefresh.DoraMetrics, len(t.applications)),
+		Projects:     make(map[string][]codefresh.DoraMetrics),
+	}
+
+	projects := make(map[string][]*applicationRecord)
+	for name, record := range t.applications {
+		applicationProjects[name] = record.project
+		projects[record.project] = append(projects[record.project], record)
+		for _, window := range t.windows {
+			summary.Applications[name] = append(summary.Applications[name], metrics([]*applicationRecord{record}, window, current))
+		}
+	}
+	for project, records := range projects {
+		for _, window := range t.windows {
+			summary.Projects[project] = append(summary.Projects[project], metrics(records, window, current))
+		}
+	}
+	return summary, applicationProjects
+}
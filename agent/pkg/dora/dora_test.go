@@ -0,0 +1,118 @@
+package dora
+
+import (
+	"bytes"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setNow(value string) {
+	parsed, _ := time.Parse(time.RFC3339, value)
+	now = func() time.Time {
+		return parsed
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows("1d, 12h,30d")
+	if err != nil || len(windows) != 3 || windows[0] != 24*time.Hour || windows[1] != 12*time.Hour {
+		t.Errorf("'ParseWindows' failed, unexpected '%v', error '%v'", windows, err)
+	}
+	if _, err = ParseWindows("7x"); err == nil {
+		t.Errorf("'ParseWindows' failed, expected error for invalid window")
+	}
+	if FormatWindow(7*24*time.Hour) != "7d" || FormatWindow(12*time.Hour) != "12h0m0s" {
+		t.Errorf("'FormatWindow' failed, unexpected '%v' '%v'", FormatWindow(7*24*time.Hour), FormatWindow(12*time.Hour))
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	defer func() { now = time.Now }()
+	tracker := NewTracker([]time.Duration{7 * 24 * time.Hour})
+
+	// deployment before start of agent is taken from history
+	setNow("2021-01-10T10:00:00Z")
+	tracker.Observe(Observation{
+		Application:  "app",
+		Project:      "default",
+		HistoryId:    1,
+		Revision:     "a",
+		Phase:        "Succeeded",
+		FinishedAt:   "2021-01-09T10:00:00Z",
+		HealthStatus: "Healthy",
+		History:      []argo.ArgoApplicationHistoryItem{{Id: 1, Revision: "a", DeployedAt: "2021-01-09T10:00:00Z"}},
+	})
+
+	// new revision degrades application
+	setNow("2021-01-10T11:00:00Z")
+	tracker.Observe(Observation{
+		Application: "app", Project: "default", HistoryId: 2, Revision: "b", Phase: "Succeeded",
+		FinishedAt: "2021-01-10T11:00:00Z", CommitDate: "2021-01-10T10:00:00Z", HealthStatus: "Degraded",
+	})
+
+	// rollback restores it in 30 minutes
+	setNow("2021-01-10T11:30:00Z")
+	tracker.Observe(Observation{
+		Application: "app", Project: "default", HistoryId: 3, Revision: "a", Phase: "Succeeded",
+		FinishedAt: "2021-01-10T11:30:00Z", CommitDate: "2021-01-08T11:30:00Z", HealthStatus: "Healthy", Rollback: true,
+	})
+
+	// failed sync of other application of project
+	tracker.Observe(Observation{
+		Application: "other", Project: "default", HistoryId: -1, Phase: "Failed",
+		FinishedAt: "2021-01-10T11:20:00Z", HealthStatus: "Healthy",
+	})
+
+	summary := tracker.Summary()
+
+	metrics := summary.Applications["app"][0]
+	if metrics.Deployments != 3 || metrics.FailedDeployments != 1 {
+		t.Errorf("'Summary' failed, expected '%v' deployments with '%v' failed, got '%v' '%v'", 3, 1, metrics.Deployments, metrics.FailedDeployments)
+	}
+	if metrics.LeadTime != (24*time.Hour + 30*time.Minute).Seconds() {
+		t.Errorf("'Summary' failed, expected lead time '%v', got '%v'", (24*time.Hour + 30*time.Minute).Seconds(), metrics.LeadTime)
+	}
+	if metrics.Incidents != 1 || metrics.MTTR != (30*time.Minute).Seconds() {
+		t.Errorf("'Summary' failed, expected '%v' incident with mttr '%v', got '%v' '%v'", 1, (30 * time.Minute).Seconds(), metrics.Incidents, metrics.MTTR)
+	}
+
+	project := summary.Projects["default"][0]
+	if project.Deployments != 4 || project.FailedDeployments != 2 || project.ChangeFailureRate != 0.5 {
+		t.Errorf("'Summary' failed, unexpected project metrics '%+v'", project)
+	}
+	if project.DeploymentFrequency != 4.0/7 {
+		t.Errorf("'Summary' failed, expected frequency '%v', got '%v'", 4.0/7, project.DeploymentFrequency)
+	}
+
+	// deployments leave window
+	setNow("2021-01-20T00:00:00Z")
+	metrics = tracker.Summary().Applications["app"][0]
+	if metrics.Deployments != 0 || metrics.ChangeFailureRate != 0 {
+		t.Errorf("'Summary' failed, expected no deployments in window, got '%+v'", metrics)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	defer func() { now = time.Now }()
+	setNow("2021-01-10T10:00:00Z")
+	tracker := NewTracker([]time.Duration{24 * time.Hour})
+	tracker.Observe(Observation{Application: "app\"1", Project: "default", HistoryId: 1, Phase: "Succeeded", FinishedAt: "2021-01-10T09:00:00Z"})
+
+	summary, projects := tracker.compute()
+	var buffer bytes.Buffer
+	writeMetrics(&buffer, summary, projects)
+	output := buffer.String()
+
+	expected := []string{
+		"# TYPE argocd_agent_dora_deployments gauge",
+		`argocd_agent_dora_deployments{application="app\"1",project="default",window="1d"} 1`,
+		`argocd_agent_dora_project_deployment_frequency_per_day{project="default",window="1d"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("'writeMetrics' failed, expected line '%v' in output '%v'", line, output)
+		}
+	}
+}
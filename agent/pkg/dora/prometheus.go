@@ -0,0 +1,90 @@
+package dora
+
+import (
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MetricsPath is path of prometheus metrics
+const MetricsPath = "/metrics"
+
+const metricsPrefix = "argocd_agent_dora_"
+
+type metricFamily struct {
+	name  string
+	help  string
+	value func(metrics codefresh.DoraMetrics) float64
+}
+
+var families = []metricFamily{
+	{"deployments", "Deployments in window", func(m codefresh.DoraMetrics) float64 { return float64(m.Deployments) }},
+	{"failed_deployments", "Deployments in window, that failed, degraded application or were rolled back", func(m codefresh.DoraMetrics) float64 { return float64(m.FailedDeployments) }},
+	{"deployment_frequency_per_day", "Average amount of deployments per day in window", func(m codefresh.DoraMetrics) float64 { return m.DeploymentFrequency }},
+	{"lead_time_seconds", "Median time from commit to deployment in window", func(m codefresh.DoraMetrics) float64 { return m.LeadTime }},
+	{"change_failure_rate", "Part of deployments in window, that failed", func(m codefresh.DoraMetrics) float64 { return m.ChangeFailureRate }},
+	{"time_to_restore_seconds", "Mean time from degradation to recovery of incidents resolved in window", func(m codefresh.DoraMetrics) float64 { return m.MTTR }},
+}
+
+// Handler serves metrics of tracker in prometheus text format,
+// metrics of applications are prefixed with argocd_agent_dora_ and metrics of projects with argocd_agent_dora_project_
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		summary, applicationProjects := t.compute()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, summary, applicationProjects)
+	})
+}
+
+func sortedKeys(values map[string][]codefresh.DoraMetrics) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeMetrics(w io.Writer, summary codefresh.DoraSummary, applicationProjects map[string]string) {
+	applications := sortedKeys(summary.Applications)
+	projects := sortedKeys(summary.Projects)
+
+	for _, family := range families {
+		name := metricsPrefix + family.name
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, family.help, name)
+		for _, application := range applications {
+			for _, metrics := range summary.Applications[application] {
+				_, _ = fmt.Fprintf(w, "%s{application=%s,project=%s,window=%s} %v\n", name,
+					quote(application), quote(applicationProjects[application]), quote(metrics.Window), family.value(metrics))
+			}
+		}
+
+		name = metricsPrefix + "project_" + family.name
+		_, _ = fmt.Fprintf(w, "# HELP %s %s, for all applications of project\n# TYPE %s gauge\n", name, family.help, name)
+		for _, project := range projects {
+			for _, metrics := range summary.Projects[project] {
+				_, _ = fmt.Fprintf(w, "%s{project=%s,window=%s} %v\n", name, quote(project), quote(metrics.Window), family.value(metrics))
+			}
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+// ServeMetrics serves prometheus metrics of tracker on address, it returns only when server failed
+func ServeMetrics(listenAddr string, tracker *Tracker) error {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, tracker.Handler())
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+
+	logger.GetLogger().Infof("Start serving prometheus metrics on \"%s%s\"", listenAddr, MetricsPath)
+	return server.ListenAndServe()
+}
@@ -0,0 +1,145 @@
+package dryrun
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// Difference describes change of latest payload sent with same key between two recordings
+type Difference struct {
+	Key     string
+	Added   bool
+	Removed bool
+	Changes []string
+}
+
+// ReadRecords reads NDJSON file written by recorder
+func ReadRecords(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Key groups requests about same entity, like environment events of single application
+func (record Record) Key() string {
+	key := record.Method + " " + record.Path
+
+	var body map[string]interface{}
+	if json.Unmarshal(record.Body, &body) != nil {
+		return key
+	}
+
+	if name, ok := body["name"].(string); ok {
+		return key + "#" + name
+	}
+	if kind, ok := body["type"].(string); ok {
+		return key + "#" + kind
+	}
+	return key
+}
+
+func latestBodies(records []Record) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, record := range records {
+		var body interface{}
+		_ = json.Unmarshal(record.Body, &body)
+		result[record.Key()] = body
+	}
+	return result
+}
+
+func compare(path string, old interface{}, new interface{}, changes *[]string) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := make(map[string]bool)
+		for key := range oldMap {
+			keys[key] = true
+		}
+		for key := range newMap {
+			keys[key] = true
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for key := range keys {
+			sortedKeys = append(sortedKeys, key)
+		}
+		sort.Strings(sortedKeys)
+		for _, key := range sortedKeys {
+			compare(path+"."+key, oldMap[key], newMap[key], changes)
+		}
+		return
+	}
+
+	oldArr, oldIsArr := old.([]interface{})
+	newArr, newIsArr := new.([]interface{})
+	if oldIsArr && newIsArr && len(oldArr) == len(newArr) {
+		for i := range oldArr {
+			compare(fmt.Sprintf("%s[%d]", path, i), oldArr[i], newArr[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		oldJson, _ := json.Marshal(old)
+		newJson, _ := json.Marshal(new)
+		*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, oldJson, newJson))
+	}
+}
+
+// Diff compares latest payloads of each key between two recordings, time of requests is ignored
+func Diff(oldRecords []Record, newRecords []Record) []Difference {
+	oldBodies := latestBodies(oldRecords)
+	newBodies := latestBodies(newRecords)
+
+	keys := make([]string, 0)
+	for key := range oldBodies {
+		keys = append(keys, key)
+	}
+	for key := range newBodies {
+		if _, ok := oldBodies[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]Difference, 0)
+	for _, key := range keys {
+		oldBody, inOld := oldBodies[key]
+		newBody, inNew := newBodies[key]
+
+		switch {
+		case !inOld:
+			result = append(result, Difference{Key: key, Added: true})
+		case !inNew:
+			result = append(result, Difference{Key: key, Removed: true})
+		default:
+			changes := make([]string, 0)
+			compare("", oldBody, newBody, &changes)
+			if len(changes) > 0 {
+				result = append(result, Difference{Key: key, Changes: changes})
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,44 @@
+package dryrun
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newRecord(time string, path string, body string) Record {
+	return Record{Time: time, Method: "POST", Path: path, Body: json.RawMessage(body)}
+}
+
+func TestDiff(t *testing.T) {
+	oldRecords := []Record{
+		newRecord("1", "/environments-v2/argo/events", `{"name":"app","healthStatus":"Progressing"}`),
+		newRecord("2", "/environments-v2/argo/events", `{"name":"app","healthStatus":"Healthy","activities":[{"status":"Healthy"}]}`),
+		newRecord("3", "/environments-v2/argo/events", `{"name":"removed"}`),
+		newRecord("4", "/argo-agent/argocd", `{"type":"applications","items":[]}`),
+	}
+
+	newRecords := []Record{
+		newRecord("5", "/environments-v2/argo/events", `{"name":"app","healthStatus":"Healthy","activities":[{"status":"Degraded"}]}`),
+		newRecord("6", "/environments-v2/argo/events", `{"name":"added"}`),
+		newRecord("7", "/argo-agent/argocd", `{"type":"applications","items":[]}`),
+	}
+
+	differences := Diff(oldRecords, newRecords)
+
+	if len(differences) != 3 {
+		t.Fatalf("'Diff' failed, expected 3 differences, got %v", differences)
+	}
+
+	changed := differences[1]
+	if changed.Key != "POST /environments-v2/argo/events#app" || len(changed.Changes) != 1 {
+		t.Errorf("'Diff' failed, unexpected difference %v", changed)
+	}
+
+	if changed.Changes[0] != `.activities[0].status: "Healthy" -> "Degraded"` {
+		t.Errorf("'Diff' failed, unexpected change %v", changed.Changes[0])
+	}
+
+	if !differences[0].Added || !differences[2].Removed {
+		t.Errorf("'Diff' failed, expected added and removed environments, got %v", differences)
+	}
+}
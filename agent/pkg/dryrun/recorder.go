@@ -0,0 +1,108 @@
+package dryrun
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Stdout is output value that makes recorder write requests to standard output
+const Stdout = "stdout"
+
+// FileName is name of file inside output directory that requests are appended to
+const FileName = "codefresh.ndjson"
+
+// Record is single request that agent would send to codefresh
+type Record struct {
+	Time   string            `json:"time"`
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Query  map[string]string `json:"query,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// Recorder writes requests as NDJSON instead of sending them to codefresh
+type Recorder struct {
+	out  io.Writer
+	lock sync.Mutex
+}
+
+var (
+	recorder *Recorder
+	now      = time.Now
+)
+
+// Init enables dry run mode, output is "stdout" or directory for requests file
+func Init(output string) error {
+	if output == Stdout {
+		recorder = &Recorder{out: os.Stdout}
+		return nil
+	}
+
+	err := os.MkdirAll(output, 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(output, FileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	recorder = &Recorder{out: f}
+	return nil
+}
+
+// SetClock replaces source of request times, replay uses it to produce same output for same archive
+func SetClock(clock func() time.Time) {
+	now = clock
+}
+
+// Close disables dry run mode and closes requests file
+func Close() error {
+	current := recorder
+	recorder = nil
+	if current == nil {
+		return nil
+	}
+	if closer, ok := current.out.(io.Closer); ok && current.out != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+// GetRecorder returns nil if dry run mode is disabled
+func GetRecorder() *Recorder {
+	return recorder
+}
+
+func (r *Recorder) Record(method string, path string, query map[string]string, body interface{}) error {
+	record := Record{
+		Time:   now().UTC().Format(time.RFC3339Nano),
+		Method: method,
+		Path:   path,
+		Query:  query,
+	}
+
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		record.Body = payload
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, err = r.out.Write(append(line, '\n'))
+	return err
+}
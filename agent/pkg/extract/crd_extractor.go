@@ -1,23 +1,38 @@
 package extract
 
 import (
+	"context"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
-	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func ExtractNewApplication(application string) (*codefresh.Environment, error) {
-	applicationObj, err := argo.GetApplication(application)
+// ExtractNewApplication prepares environment of application, that is referenced by its codefresh qualified name
+func ExtractNewApplication(ctx context.Context, application string) (*codefresh.Environment, error) {
+	namespace, name := argo.ParseQualifiedName(application)
+	applicationObj, err := argo.GetApplication(name, namespace)
 	if err != nil {
 		return nil, err
 	}
 
 	envTransformer := transform.GetEnvTransformerInstance(argo.GetInstance())
 
-	err, env := envTransformer.PrepareEnvironment(applicationObj)
+	err, env := envTransformer.PrepareEnvironment(ctx, applicationObj)
 	if err != nil {
 		return nil, err
 	}
 	return env, nil
 }
+
+// applicationCache serves applications of event source to argo.GetApplication
+func applicationCache(lookup func(key string) (*unstructured.Unstructured, bool)) argo.ApplicationCache {
+	return func(name string, namespace string) (map[string]interface{}, bool) {
+		obj, ok := lookup(argo.ApplicationKey(namespace, name))
+		if !ok {
+			return nil, false
+		}
+		// cached objects are shared with event source and shouldn't be modified
+		return obj.DeepCopy().Object, true
+	}
+}
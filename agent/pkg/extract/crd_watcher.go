@@ -2,20 +2,30 @@ package extract
 
 import (
 	//"fmt"
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dora"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/handler"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/kube"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/queue"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/replay"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/sink"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
 	"github.com/mitchellh/mapstructure"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
-	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"time"
 )
@@ -32,160 +42,346 @@ var (
 		Version:  "v1alpha1",
 		Resource: "appprojects",
 	}
+
+	applicationSetCRD = schema.GroupVersionResource{
+		Group:    "argoproj.io",
+		Version:  "v1alpha1",
+		Resource: "applicationsets",
+	}
 )
 
-var itemQueue *queue.ItemQueue
+var itemQueue = queue.GetInstance()
+
+func updateDeletedEnv(ctx context.Context, obj interface{}) (error, *codefresh2.Environment) {
+	// state of deleted application isn't needed anymore, even if deleted status wasn't sent
+	key := argo.ApplicationKey(obj.(*unstructured.Unstructured).GetNamespace(), obj.(*unstructured.Unstructured).GetName())
+	defer state.GetInstance().Delete(util.StateKey("environment", &key))
 
-func updateDeletedEnv(obj interface{}) (error, *codefresh2.Environment) {
 	envTransformer := transform.GetEnvTransformerInstance(argo.GetInstance())
-	err, env := envTransformer.PrepareEnvironment(obj.(*unstructured.Unstructured).Object)
+	err, env := envTransformer.PrepareEnvironment(ctx, obj.(*unstructured.Unstructured).Object)
 	if err != nil {
 		return err, env
 	}
 
 	env.HealthStatus = "Deleted"
-	_, err = codefresh2.GetInstance().SendEnvironment(*env)
+	err = sink.GetDispatcherInstance().Dispatch(ctx, sink.EnvironmentDeleted, *env)
 
 	return nil, env
 }
 
-func watchApplicationChanges() error {
-	config, err := kube.BuildConfig()
+// startInformerSpan starts trace of application event, received from informer
+func startInformerSpan(name string, obj *unstructured.Unstructured) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(context.Background(), name, tracing.KindInternal)
+	span.SetAttribute("argocd.application", obj.GetName())
+	span.SetAttribute("argocd.namespace", obj.GetNamespace())
+	span.SetAttribute("k8s.resourceVersion", obj.GetResourceVersion())
+	return ctx, span
+}
+
+func enqueue(spanName string, obj *unstructured.Unstructured) {
+	ctx, span := startInformerSpan(spanName, obj)
+	defer span.End()
+	itemQueue.Enqueue(ctx, obj)
+}
+
+// RefreshApplication enqueues application, that is held by event source, e.g. when its sync windows open or close
+func RefreshApplication(namespace string, name string) {
+	obj, err := argo.GetApplication(name, namespace)
 	if err != nil {
-		return err
+		logger.GetLogger().WithFields(logger.Fields{
+			logger.AppField:       name,
+			logger.NamespaceField: namespace,
+		}).Warnf("Failed to refresh application, reason %v", err)
+		return
 	}
-	clientset, err := dynamic.NewForConfig(config)
+	enqueue("agent.Refresh", &unstructured.Unstructured{Object: obj})
+}
+
+// isResourceServed checks that crd is installed, applicationsets are available only with newer argocd versions
+func isResourceServed(config *rest.Config, resource schema.GroupVersionResource) (bool, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	kubeInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(clientset, time.Minute*30)
-	applicationInformer := kubeInformerFactory.ForResource(applicationCRD).Informer()
-
-	api := codefresh2.GetInstance()
-
-	applicationInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			var app argo.ArgoApplication
-			err := mapstructure.Decode(obj.(*unstructured.Unstructured).Object, &app)
-
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to decode argo application, reason: %v", err)
-				return
-			}
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(resource.GroupVersion().String())
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
 
-			itemQueue.Enqueue(obj.(*unstructured.Unstructured))
+	for _, apiResource := range resources.APIResources {
+		if apiResource.Name == resource.Resource {
+			return true, nil
+		}
+	}
 
-			applications, err := argo.GetInstance().GetApplicationsWithCredentialsFromStorage()
+	return false, nil
+}
 
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to get applications, reason: %v", err)
-				return
-			}
+func sendApplicationSets(informer cache.SharedIndexInformer) {
+	applicationSets := make([]argo.ArgoApplicationSet, 0)
+	for _, obj := range informer.GetStore().List() {
+		var applicationSet argo.ArgoApplicationSet
+		err := mapstructure.Decode(obj.(*unstructured.Unstructured).Object, &applicationSet)
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to decode argo applicationset, reason: %v", err)
+			continue
+		}
+		applicationSets = append(applicationSets, applicationSet)
+	}
 
-			err = util.ProcessDataWithFilter("applications", nil, applications, nil, func() error {
-				applications := transform.AdaptArgoApplications(applications)
-				return api.SendResources("applications", applications, len(applications))
-			})
+	items := transform.AdaptArgoApplicationSets(applicationSets)
 
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to send applications to codefresh, reason: %v", err)
-				return
-			}
+	err := util.ProcessDataWithFilter("applicationsets", nil, items, nil, func() error {
+		return codefresh2.GetInstance().SendResources("applicationsets", items, len(items))
+	})
 
-			logger.GetLogger().Info("Successfully sent applications to codefresh")
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to send applicationsets to codefresh, reason: %v", err)
+	}
+}
 
-			applicationCreatedHandler := handler.GetApplicationCreatedHandlerInstance()
-			err = applicationCreatedHandler.Handle(app)
+func watchApplicationSetChanges(kubeInformerFactory dynamicinformer.DynamicSharedInformerFactory) {
+	applicationSetInformer := kubeInformerFactory.ForResource(applicationSetCRD).Informer()
 
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to handle create application event use handler, reason: %v", err)
-			} else {
-				logger.GetLogger().Infof("Successfully handle new application \"%v\" ", app.Metadata.Name)
-			}
+	applicationSetInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sendApplicationSets(applicationSetInformer)
 		},
 		DeleteFunc: func(obj interface{}) {
-			var app argo.ArgoApplication
-			err := mapstructure.Decode(obj.(*unstructured.Unstructured).Object, &app)
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to decode argo application, reason: %v", err)
-				return
-			}
-
-			applications, err := argo.GetInstance().GetApplicationsWithCredentialsFromStorage()
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to get applications, reason: %v", err)
-				return
-			}
-
-			err = util.ProcessDataWithFilter("applications", nil, applications, nil, func() error {
-				applications := transform.AdaptArgoApplications(applications)
-				return api.SendResources("applications", applications, len(applications))
-			})
-
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to send applications to codefresh, reason: %v", err)
-				return
-			}
-
-			applicationRemovedHandler := handler.GetApplicationRemovedHandlerInstance()
-			err = applicationRemovedHandler.Handle(app)
-
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to handle remove application event use handler, reason: %v", err)
-			}
-
-			err, _ = updateDeletedEnv(obj)
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to update application status as 'Deleted', reason: %v", err)
-			}
-
+			sendApplicationSets(applicationSetInformer)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			itemQueue.Enqueue(newObj.(*unstructured.Unstructured))
+			sendApplicationSets(applicationSetInformer)
 		},
 	})
+}
 
-	projectInformer := kubeInformerFactory.ForResource(projectCRD).Informer()
+// getApplications lists applications, renewing argocd session when token was rejected
+func getApplications() ([]argo.ApplicationItem, error) {
+	var applications []argo.ApplicationItem
+	err := argo.WithReauthentication(func() error {
+		var err error
+		applications, err = argo.GetInstance().GetApplicationsWithCredentialsFromStorage()
+		return err
+	})
+	return applications, err
+}
 
-	projectInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			projects, err := argo.GetProjectsWithCredentialsFromStorage()
+func sendProjects() {
+	var projects []argo.ProjectItem
+	err := argo.WithReauthentication(func() error {
+		var err error
+		projects, err = argo.GetProjectsWithCredentialsFromStorage()
+		return err
+	})
+
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "projects", err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to get projects, reason: %v", err)
+		return
+	}
+
+	err = util.ProcessDataWithFilter("projects", nil, projects, nil, func() error {
+		projects := transform.AdaptArgoProjects(projects)
+		return codefresh2.GetInstance().SendResources("projects", projects, len(projects))
+	})
+
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to send projects to codefresh, reason: %v", err)
+	}
+}
+
+// OnApplicationAdd handles application add event of informer
+func OnApplicationAdd(obj interface{}) {
+	replay.RecordEvent(replay.AddEvent, obj, nil)
+
+	var app argo.ArgoApplication
+	err := mapstructure.Decode(obj.(*unstructured.Unstructured).Object, &app)
+
+	diagnostics.ReportError(diagnostics.InformerSubsystem, "decode", err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to decode argo application, reason: %v", err)
+		return
+	}
+
+	enqueue("informer.Add", obj.(*unstructured.Unstructured))
+
+	applications, err := getApplications()
+
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "applications", err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to get applications, reason: %v", err)
+		return
+	}
+
+	err = util.ProcessDataWithFilter("applications", nil, applications, nil, func() error {
+		applications := transform.AdaptArgoApplications(applications)
+		return codefresh2.GetInstance().SendResources("applications", applications, len(applications))
+	})
 
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to get projects, reason: %v", err)
-				return
-			}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to send applications to codefresh, reason: %v", err)
+		return
+	}
+
+	logger.GetLogger().Info("Successfully sent applications to codefresh")
+
+	applicationCreatedHandler := handler.GetApplicationCreatedHandlerInstance()
+	err = applicationCreatedHandler.Handle(app)
+
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to handle create application event use handler, reason: %v", err)
+	} else {
+		logger.GetLogger().Infof("Successfully handle new application \"%v\" ", app.Metadata.Name)
+	}
+}
+
+// OnApplicationDelete handles application delete event of informer
+func OnApplicationDelete(obj interface{}) {
+	replay.RecordEvent(replay.DeleteEvent, obj, nil)
+
+	var app argo.ArgoApplication
+	err := mapstructure.Decode(obj.(*unstructured.Unstructured).Object, &app)
+	diagnostics.ReportError(diagnostics.InformerSubsystem, "decode", err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to decode argo application, reason: %v", err)
+		return
+	}
+	// observations of deleted application are forgotten after its last update, that records them again
+	defer transform.ForgetTimeline(app.Metadata.Namespace, app.Metadata.Name)
+	if tracker := dora.GetTracker(); tracker != nil {
+		defer tracker.Forget(argo.QualifiedName(app.Metadata.Namespace, app.Metadata.Name))
+	}
+
+	applications, err := getApplications()
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "applications", err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to get applications, reason: %v", err)
+		return
+	}
+
+	err = util.ProcessDataWithFilter("applications", nil, applications, nil, func() error {
+		applications := transform.AdaptArgoApplications(applications)
+		return codefresh2.GetInstance().SendResources("applications", applications, len(applications))
+	})
+
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to send applications to codefresh, reason: %v", err)
+		return
+	}
+
+	applicationRemovedHandler := handler.GetApplicationRemovedHandlerInstance()
+	err = applicationRemovedHandler.Handle(app)
+
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to handle remove application event use handler, reason: %v", err)
+	}
+
+	ctx, span := startInformerSpan("informer.Delete", obj.(*unstructured.Unstructured))
+	err, _ = updateDeletedEnv(ctx, obj)
+	span.RecordError(err)
+	span.End()
+	// update of deleted environment evaluates sync windows again
+	transform.ForgetSyncWindows(app.Metadata.Namespace, app.Metadata.Name)
+	if apierrors.IsRetryable(err) {
+		logger.GetLogger().Warnf("Failed to update application status as 'Deleted', it will be repaired by next reconciliation, reason: %v", err)
+	} else if err != nil {
+		logger.GetLogger().Errorf("Failed to update application status as 'Deleted', reason: %v", err)
+	}
+}
+
+// OnApplicationUpdate handles application update event of informer
+func OnApplicationUpdate(oldObj, newObj interface{}) {
+	replay.RecordEvent(replay.UpdateEvent, newObj, oldObj)
+
+	enqueue("informer.Update", newObj.(*unstructured.Unstructured))
+
+	var oldApp, newApp argo.ArgoApplication
+	err := mapstructure.Decode(oldObj.(*unstructured.Unstructured).Object, &oldApp)
+	if err == nil {
+		err = mapstructure.Decode(newObj.(*unstructured.Unstructured).Object, &newApp)
+	}
+	diagnostics.ReportError(diagnostics.InformerSubsystem, "decode", err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to decode argo application, reason: %v", err)
+		return
+	}
+
+	applicationUpdatedHandler := handler.GetApplicationUpdatedHandlerInstance()
+	err = applicationUpdatedHandler.Handle(oldApp, newApp)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to handle update application event use handler, reason: %v", err)
+	}
+}
+
+func watchApplicationChanges() error {
+	config, err := kube.BuildConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
 
-			err = util.ProcessDataWithFilter("projects", nil, projects, nil, func() error {
-				projects := transform.AdaptArgoProjects(projects)
-				return api.SendResources("projects", projects, len(projects))
-			})
+	kubeInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(clientset, time.Minute*30)
+	applicationInformer := kubeInformerFactory.ForResource(applicationCRD).Informer()
+
+	applicationInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    OnApplicationAdd,
+		DeleteFunc: OnApplicationDelete,
+		UpdateFunc: OnApplicationUpdate,
+	})
+	argo.SetApplicationCache(applicationCache(func(key string) (*unstructured.Unstructured, bool) {
+		obj, exists, err := applicationInformer.GetStore().GetByKey(key)
+		if err != nil || !exists || !applicationInformer.HasSynced() {
+			return nil, false
+		}
+		return obj.(*unstructured.Unstructured), true
+	}))
+
+	projectInformer := kubeInformerFactory.ForResource(projectCRD).Informer()
+	argo.SetProjectCache(argo.ProjectCache(applicationCache(func(key string) (*unstructured.Unstructured, bool) {
+		obj, exists, err := projectInformer.GetStore().GetByKey(key)
+		if err != nil || !exists || !projectInformer.HasSynced() {
+			return nil, false
+		}
+		return obj.(*unstructured.Unstructured), true
+	})))
+
+	diagnostics.RegisterCounter("applications", func() int {
+		return len(applicationInformer.GetStore().ListKeys())
+	})
+	diagnostics.RegisterCounter("projects", func() int {
+		return len(projectInformer.GetStore().ListKeys())
+	})
 
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to send projects to codefresh, reason: %v", err)
-			}
+	projectInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sendProjects()
 		},
 		DeleteFunc: func(obj interface{}) {
-			projects, err := argo.GetProjectsWithCredentialsFromStorage()
-
-			if err != nil {
-				//TODO: add error handling
-				return
-			}
-
-			err = util.ProcessDataWithFilter("projects", nil, projects, nil, func() error {
-				projects := transform.AdaptArgoProjects(projects)
-				return api.SendResources("projects", projects, len(projects))
-			})
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to send projects to codefresh, reason: %v", err)
-			}
+			sendProjects()
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 		},
 	})
 
+	applicationSetServed, err := isResourceServed(config, applicationSetCRD)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to check applicationsets availability, reason: %v", err)
+	}
+
+	if applicationSetServed {
+		watchApplicationSetChanges(kubeInformerFactory)
+	} else {
+		logger.GetLogger().Info("Applicationsets aren't available, skip watching them")
+	}
+
 	stop := make(chan struct{})
 	defer close(stop)
 	kubeInformerFactory.Start(stop)
@@ -197,6 +393,5 @@ func watchApplicationChanges() error {
 }
 
 func Watch() error {
-	itemQueue = queue.GetInstance()
 	return watchApplicationChanges()
 }
@@ -0,0 +1,57 @@
+package extract
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dora"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// backendServer plays both argocd and codefresh, it answers every request with empty result
+func backendServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/resource-tree"):
+			_, _ = w.Write([]byte(`{"nodes":[]}`))
+		case strings.HasPrefix(r.URL.Path, "/api/v1/"):
+			_, _ = w.Write([]byte(`{"items":[]}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+}
+
+func TestOnApplicationDeleteForgetsObservations(t *testing.T) {
+	server := backendServer()
+	defer server.Close()
+	store.SetArgo("token", server.URL)
+	store.SetCodefresh(server.URL, "token", "argocd")
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "deleted-app", "namespace": "argocd"},
+		// invalid repository url, so github isn't requested
+		"spec": map[string]interface{}{"source": map[string]interface{}{"repoURL": "%"}},
+		"status": map[string]interface{}{
+			"operationState": map[string]interface{}{
+				"startedAt":  "2021-01-01T10:00:00Z",
+				"finishedAt": "2021-01-01T10:00:30Z",
+			},
+		},
+	}}
+	known := transform.KnownTimelines()
+	tracker := dora.Start(nil)
+	tracker.Observe(dora.Observation{Application: "deleted-app", Project: "default", HistoryId: 1, Phase: "Succeeded", FinishedAt: "2021-01-01T10:00:30Z"})
+
+	OnApplicationDelete(obj)
+
+	if transform.KnownTimelines() != known {
+		t.Errorf("'OnApplicationDelete' failed, expected '%v' timelines, got '%v'", known, transform.KnownTimelines())
+	}
+	if _, ok := tracker.Summary().Applications["deleted-app"]; ok {
+		t.Errorf("'OnApplicationDelete' failed, expected deleted application to be removed from DORA metrics")
+	}
+}
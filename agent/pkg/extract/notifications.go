@@ -0,0 +1,143 @@
+package extract
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"io"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"strings"
+)
+
+// NotificationsPath is path of endpoint, that receives webhooks of argocd notifications
+const NotificationsPath = "/notifications"
+
+const (
+	CreatedTrigger = "on-created"
+	DeletedTrigger = "on-deleted"
+)
+
+// maxNotificationSize limits body of webhook, application manifest with status rarely exceeds few megabytes
+const maxNotificationSize = 16 * 1024 * 1024
+
+// Notification is body of argocd notifications webhook, application is passed either as full manifest
+// with "{{toJson .app}}" or by name, then it's retrieved from argocd api
+type Notification struct {
+	Trigger     string                 `json:"trigger"`
+	App         string                 `json:"app"`
+	Namespace   string                 `json:"namespace"`
+	Application map[string]interface{} `json:"application"`
+}
+
+type NotificationsConfig struct {
+	ListenAddr string
+	Token      string
+	TLSCert    string
+	TLSKey     string
+}
+
+type notificationsHandler struct {
+	token string
+}
+
+// NewNotificationsHandler returns handler, that accepts webhooks authorized with "Bearer <token>"
+func NewNotificationsHandler(token string) http.Handler {
+	return &notificationsHandler{token: token}
+}
+
+func (h *notificationsHandler) authorized(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func resolveApplication(notification Notification) (*unstructured.Unstructured, error) {
+	if notification.Application != nil {
+		return &unstructured.Unstructured{Object: notification.Application}, nil
+	}
+	if notification.App == "" {
+		return nil, errors.New("notification should contain \"application\" or \"app\"")
+	}
+	if notification.Trigger == DeletedTrigger {
+		// deleted application can't be retrieved anymore
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		obj.SetName(notification.App)
+		obj.SetNamespace(notification.Namespace)
+		return obj, nil
+	}
+	app, err := argo.GetApplication(notification.App, notification.Namespace)
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "application", err)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: app}, nil
+}
+
+func (h *notificationsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(req) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var notification Notification
+	err := json.NewDecoder(io.LimitReader(req.Body, maxNotificationSize)).Decode(&notification)
+	if err != nil {
+		http.Error(w, "invalid notification: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	obj, err := resolveApplication(notification)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to resolve application of notification, reason: %v", err)
+		status := http.StatusBadGateway
+		if notification.App == "" {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	log := logger.GetLogger().WithFields(logger.Fields{
+		logger.AppField:       obj.GetName(),
+		logger.NamespaceField: obj.GetNamespace(),
+	})
+	log.Debugf("Received notification \"%s\"", notification.Trigger)
+
+	switch {
+	case notification.Trigger == DeletedTrigger || obj.GetDeletionTimestamp() != nil:
+		OnApplicationDelete(obj)
+	case notification.Trigger == CreatedTrigger:
+		OnApplicationAdd(obj)
+	default:
+		// any other trigger means that application was changed, environment is updated from its current state
+		enqueue("notifications.Update", obj)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ServeNotifications receives webhooks of argocd notifications instead of or in addition to watching crds,
+// so agent doesn't need access to kubernetes api of argocd cluster
+func ServeNotifications(config NotificationsConfig) error {
+	mux := http.NewServeMux()
+	mux.Handle(NotificationsPath, NewNotificationsHandler(config.Token))
+	server := &http.Server{Addr: config.ListenAddr, Handler: mux}
+
+	logger.GetLogger().Infof("Start receiving argocd notifications on \"%s%s\"", config.ListenAddr, NotificationsPath)
+	if config.TLSCert != "" {
+		return server.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+	}
+	return server.ListenAndServe()
+}
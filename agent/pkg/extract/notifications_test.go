@@ -0,0 +1,52 @@
+package extract
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func notify(method string, authorization string, body string) int {
+	req := httptest.NewRequest(method, NotificationsPath, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	NewNotificationsHandler("secret").ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestNotificationsHandler(t *testing.T) {
+	application := `{"trigger":"on-sync-succeeded","application":{"metadata":{"name":"app","namespace":"team"}}}`
+
+	cases := []struct {
+		name          string
+		method        string
+		authorization string
+		body          string
+		status        int
+	}{
+		{"wrong method", http.MethodGet, "Bearer secret", "", http.StatusMethodNotAllowed},
+		{"no token", http.MethodPost, "", application, http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "Bearer wrong", application, http.StatusUnauthorized},
+		{"invalid json", http.MethodPost, "Bearer secret", "{", http.StatusBadRequest},
+		{"no application", http.MethodPost, "Bearer secret", `{"trigger":"on-sync-succeeded"}`, http.StatusBadRequest},
+		{"application", http.MethodPost, "Bearer secret", application, http.StatusAccepted},
+	}
+
+	for _, c := range cases {
+		status := notify(c.method, c.authorization, c.body)
+		if status != c.status {
+			t.Errorf("'ServeHTTP' failed for %s, expected '%v', got '%v'", c.name, c.status, status)
+		}
+	}
+
+	if itemQueue.Size() != 1 {
+		t.Fatalf("'ServeHTTP' failed, expected '%v' queued items, got '%v'", 1, itemQueue.Size())
+	}
+	item := itemQueue.Dequeue()
+	if item.Object.GetName() != "app" || item.Object.GetNamespace() != "team" {
+		t.Errorf("'ServeHTTP' failed, expected '%v', got '%v'", "team/app", item.Object.GetNamespace()+"/"+item.Object.GetName())
+	}
+}
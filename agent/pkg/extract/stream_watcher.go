@@ -0,0 +1,170 @@
+package extract
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sync"
+	"time"
+)
+
+const (
+	// streamResyncPeriod is same as resync period of informers
+	streamResyncPeriod = time.Minute * 30
+	streamMinBackoff   = time.Second
+	streamMaxBackoff   = time.Minute
+)
+
+// streamWatcher keeps last known state of applications, so events of argocd stream and lists after reconnect
+// are turned to same add, update and delete events, that informer produces
+type streamWatcher struct {
+	applications    map[string]*unstructured.Unstructured
+	resourceVersion string
+	lock            sync.Mutex
+}
+
+func newStreamWatcher() *streamWatcher {
+	return &streamWatcher{applications: make(map[string]*unstructured.Unstructured)}
+}
+
+func (w *streamWatcher) get(key string) (*unstructured.Unstructured, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	obj, ok := w.applications[key]
+	return obj, ok
+}
+
+func (w *streamWatcher) size() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.applications)
+}
+
+// apply returns false when application is already known with same resource version, e.g. after resync
+func (w *streamWatcher) apply(eventType string, obj *unstructured.Unstructured) bool {
+	key := argo.ApplicationKey(obj.GetNamespace(), obj.GetName())
+
+	w.lock.Lock()
+	old, known := w.applications[key]
+	if eventType == argo.DeletedEvent {
+		delete(w.applications, key)
+	} else {
+		w.applications[key] = obj
+	}
+	w.lock.Unlock()
+
+	if obj.GetResourceVersion() != "" {
+		w.resourceVersion = obj.GetResourceVersion()
+	}
+
+	switch {
+	case eventType == argo.DeletedEvent:
+		OnApplicationDelete(obj)
+	case !known:
+		OnApplicationAdd(obj)
+	case old.GetResourceVersion() == obj.GetResourceVersion():
+		return false
+	default:
+		OnApplicationUpdate(old, obj)
+	}
+	return true
+}
+
+func (w *streamWatcher) handle(event argo.ApplicationEvent) {
+	w.apply(event.Type, &unstructured.Unstructured{Object: event.Application})
+}
+
+// resync lists applications and reports changes, that were missed while stream was disconnected,
+// argocd doesn't keep history of events, so stream can't be resumed from resource version of last received event
+func (w *streamWatcher) resync() error {
+	items, resourceVersion, err := argo.ListApplications()
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(items))
+	changes := 0
+	for _, item := range items {
+		obj := &unstructured.Unstructured{Object: item}
+		listed[argo.ApplicationKey(obj.GetNamespace(), obj.GetName())] = true
+		if w.apply(argo.ModifiedEvent, obj) {
+			changes++
+		}
+	}
+
+	var deleted []*unstructured.Unstructured
+	w.lock.Lock()
+	for key, obj := range w.applications {
+		if !listed[key] {
+			deleted = append(deleted, obj)
+		}
+	}
+	w.lock.Unlock()
+	for _, obj := range deleted {
+		w.apply(argo.DeletedEvent, obj)
+	}
+
+	if resourceVersion != "" {
+		w.resourceVersion = resourceVersion
+	}
+
+	logger.GetLogger().Infof("Resynced %v applications from argocd, changed %v, deleted %v", len(items), changes, len(deleted))
+
+	// projects aren't streamed by argocd, they are updated on every resync
+	sendProjects()
+	return nil
+}
+
+// watch runs stream until it's broken or resync period is over
+func (w *streamWatcher) watch() error {
+	err := w.resync()
+	if err != nil {
+		return err
+	}
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "stream", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), streamResyncPeriod)
+	defer cancel()
+	err = argo.StreamApplications(ctx, w.resourceVersion, w.handle)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil
+	}
+	return err
+}
+
+// WatchStream watches applications with argocd api stream instead of kubernetes informer,
+// so agent needs only argocd token, stream is reconnected with exponential backoff
+func WatchStream() error {
+	watcher := newStreamWatcher()
+	diagnostics.RegisterCounter("applications", watcher.size)
+	argo.SetApplicationCache(applicationCache(watcher.get))
+
+	backoff := streamMinBackoff
+	for {
+		startedAt := time.Now()
+		err := watcher.watch()
+		if err == nil {
+			backoff = streamMinBackoff
+			continue
+		}
+
+		// stream, that worked for a while, is reconnected without waiting for long
+		if time.Since(startedAt) > streamMaxBackoff {
+			backoff = streamMinBackoff
+		}
+		logger.GetLogger().Errorf("Applications stream of argocd is broken, reconnect in %v, reason: %v", backoff, err)
+		diagnostics.ReportError(diagnostics.ArgoSubsystem, "stream", err)
+		if argo.IsUnauthorized(err) {
+			if authErr := argo.Reauthenticate(); authErr != nil {
+				logger.GetLogger().Errorf("Failed to renew argocd session, reason %v", authErr)
+			}
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > streamMaxBackoff {
+			backoff = streamMaxBackoff
+		}
+	}
+}
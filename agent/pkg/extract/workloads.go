@@ -0,0 +1,78 @@
+package extract
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/kube"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"time"
+)
+
+// workloadResources are kinds, that activities of environment are built from
+var workloadResources = map[schema.GroupKind]schema.GroupVersionResource{
+	{Group: "apps", Kind: "Deployment"}:  {Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Kind: "StatefulSet"}: {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "apps", Kind: "DaemonSet"}:   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "apps", Kind: "ReplicaSet"}:  {Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "batch", Kind: "Job"}:        {Group: "batch", Version: "v1", Resource: "jobs"},
+}
+
+// clusterWorkloads serves workloads of cluster, that agent runs in, from informers
+type clusterWorkloads struct {
+	informers map[schema.GroupKind]cache.SharedIndexInformer
+}
+
+func (w *clusterWorkloads) Serves(group string, kind string) bool {
+	_, ok := w.informers[schema.GroupKind{Group: group, Kind: kind}]
+	return ok
+}
+
+func (w *clusterWorkloads) Get(group string, kind string, namespace string, name string) (map[string]interface{}, bool) {
+	informer, ok := w.informers[schema.GroupKind{Group: group, Kind: kind}]
+	if !ok {
+		return nil, false
+	}
+	obj, exists, err := informer.GetStore().GetByKey(argo.ApplicationKey(namespace, name))
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj.(*unstructured.Unstructured).Object, true
+}
+
+// WatchWorkloads starts informers of workloads in cluster of agent, after their sync activities of in-cluster applications
+// are built without requests of managed resources to argocd
+func WatchWorkloads(stop <-chan struct{}) error {
+	config, err := kube.BuildConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(clientset, time.Minute*30)
+	workloads := &clusterWorkloads{informers: make(map[schema.GroupKind]cache.SharedIndexInformer)}
+	var synced []cache.InformerSynced
+	for groupKind, resource := range workloadResources {
+		informer := factory.ForResource(resource).Informer()
+		workloads.informers[groupKind] = informer
+		synced = append(synced, informer.HasSynced)
+	}
+
+	factory.Start(stop)
+	go func() {
+		if !cache.WaitForCacheSync(stop, synced...) {
+			return
+		}
+		transform.SetWorkloadSource(workloads)
+		logger.GetLogger().Info("Workloads of in-cluster applications are taken from cluster")
+	}()
+
+	return nil
+}
@@ -2,12 +2,17 @@ package git
 
 import (
 	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/httpclient"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/replay"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
 	"github.com/google/go-github/github"
 	"github.com/whilp/git-urls"
 	"golang.org/x/oauth2"
+	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type Api struct {
@@ -20,6 +25,21 @@ type Api struct {
 
 var api *Api
 
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+)
+
+// getHttpClient returns base client of github api, oauth2 client takes its transport
+func getHttpClient() *http.Client {
+	httpClientOnce.Do(func() {
+		httpClient = httpclient.New(httpclient.DefaultConfig(), func(tr http.RoundTripper) http.RoundTripper {
+			return tracing.NewTransport(replay.Transport(tr))
+		})
+	})
+	return httpClient
+}
+
 func GetInstance(repoUrl string) (error, *Api) {
 	err, owner, repo := extractRepoAndOwnerFromUrl(repoUrl)
 	if err != nil {
@@ -31,7 +51,8 @@ func GetInstance(repoUrl string) (error, *Api) {
 		return nil, api
 	}
 	gitConfig := store.GetStore().Git
-	ctx := context.Background()
+	// oauth2 client uses http client from context as base one
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, getHttpClient())
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: gitConfig.Token},
 	)
@@ -81,24 +102,24 @@ func extractRepoAndOwnerFromUrl(repoUrl string) (error, string, string) {
 	return nil, "", ""
 }
 
-func (a *Api) GetCommitBySha(sha string) (error, *github.RepositoryCommit) {
-	revisionCommit, _, err := api.Client.Repositories.GetCommit(api.Ctx, api.Owner, api.Repo, sha)
+func (a *Api) GetCommitBySha(ctx context.Context, sha string) (error, *github.RepositoryCommit) {
+	revisionCommit, _, err := api.Client.Repositories.GetCommit(ctx, api.Owner, api.Repo, sha)
 	if err != nil {
 		return err, nil
 	}
 	return nil, revisionCommit
 }
 
-func (a *Api) GetUserByUsername(username string) (error, *github.User) {
-	user, _, err := api.Client.Users.Get(api.Ctx, username)
+func (a *Api) GetUserByUsername(ctx context.Context, username string) (error, *github.User) {
+	user, _, err := api.Client.Users.Get(ctx, username)
 	if err != nil {
 		return err, nil
 	}
 	return nil, user
 }
 
-func (a *Api) GetCommitsBySha(sha string) (error, []*github.RepositoryCommit) {
-	revisionCommit, _, err := api.Client.Repositories.GetCommit(api.Ctx, api.Owner, api.Repo, sha)
+func (a *Api) GetCommitsBySha(ctx context.Context, sha string) (error, []*github.RepositoryCommit) {
+	revisionCommit, _, err := api.Client.Repositories.GetCommit(ctx, api.Owner, api.Repo, sha)
 	if err != nil {
 		return err, nil
 	}
@@ -126,8 +147,8 @@ func (a *Api) GetComittersByCommits(commits []*github.RepositoryCommit) (error,
 	return nil, comitters
 }
 
-func (a *Api) GetIssuesAndPrsByCommits(commits []*github.RepositoryCommit) (error, []Annotation, []Annotation) {
-	allPullRequests, _, err := api.Client.PullRequests.List(api.Ctx, api.Owner, api.Repo, &github.PullRequestListOptions{State: "all"})
+func (a *Api) GetIssuesAndPrsByCommits(ctx context.Context, commits []*github.RepositoryCommit) (error, []Annotation, []Annotation) {
+	allPullRequests, _, err := api.Client.PullRequests.List(ctx, api.Owner, api.Repo, &github.PullRequestListOptions{State: "all"})
 	if err != nil {
 		return err, nil, nil
 	}
@@ -145,7 +166,7 @@ func (a *Api) GetIssuesAndPrsByCommits(commits []*github.RepositoryCommit) (erro
 				continue
 			}
 			if *commit.SHA == *mergeCommitSHA {
-				issue, _, err := api.Client.Issues.Get(api.Ctx, api.Owner, api.Repo, *pr.Number)
+				issue, _, err := api.Client.Issues.Get(ctx, api.Owner, api.Repo, *pr.Number)
 				if err != nil {
 					return err, nil, nil
 				}
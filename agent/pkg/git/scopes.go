@@ -0,0 +1,30 @@
+package git
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	"strings"
+)
+
+// GetTokenScopes returns scopes of github token, that github sends with response to every authenticated request.
+// Scopes are empty for tokens without classic scopes, like fine-grained tokens
+func GetTokenScopes(ctx context.Context, token string) ([]string, error) {
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	client := github.NewClient(oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, getHttpClient()), ts))
+
+	_, resp, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []string{}
+	for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if strings.TrimSpace(scope) != "" {
+			scopes = append(scopes, strings.TrimSpace(scope))
+		}
+	}
+	return scopes, nil
+}
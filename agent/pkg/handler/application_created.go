@@ -20,13 +20,19 @@ func GetApplicationCreatedHandlerInstance() *ApplicationCreatedHandler {
 }
 
 func (applicationCreatedHandler *ApplicationCreatedHandler) Handle(application argo.ArgoApplication) error {
-	if store.GetStore().Codefresh.SyncMode != codefresh.ContinueSync {
+	syncMode := store.GetStore().Codefresh.SyncMode
+	if syncMode != codefresh.ContinueSync && syncMode != codefresh.SelectSync {
 		// ignore handling if autosync disabled
 		return nil
 	}
 
+	if !IsSelectedForSync(application.Metadata, applicationSpec(application)) {
+		return nil
+	}
+
 	api := codefresh.GetInstance()
-	err := api.CreateEnvironment(application.Metadata.Name, application.Spec.Project, application.Metadata.Name)
+	name := argo.QualifiedName(application.Metadata.Namespace, application.Metadata.Name)
+	err := api.CreateEnvironment(name, application.Spec.Project, name)
 	if err != nil {
 		return err
 	}
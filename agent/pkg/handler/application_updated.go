@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+)
+
+type ApplicationUpdatedHandler struct {
+}
+
+var applicationUpdatedHandler *ApplicationUpdatedHandler
+
+func GetApplicationUpdatedHandlerInstance() *ApplicationUpdatedHandler {
+	if applicationUpdatedHandler != nil {
+		return applicationUpdatedHandler
+	}
+	applicationUpdatedHandler = &ApplicationUpdatedHandler{}
+	return applicationUpdatedHandler
+}
+
+// Handle keeps environment in codefresh in line with selection rules when application labels, annotations,
+// project or destination are changed
+func (applicationUpdatedHandler *ApplicationUpdatedHandler) Handle(oldApplication argo.ArgoApplication, newApplication argo.ArgoApplication) error {
+	syncMode := store.GetStore().Codefresh.SyncMode
+	if syncMode != codefresh.ContinueSync && syncMode != codefresh.SelectSync {
+		return nil
+	}
+
+	wasSelected := IsSelectedForSync(oldApplication.Metadata, applicationSpec(oldApplication))
+	isSelected := IsSelectedForSync(newApplication.Metadata, applicationSpec(newApplication))
+
+	if wasSelected == isSelected {
+		return nil
+	}
+
+	api := codefresh.GetInstance()
+	name := argo.QualifiedName(newApplication.Metadata.Namespace, newApplication.Metadata.Name)
+
+	if isSelected {
+		logger.GetLogger().Infof("Application \"%s\" was selected for sync, create environment", name)
+		return api.CreateEnvironment(name, newApplication.Spec.Project, name)
+	}
+
+	logger.GetLogger().Infof("Application \"%s\" isn't selected for sync anymore, remove environment", name)
+	return api.DeleteEnvironment(name)
+}
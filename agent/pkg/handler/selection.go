@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
+	"k8s.io/apimachinery/pkg/labels"
+	"regexp"
+)
+
+// SyncAnnotation allows to opt out application from sync with codefresh, when set to "false"
+const SyncAnnotation = "codefresh.io/sync"
+
+// ParseSyncRules reads json list of sync rules and validates them, it's shared by agent (SYNC_RULES) and installer (--sync-rules)
+func ParseSyncRules(value []byte) ([]store.SyncRule, error) {
+	var rules []store.SyncRule
+	if err := json.Unmarshal(value, &rules); err != nil {
+		return nil, fmt.Errorf("sync rules should be json list, reason %v", err)
+	}
+	if err := ValidateSyncRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ValidateSyncRules checks that label selectors and regexes of rules can be parsed
+func ValidateSyncRules(rules []store.SyncRule) error {
+	for _, rule := range rules {
+		if _, err := labels.Parse(rule.Labels); err != nil {
+			return fmt.Errorf("invalid labels selector \"%s\", reason %v", rule.Labels, err)
+		}
+		if _, err := regexp.Compile(rule.NameRegex); err != nil {
+			return fmt.Errorf("invalid name regex \"%s\", reason %v", rule.NameRegex, err)
+		}
+	}
+	return nil
+}
+
+func isOptedOut(metadata argo.ApplicationMetadata) bool {
+	return metadata.Annotations[SyncAnnotation] == "false"
+}
+
+func containsOrEmpty(arr []string, elements ...string) bool {
+	if len(arr) == 0 {
+		return true
+	}
+	for _, element := range elements {
+		if element != "" && util.Contains(arr, element) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesRule(rule store.SyncRule, metadata argo.ApplicationMetadata, spec argo.ApplicationSpec) bool {
+	if rule.Labels != "" {
+		selector, err := labels.Parse(rule.Labels)
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to parse labels selector \"%s\", reason %v", rule.Labels, err)
+			return false
+		}
+		if !selector.Matches(labels.Set(metadata.Labels)) {
+			return false
+		}
+	}
+
+	if rule.NameRegex != "" {
+		matched, err := regexp.MatchString(rule.NameRegex, metadata.Name)
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to match name regex \"%s\", reason %v", rule.NameRegex, err)
+			return false
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return containsOrEmpty(rule.Projects, spec.Project) &&
+		containsOrEmpty(rule.Namespaces, spec.Destination.Namespace) &&
+		containsOrEmpty(rule.Clusters, spec.Destination.Server, spec.Destination.Name)
+}
+
+// IsSelectedForSync decides if environment should exist in codefresh for application in current sync mode
+func IsSelectedForSync(metadata argo.ApplicationMetadata, spec argo.ApplicationSpec) bool {
+	if isOptedOut(metadata) {
+		return false
+	}
+
+	codefreshConfig := store.GetStore().Codefresh
+
+	switch codefreshConfig.SyncMode {
+	case codefresh.ContinueSync, codefresh.OneTimeSync:
+		return true
+	case codefresh.SelectSync:
+		if util.Contains(codefreshConfig.ApplicationsForSync, argo.QualifiedName(metadata.Namespace, metadata.Name)) {
+			return true
+		}
+		for _, rule := range codefreshConfig.SyncRules {
+			if matchesRule(rule, metadata, spec) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func applicationSpec(application argo.ArgoApplication) argo.ApplicationSpec {
+	return argo.ApplicationSpec{
+		Project:     application.Spec.Project,
+		Destination: application.Spec.Destination,
+	}
+}
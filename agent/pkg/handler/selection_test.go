@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"testing"
+)
+
+func testApplication(name string, labels map[string]string, annotations map[string]string) (argo.ApplicationMetadata, argo.ApplicationSpec) {
+	metadata := argo.ApplicationMetadata{
+		Name:        name,
+		Labels:      labels,
+		Annotations: annotations,
+	}
+	spec := argo.ApplicationSpec{
+		Project: "default",
+		Destination: argo.ApplicationSpecDestination{
+			Server:    "https://kubernetes.default.svc",
+			Namespace: "prod",
+		},
+	}
+	return metadata, spec
+}
+
+func TestSelectionByRules(t *testing.T) {
+	store.SetSyncOptions(codefresh.SelectSync, []string{"listed"})
+	store.SetSyncRules([]store.SyncRule{
+		{Labels: "team=a"},
+		{NameRegex: "^payments-", Projects: []string{"default"}, Namespaces: []string{"prod"}},
+		{Clusters: []string{"in-cluster"}},
+	})
+	defer store.SetSyncRules(nil)
+
+	cases := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		expected    bool
+	}{
+		{"listed", nil, nil, true},
+		{"other", map[string]string{"team": "a"}, nil, true},
+		{"other", map[string]string{"team": "b"}, nil, false},
+		{"payments-api", nil, nil, true},
+		{"api-payments", nil, nil, false},
+		{"payments-api", nil, map[string]string{SyncAnnotation: "false"}, false},
+		{"listed", nil, map[string]string{SyncAnnotation: "false"}, false},
+	}
+
+	for _, c := range cases {
+		metadata, spec := testApplication(c.name, c.labels, c.annotations)
+		if IsSelectedForSync(metadata, spec) != c.expected {
+			t.Errorf("'IsSelectedForSync' failed for application \"%s\" with labels %v, expected %v", c.name, c.labels, c.expected)
+		}
+	}
+}
+
+func TestSelectionOptOutInContinueSync(t *testing.T) {
+	store.SetSyncOptions(codefresh.ContinueSync, []string{})
+
+	metadata, spec := testApplication("app", nil, nil)
+	if !IsSelectedForSync(metadata, spec) {
+		t.Errorf("Application should be selected during ContinueSync mode")
+	}
+
+	metadata, spec = testApplication("app", nil, map[string]string{SyncAnnotation: "false"})
+	if IsSelectedForSync(metadata, spec) {
+		t.Errorf("Application with opt out annotation should not be selected")
+	}
+}
+
+func TestValidateSyncRules(t *testing.T) {
+	if ValidateSyncRules([]store.SyncRule{{Labels: "team in (a"}}) == nil {
+		t.Errorf("Invalid labels selector should fail validation")
+	}
+
+	if ValidateSyncRules([]store.SyncRule{{NameRegex: "("}}) == nil {
+		t.Errorf("Invalid name regex should fail validation")
+	}
+
+	if ValidateSyncRules([]store.SyncRule{{Labels: "team=a", NameRegex: "^app"}}) != nil {
+		t.Errorf("Valid rule should pass validation")
+	}
+}
+
+func TestParseSyncRules(t *testing.T) {
+	rules, err := ParseSyncRules([]byte(`[{"labels":"team=a","projects":["default"]}]`))
+	if err != nil || len(rules) != 1 || rules[0].Labels != "team=a" {
+		t.Errorf("'ParseSyncRules' failed, expected '%v', got '%v', reason %v", "team=a", rules, err)
+	}
+
+	if _, err = ParseSyncRules([]byte(`{"labels":"team=a"}`)); err == nil {
+		t.Errorf("'ParseSyncRules' failed, expected error for rules that aren't json list")
+	}
+
+	if _, err = ParseSyncRules([]byte(`[{"nameRegex":"("}]`)); err == nil {
+		t.Errorf("'ParseSyncRules' failed, expected error for invalid name regex")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
-	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
 	"strings"
 )
 
@@ -42,7 +41,11 @@ func (syncHandler *SyncHandler) Handle() error {
 			return err
 		}
 		for _, application := range applications {
-			err = syncHandler.codefreshApi.CreateEnvironment(application.Metadata.Name, application.Spec.Project, application.Metadata.Name)
+			if !IsSelectedForSync(application.Metadata, application.Spec) {
+				continue
+			}
+			name := argo.QualifiedName(application.Metadata.Namespace, application.Metadata.Name)
+			err = syncHandler.codefreshApi.CreateEnvironment(name, application.Spec.Project, name)
 			if err != nil {
 				logger.GetLogger().Errorf("Failed to create environment, reason %v", err)
 			}
@@ -51,15 +54,16 @@ func (syncHandler *SyncHandler) Handle() error {
 
 	if syncMode == codefresh.SelectSync {
 		selectedApps := store.GetStore().Codefresh.ApplicationsForSync
-		logger.GetLogger().Infof("Start sync applications: %v", strings.Join(selectedApps, ","))
+		logger.GetLogger().Infof("Start sync applications: %v, rules amount: %v", strings.Join(selectedApps, ","), len(store.GetStore().Codefresh.SyncRules))
 
 		applications, err := syncHandler.argoApi.GetApplicationsWithCredentialsFromStorage()
 		if err != nil {
 			return err
 		}
 		for _, application := range applications {
-			if util.Contains(selectedApps, application.Metadata.Name) {
-				err = syncHandler.codefreshApi.CreateEnvironment(application.Metadata.Name, application.Spec.Project, application.Metadata.Name)
+			name := argo.QualifiedName(application.Metadata.Namespace, application.Metadata.Name)
+			if IsSelectedForSync(application.Metadata, application.Spec) {
+				err = syncHandler.codefreshApi.CreateEnvironment(name, application.Spec.Project, name)
 				if err != nil {
 					logger.GetLogger().Errorf("Failed to create environment, reason %v", err)
 				}
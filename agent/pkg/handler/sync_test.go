@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
@@ -12,15 +13,35 @@ var createdEnv []string
 type MockArgoApi struct {
 }
 
-func (api *MockArgoApi) GetResourceTree(applicationName string) (*argo.ResourceTree, error) {
+func (api *MockArgoApi) GetResourceTreeAll(ctx context.Context, applicationName string, applicationNamespace string) (interface{}, error) {
 	panic("implement me")
 }
 
-func (api *MockArgoApi) GetResourceTreeAll(applicationName string) (interface{}, error) {
+func (api *MockArgoApi) GetManagedResources(ctx context.Context, applicationName string, applicationNamespace string) (*argo.ManagedResource, error) {
 	panic("implement me")
 }
 
-func (api *MockArgoApi) GetManagedResources(applicationName string) (*argo.ManagedResource, error) {
+func (api *MockArgoApi) GetProject(ctx context.Context, name string) (*argo.ProjectItem, error) {
+	panic("implement me")
+}
+
+func (api *MockArgoApi) GetVersion() (string, error) {
+	panic("implement me")
+}
+
+func (api *MockArgoApi) SyncApplication(applicationName string, applicationNamespace string) error {
+	panic("implement me")
+}
+
+func (api *MockArgoApi) RefreshApplication(applicationName string, applicationNamespace string, hard bool) error {
+	panic("implement me")
+}
+
+func (api *MockArgoApi) RollbackApplication(applicationName string, applicationNamespace string, historyId int64) error {
+	panic("implement me")
+}
+
+func (api *MockArgoApi) TerminateOperation(applicationName string, applicationNamespace string) error {
 	panic("implement me")
 }
 
@@ -1,15 +1,103 @@
 package heartbeat
 
 import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/kube"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/queue"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/redact"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/sink"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"k8s.io/client-go/discovery"
+	"sort"
+	"strings"
 )
 
+// versionsRefreshPeriod is amount of heartbeats, after that versions of argocd and kubernetes are requested again
+const versionsRefreshPeriod = 100
+
 var heartbeatAmount = 0
 
+var (
+	argoVersion       string
+	kubernetesVersion string
+)
+
+func getKubernetesVersion() (string, error) {
+	config, err := kube.BuildConfig()
+	if err != nil {
+		return "", err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
+
+func refreshVersions() {
+	version, err := argo.GetInstance().GetVersion()
+	if err != nil {
+		logger.GetLogger().Warnf("Failed to retrieve argocd version, reason %v", err)
+	} else {
+		argoVersion = version
+	}
+
+	version, err = getKubernetesVersion()
+	if err != nil {
+		logger.GetLogger().Warnf("Failed to retrieve kubernetes version, reason %v", err)
+	} else {
+		kubernetesVersion = version
+	}
+}
+
+// errorSummary joins current errors of subsystems to single string of legacy "error" field
+func errorSummary(errors map[string]diagnostics.SubsystemError) string {
+	subsystems := make([]string, 0, len(errors))
+	for subsystem := range errors {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+
+	messages := make([]string, 0, len(subsystems))
+	for _, subsystem := range subsystems {
+		messages = append(messages, subsystem+": "+errors[subsystem].Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Build collects diagnostics of agent, that are sent with heartbeat
+func Build() codefresh.Heartbeat {
+	errors := diagnostics.Errors()
+
+	return codefresh.Heartbeat{
+		Error:             errorSummary(errors),
+		ArgoVersion:       argoVersion,
+		KubernetesVersion: kubernetesVersion,
+		Applications:      diagnostics.Count("applications"),
+		Projects:          diagnostics.Count("projects"),
+		QueueDepth:        queue.GetInstance().Size(),
+		OutboxBacklog:     sink.GetDispatcherInstance().Backlog(),
+		LastEventAt:       diagnostics.LastEventAt(),
+		Errors:            errors,
+		SyncMode:          store.GetStore().Codefresh.SyncMode,
+		Features:          diagnostics.Features(),
+		Redactions:        redact.GetInstance().Counts(),
+	}
+}
+
 func HeartBeatTask() {
-	err := codefresh.GetInstance().HeartBeat(store.GetStore().Heartbeat.Error)
+	if heartbeatAmount%versionsRefreshPeriod == 0 {
+		refreshVersions()
+	}
+
+	err := codefresh.GetInstance().HeartBeat(Build())
 	if err != nil {
 		logger.GetLogger().Errorf("Failed to send heartbeat status, reason %v", err)
 	}
@@ -19,3 +107,12 @@ func HeartBeatTask() {
 		logger.GetLogger().Infof("Im still alive, heartbeat amount %v", heartbeatAmount)
 	}
 }
+
+// SendFatalError reports error, that stops agent, before it dies
+func SendFatalError(subsystem string, err error) {
+	diagnostics.ReportError(subsystem, "fatal", err)
+	sendErr := codefresh.GetInstance().HeartBeat(Build())
+	if sendErr != nil {
+		logger.GetLogger().Errorf("Failed to send heartbeat status, reason %v", sendErr)
+	}
+}
@@ -0,0 +1,161 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config is tuning of client of single backend
+type Config struct {
+	// Timeout limits whole request including reading of body, streams use zero timeout
+	Timeout               time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConnsPerHost   int
+	InsecureSkipVerify    bool
+	// Retries is amount of additional attempts of idempotent requests
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+var (
+	defaultTimeout = 30 * time.Second
+	defaultRetries = 3
+	lock           sync.RWMutex
+)
+
+// Configure sets timeout and retries of clients, that are created after it
+func Configure(timeout time.Duration, retries int) {
+	lock.Lock()
+	defer lock.Unlock()
+	defaultTimeout = timeout
+	defaultRetries = retries
+}
+
+// DefaultConfig returns config with configured timeout and retries
+func DefaultConfig() Config {
+	lock.RLock()
+	defer lock.RUnlock()
+	return Config{
+		Timeout:               defaultTimeout,
+		DialTimeout:           10 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: defaultTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   10,
+		Retries:               defaultRetries,
+		RetryBackoff:          500 * time.Millisecond,
+	}
+}
+
+// NewTransport creates transport, that reuses connections and honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+func NewTransport(config Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewRetryTransport retries idempotent requests, that failed with network error or transient status, with exponential backoff and jitter
+func NewRetryTransport(base http.RoundTripper, retries int, backoff time.Duration) http.RoundTripper {
+	return &retryTransport{base: base, retries: retries, backoff: backoff}
+}
+
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// jitter returns random duration from half to full backoff, so agents don't retry at the same moment
+func jitter(backoff time.Duration) time.Duration {
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) || t.retries <= 0 || (req.Body != nil && req.GetBody == nil) {
+		return t.base.RoundTrip(req)
+	}
+
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.retries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil && !isTransientStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if sleepErr := sleep(req.Context(), jitter(backoff)); sleepErr != nil {
+			return nil, sleepErr
+		}
+		backoff *= 2
+	}
+}
+
+// New creates client with tuned transport, wrap is applied to retrying transport, e.g. to add tracing
+func New(config Config, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	var transport http.RoundTripper = NewRetryTransport(NewTransport(config), config.Retries, config.RetryBackoff)
+	if wrap != nil {
+		transport = wrap(transport)
+	}
+	return &http.Client{Transport: transport, Timeout: config.Timeout}
+}
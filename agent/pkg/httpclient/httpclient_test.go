@@ -0,0 +1,80 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	attempts := 0
+	failures := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Retries = 2
+	config.RetryBackoff = time.Millisecond
+	client := New(config, nil)
+
+	cases := []struct {
+		name             string
+		method           string
+		failures         int
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{"success", http.MethodGet, 0, http.StatusOK, 1},
+		{"retried", http.MethodGet, 2, http.StatusOK, 3},
+		{"retries exhausted", http.MethodGet, 5, http.StatusServiceUnavailable, 3},
+		{"retried put with body", http.MethodPut, 1, http.StatusOK, 2},
+		{"post isn't retried", http.MethodPost, 1, http.StatusServiceUnavailable, 1},
+	}
+
+	for _, c := range cases {
+		attempts = 0
+		failures = c.failures
+		req, _ := http.NewRequest(c.method, server.URL, strings.NewReader("{}"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("'Do' failed for %s, reason %v", c.name, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.expectedStatus || attempts != c.expectedAttempts {
+			t.Errorf("'Do' failed for %s, expected '%v %v', got '%v %v'", c.name, c.expectedStatus, c.expectedAttempts, resp.StatusCode, attempts)
+		}
+	}
+}
+
+func TestRetryTransportCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Retries = 5
+	config.RetryBackoff = time.Hour
+	client := New(config, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	started := time.Now()
+	_, err := client.Do(req)
+	if err == nil || time.Since(started) > 5*time.Second {
+		t.Errorf("'Do' failed, expected cancellation of backoff, got '%v' after %v", err, time.Since(started))
+	}
+}
@@ -1,6 +1,11 @@
 package kube
 
 import (
+	"errors"
+	// only gcp and oidc auth providers of kubeconfig are linked, azure one depends on vulnerable jwt-go,
+	// exec plugins (like "aws eks get-token" or "kubelogin") are supported by client-go itself
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"os"
@@ -8,6 +13,9 @@ import (
 	"strconv"
 )
 
+// BuildConfig creates config of kubernetes client from environment:
+// in cluster service account, MASTERURL with bearer token or client certificate, or kubeconfig file.
+// Tokens from files (service account, BEARERTOKEN_FILE) and exec plugins are refreshed by client without restart
 func BuildConfig() (*rest.Config, error) {
 	inCluster, _ := strconv.ParseBool(os.Getenv("IN_CLUSTER"))
 	if inCluster {
@@ -15,13 +23,7 @@ func BuildConfig() (*rest.Config, error) {
 	}
 
 	if os.Getenv("MASTERURL") != "" {
-		cfg, err := clientcmd.BuildConfigFromFlags(os.Getenv("MASTERURL"), "")
-		if err != nil {
-			return nil, err
-		}
-		cfg.BearerToken = os.Getenv("BEARERTOKEN")
-		cfg.Insecure = true
-		return cfg, nil
+		return buildMasterUrlConfig(os.Getenv("MASTERURL"))
 	}
 
 	kubeconfig := os.Getenv("KUBECONFIG")
@@ -31,5 +33,36 @@ func BuildConfig() (*rest.Config, error) {
 		)
 	}
 
-	return clientcmd.BuildConfigFromFlags("", kubeconfig)
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: os.Getenv("KUBECONTEXT")},
+	).ClientConfig()
+}
+
+func buildMasterUrlConfig(masterUrl string) (*rest.Config, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags(masterUrl, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.BearerToken = os.Getenv("BEARERTOKEN")
+	// file is reread every minute, so rotated token is used without restart
+	cfg.BearerTokenFile = os.Getenv("BEARERTOKEN_FILE")
+
+	clientCert, clientKey := os.Getenv("CLIENT_CERT"), os.Getenv("CLIENT_KEY")
+	if (clientCert == "") != (clientKey == "") {
+		return nil, errors.New("CLIENT_CERT and CLIENT_KEY should be set together")
+	}
+	cfg.CertFile = clientCert
+	cfg.KeyFile = clientKey
+
+	caCert := os.Getenv("CA_CERT")
+	if caCert != "" {
+		cfg.CAFile = caCert
+	} else {
+		// certificate of api server isn't verified without CA_CERT, like in previous versions of agent
+		cfg.Insecure = true
+	}
+
+	return cfg, nil
 }
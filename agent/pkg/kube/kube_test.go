@@ -1,7 +1,10 @@
 package kube
 
 import (
+	"io/ioutil"
+	"k8s.io/client-go/rest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -22,3 +25,170 @@ func TestGetConfigWithCustomCreds(t *testing.T) {
 	}
 
 }
+
+func TestGetConfigWithClientCertificate(t *testing.T) {
+	_ = os.Setenv("IN_CLUSTER", "false")
+	_ = os.Setenv("MASTERURL", "https://cluster")
+	_ = os.Setenv("CLIENT_CERT", "/certs/tls.crt")
+	_ = os.Setenv("CLIENT_KEY", "/certs/tls.key")
+	_ = os.Setenv("CA_CERT", "/certs/ca.crt")
+	_ = os.Setenv("BEARERTOKEN_FILE", "/var/run/token")
+	defer func() {
+		for _, name := range []string{"MASTERURL", "CLIENT_CERT", "CLIENT_KEY", "CA_CERT", "BEARERTOKEN_FILE"} {
+			_ = os.Unsetenv(name)
+		}
+	}()
+
+	conf, err := BuildConfig()
+
+	if err != nil {
+		t.Errorf("'BuildConfig' failed, reason %v", err)
+		return
+	}
+	if conf.CertFile != "/certs/tls.crt" || conf.KeyFile != "/certs/tls.key" {
+		t.Errorf("'BuildConfig' failed, expected '%v', got '%v'", "/certs/tls.crt /certs/tls.key", conf.CertFile+" "+conf.KeyFile)
+	}
+	if conf.Insecure || conf.CAFile != "/certs/ca.crt" {
+		t.Errorf("'BuildConfig' failed, expected server certificate to be verified with '%v', got '%v'", "/certs/ca.crt", conf.CAFile)
+	}
+	if conf.BearerTokenFile != "/var/run/token" {
+		t.Errorf("'BuildConfig' failed, expected '%v', got '%v'", "/var/run/token", conf.BearerTokenFile)
+	}
+
+	_ = os.Unsetenv("CLIENT_KEY")
+	_, err = BuildConfig()
+	if err == nil {
+		t.Errorf("'BuildConfig' failed, expected error when CLIENT_KEY is missing")
+	}
+}
+
+func TestGetConfigFromKubeconfigContext(t *testing.T) {
+	kubeconfig := `apiVersion: v1
+kind: Config
+current-context: local
+clusters:
+- name: local
+  cluster:
+    server: https://local
+- name: eks
+  cluster:
+    server: https://eks
+contexts:
+- name: local
+  context:
+    cluster: local
+    user: local
+- name: eks
+  context:
+    cluster: eks
+    user: eks
+users:
+- name: local
+  user:
+    token: local-token
+- name: eks
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1alpha1
+      command: aws
+      args: ["eks", "get-token", "--cluster-name", "agent"]
+`
+	file, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("failed to create kubeconfig, reason %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString(kubeconfig)
+	_ = file.Close()
+
+	_ = os.Setenv("IN_CLUSTER", "false")
+	_ = os.Unsetenv("MASTERURL")
+	_ = os.Setenv("KUBECONFIG", file.Name())
+	_ = os.Setenv("KUBECONTEXT", "eks")
+	defer os.Unsetenv("KUBECONFIG")
+	defer os.Unsetenv("KUBECONTEXT")
+
+	conf, err := BuildConfig()
+
+	if err != nil {
+		t.Errorf("'BuildConfig' failed, reason %v", err)
+		return
+	}
+	if conf.Host != "https://eks" {
+		t.Errorf("'BuildConfig' failed, expected '%v', got '%v'", "https://eks", conf.Host)
+	}
+	if conf.ExecProvider == nil || conf.ExecProvider.Command != "aws" {
+		t.Errorf("'BuildConfig' failed, expected exec provider with command '%v', got '%v'", "aws", conf.ExecProvider)
+	}
+}
+
+func TestGetConfigForAksWithKubelogin(t *testing.T) {
+	kubeconfig := `apiVersion: v1
+kind: Config
+current-context: aks
+clusters:
+- name: aks
+  cluster:
+    server: https://aks.hcp.westeurope.azmk8s.io:443
+contexts:
+- name: aks
+  context:
+    cluster: aks
+    user: kubelogin
+- name: aks-legacy
+  context:
+    cluster: aks
+    user: azure
+users:
+- name: kubelogin
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: kubelogin
+      args: ["get-token", "--login", "msi", "--server-id", "6dae42f8-4368-4678-94ff-3960e28e3630"]
+- name: azure
+  user:
+    auth-provider:
+      name: azure
+      config:
+        apiserver-id: 6dae42f8-4368-4678-94ff-3960e28e3630
+`
+	file, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("failed to create kubeconfig, reason %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString(kubeconfig)
+	_ = file.Close()
+
+	_ = os.Setenv("IN_CLUSTER", "false")
+	_ = os.Unsetenv("MASTERURL")
+	_ = os.Setenv("KUBECONFIG", file.Name())
+	defer os.Unsetenv("KUBECONFIG")
+	defer os.Unsetenv("KUBECONTEXT")
+
+	conf, err := BuildConfig()
+
+	if err != nil {
+		t.Errorf("'BuildConfig' failed, reason %v", err)
+		return
+	}
+	if conf.ExecProvider == nil || conf.ExecProvider.Command != "kubelogin" {
+		t.Errorf("'BuildConfig' failed, expected exec provider with command '%v', got '%v'", "kubelogin", conf.ExecProvider)
+		return
+	}
+	if _, err = rest.TransportFor(conf); err != nil {
+		t.Errorf("'BuildConfig' failed, expected client with kubelogin exec plugin, reason %v", err)
+	}
+
+	// azure auth provider isn't linked into agent, so client can't be created with it
+	_ = os.Setenv("KUBECONTEXT", "aks-legacy")
+	conf, err = BuildConfig()
+	if err != nil {
+		t.Errorf("'BuildConfig' failed, reason %v", err)
+		return
+	}
+	if _, err = rest.TransportFor(conf); err == nil || !strings.Contains(err.Error(), "azure") {
+		t.Errorf("'BuildConfig' failed, expected error of unsupported azure auth provider")
+	}
+}
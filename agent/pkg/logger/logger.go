@@ -1,44 +1,179 @@
 package logger
 
 import (
-	"flag"
-	"github.com/golang/glog"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+const (
+	JsonFormat    = "json"
+	ConsoleFormat = "console"
+)
+
+// Standard fields, that allow to find everything that happened with single application
+const (
+	AppField       = "app"
+	NamespaceField = "namespace"
+	ProjectField   = "project"
+	RevisionField  = "revision"
+	QueueKeyField  = "queueKey"
+	RequestIdField = "requestId"
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+type Fields map[string]interface{}
+
 type Logger struct {
+	fields Fields
+}
+
+var (
+	logger                 = &Logger{fields: Fields{}}
+	minLevel               = InfoLevel
+	outputFormat           = ConsoleFormat
+	out          io.Writer = os.Stderr
+	lock         sync.Mutex
+)
+
+// ParseLevel converts level name like "debug" to Level
+func ParseLevel(name string) (Level, error) {
+	for lvl, levelName := range levelNames {
+		if strings.EqualFold(levelName, name) {
+			return lvl, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level \"%s\"", name)
 }
 
-var logger *Logger
+// Configure sets minimal level and output format (json or console) of all loggers
+func Configure(newLevel Level, newFormat string) {
+	lock.Lock()
+	defer lock.Unlock()
+	minLevel = newLevel
+	if newFormat == JsonFormat {
+		outputFormat = JsonFormat
+	} else {
+		outputFormat = ConsoleFormat
+	}
+}
 
 func GetLogger() *Logger {
-	if logger == nil {
-		_ = flag.Set("logtostderr", "true")
-		_ = flag.Set("stderrthreshold", "WARNING")
-		_ = flag.Set("v", "2")
-		// NOTE: This next line is key you have to call flag.Parse() for the command line
-		// options or "flags" that are defined in the glog module to be picked up.
-		flag.Parse()
-		logger = &Logger{}
-	}
 	return logger
 }
 
+// WithFields returns logger, that adds fields to every message
+func (log *Logger) WithFields(fields Fields) *Logger {
+	result := Fields{}
+	for key, value := range log.fields {
+		result[key] = value
+	}
+	for key, value := range fields {
+		result[key] = value
+	}
+	return &Logger{fields: result}
+}
+
+func (log *Logger) WithField(key string, value interface{}) *Logger {
+	return log.WithFields(Fields{key: value})
+}
+
+func (log *Logger) write(lvl Level, msg string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if lvl < minLevel {
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	if outputFormat == JsonFormat {
+		entry := make(map[string]interface{}, len(log.fields)+3)
+		for key, value := range log.fields {
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			entry[key] = value
+		}
+		entry["time"] = now
+		entry["level"] = levelNames[lvl]
+		entry["msg"] = msg
+		line, err := json.Marshal(entry)
+		if err != nil {
+			line = []byte(fmt.Sprintf(`{"time":%q,"level":"error","msg":"failed to marshal log entry, reason %v"}`, now, err))
+		}
+		_, _ = out.Write(append(line, '\n'))
+		return
+	}
+
+	keys := make([]string, 0, len(log.fields))
+	for key := range log.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%s %-5s %s", now, strings.ToUpper(levelNames[lvl]), msg))
+	for _, key := range keys {
+		builder.WriteString(fmt.Sprintf(" %s=%v", key, log.fields[key]))
+	}
+	builder.WriteString("\n")
+	_, _ = io.WriteString(out, builder.String())
+}
+
+func (log *Logger) Debug(msg string) {
+	log.write(DebugLevel, msg)
+}
+
+func (log *Logger) Debugf(format string, args ...interface{}) {
+	log.write(DebugLevel, fmt.Sprintf(format, args...))
+}
+
 func (log *Logger) Info(msg string) {
-	glog.Info(msg)
+	log.write(InfoLevel, msg)
 }
 
 func (log *Logger) Infof(format string, args ...interface{}) {
-	glog.Infof(format, args...)
+	log.write(InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (log *Logger) Warn(msg string) {
+	log.write(WarnLevel, msg)
+}
+
+func (log *Logger) Warnf(format string, args ...interface{}) {
+	log.write(WarnLevel, fmt.Sprintf(format, args...))
 }
 
 func (log *Logger) Errorf(format string, args ...interface{}) {
-	glog.Errorf(format, args...)
+	log.write(ErrorLevel, fmt.Sprintf(format, args...))
 }
 
 func (log *Logger) Error(msg string) {
-	glog.Error(msg)
+	log.write(ErrorLevel, msg)
 }
 
 func (log *Logger) ErrorE(err error) {
-	glog.Error(err.Error())
+	log.write(ErrorLevel, err.Error())
 }
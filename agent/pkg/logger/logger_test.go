@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJsonOutputWithFields(t *testing.T) {
+	var buffer bytes.Buffer
+	out = &buffer
+	Configure(InfoLevel, JsonFormat)
+	defer Configure(InfoLevel, ConsoleFormat)
+
+	log := GetLogger().WithFields(Fields{AppField: "app", ProjectField: "default"}).WithField(RevisionField, "123")
+	log.Debug("skipped")
+	log.Infof("sent %v", 1)
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Debug message should be skipped with info level, got %v", lines)
+	}
+
+	var entry map[string]interface{}
+	err := json.Unmarshal([]byte(lines[0]), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry["msg"] != "sent 1" || entry["level"] != "info" || entry[AppField] != "app" || entry[ProjectField] != "default" || entry[RevisionField] != "123" {
+		t.Errorf("Unexpected log entry %v", entry)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	lvl, err := ParseLevel("WARN")
+	if err != nil || lvl != WarnLevel {
+		t.Errorf("'ParseLevel' failed, expected %v, got %v", WarnLevel, lvl)
+	}
+
+	_, err = ParseLevel("verbose")
+	if err == nil {
+		t.Errorf("'ParseLevel' should fail for unknown level")
+	}
+}
@@ -1,31 +1,81 @@
 package queue
 
 import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sync"
+	"time"
 )
 
+// Item is application update, that waits for processing
+type Item struct {
+	Object *unstructured.Unstructured
+	// SpanContext links processing of item with informer event, that enqueued it
+	SpanContext tracing.SpanContext
+	EnqueuedAt  time.Time
+	// Attempts is amount of failed processings of item
+	Attempts int
+	// generation grows with every enqueued update, so failed item can be compared with newer updates of application
+	generation uint64
+}
+
 // ItemQueue the queue of Items
 type ItemQueue struct {
-	items []*unstructured.Unstructured
+	items []*Item
 	lock  sync.RWMutex
+	// generation is generation of last enqueued Item, latest keeps it per application
+	generation uint64
+	latest     map[string]uint64
 }
 
 // New creates a new ItemQueue
 func (s *ItemQueue) New() *ItemQueue {
-	s.items = make([]*unstructured.Unstructured, 0)
+	s.items = make([]*Item, 0)
+	s.latest = make(map[string]uint64)
 	return s
 }
 
-// Enqueue adds an Item to the end of the queue
-func (s *ItemQueue) Enqueue(t *unstructured.Unstructured) {
+// Enqueue adds an Item to the end of the queue, pending Item of the same application is replaced with newer one
+func (s *ItemQueue) Enqueue(ctx context.Context, t *unstructured.Unstructured) {
 	s.lock.Lock()
-	s.items = append(s.items, t)
-	s.lock.Unlock()
+	defer s.lock.Unlock()
+	newItem := &Item{
+		Object:      t,
+		SpanContext: tracing.SpanFromContext(ctx).Context(),
+		EnqueuedAt:  time.Now(),
+	}
+	key := argo.ApplicationKey(t.GetNamespace(), t.GetName())
+	s.generation++
+	newItem.generation = s.generation
+	s.latest[key] = s.generation
+	for i, item := range s.items {
+		if argo.ApplicationKey(item.Object.GetNamespace(), item.Object.GetName()) == key {
+			// keep time of first update, so wait in queue isn't hidden by newer updates
+			newItem.EnqueuedAt = item.EnqueuedAt
+			s.items[i] = newItem
+			return
+		}
+	}
+	s.items = append(s.items, newItem)
+}
+
+// Requeue returns failed Item to the end of the queue, it's dropped when newer Item of the same application was enqueued
+// after it, no matter if newer Item is pending, was already processed or waits for retry itself
+func (s *ItemQueue) Requeue(item *Item) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := argo.ApplicationKey(item.Object.GetNamespace(), item.Object.GetName())
+	if item.generation < s.latest[key] {
+		return false
+	}
+	s.items = append(s.items, item)
+	return true
 }
 
 // Dequeue removes an Item from the start of the queue
-func (s *ItemQueue) Dequeue() *unstructured.Unstructured {
+func (s *ItemQueue) Dequeue() *Item {
 	s.lock.Lock()
 	item := s.items[0]
 	s.items = s.items[1:len(s.items)]
@@ -34,7 +84,7 @@ func (s *ItemQueue) Dequeue() *unstructured.Unstructured {
 }
 
 // Front returns the item next in the queue, without removing it
-func (s *ItemQueue) Front() *unstructured.Unstructured {
+func (s *ItemQueue) Front() *Item {
 	s.lock.RLock()
 	item := s.items[0]
 	s.lock.RUnlock()
@@ -43,10 +93,14 @@ func (s *ItemQueue) Front() *unstructured.Unstructured {
 
 // IsEmpty returns true if the queue is empty
 func (s *ItemQueue) IsEmpty() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return len(s.items) == 0
 }
 
 // Size returns the number of Items in the queue
 func (s *ItemQueue) Size() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return len(s.items)
 }
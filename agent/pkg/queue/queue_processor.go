@@ -1,16 +1,27 @@
 package queue
 
 import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/sink"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/transform"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/util/comparator"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"time"
 )
 
+const (
+	maxAttempts   = 5
+	minRetryDelay = 2 * time.Second
+	maxRetryDelay = time.Minute
+)
+
 type QueueProcessor interface {
 	Run()
 }
@@ -27,33 +38,113 @@ func (processor *EnvQueueProcessor) New() QueueProcessor {
 	return envQueueProcessor
 }
 
-func updateEnv(obj *unstructured.Unstructured) (error, *codefresh.Environment) {
+func updateEnv(item *Item) (error, *codefresh.Environment) {
+	obj := item.Object
+	key := argo.ApplicationKey(obj.GetNamespace(), obj.GetName())
+	log := logger.GetLogger().WithField(logger.QueueKeyField, key)
+
+	// processing continues trace of informer event, that enqueued item
+	ctx := tracing.ContextWithRemoteSpanContext(context.Background(), item.SpanContext)
+	_, waitSpan := tracing.StartAt(ctx, "queue.Wait", tracing.KindInternal, item.EnqueuedAt)
+	waitSpan.End()
+
+	ctx, span := tracing.Start(ctx, "queue.Process", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute(logger.QueueKeyField, key)
+
+	log.Debug("Start processing application update")
+
 	envTransformer := transform.GetEnvTransformerInstance(argo.GetInstance())
-	err, env := envTransformer.PrepareEnvironment(obj.Object)
+	err, env := envTransformer.PrepareEnvironment(ctx, obj.Object)
 	if err != nil {
+		span.RecordError(err)
 		return err, env
 	}
+	transform.ObserveDeployment(obj.Object, *env)
 
 	envComparator := comparator.EnvComparator{}
 
-	err = util.ProcessDataWithFilter("environment", &env.Name, env, envComparator.Compare, func() error {
-		_, err = codefresh.GetInstance().SendEnvironment(*env)
-		return err
+	err = util.ProcessDataWithFilter("environment", &key, env, envComparator.Normalize, func() error {
+		return sink.GetDispatcherInstance().Dispatch(ctx, sink.EnvironmentUpdated, *env)
 	})
+	span.RecordError(err)
 
-	return nil, env
+	return err, env
+}
+
+// ForgetEnvironment removes hash of environment from state, so next update of application is sent even without changes.
+// It's used when update, that was already accepted, couldn't be delivered
+func ForgetEnvironment(name string) {
+	namespace, appName := argo.ParseQualifiedName(name)
+	if namespace == "" {
+		namespace = store.GetStore().Argo.Namespace
+	}
+	key := argo.ApplicationKey(namespace, appName)
+	state.GetInstance().Delete(util.StateKey("environment", &key))
+}
+
+// retryDelay grows exponentially with attempts, delay requested by rate limited backend is respected
+func retryDelay(err error, attempts int) time.Duration {
+	delay := minRetryDelay << uint(attempts-1)
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	if retryAfter := apierrors.RetryAfterOf(err); retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// processItem retries failed updates in background when retries are enabled
+func processItem(item *Item, retries bool) {
+	err, _ := updateEnv(item)
+	if err == nil {
+		return
+	}
+
+	log := logger.GetLogger().WithFields(logger.Fields{
+		logger.QueueKeyField: argo.ApplicationKey(item.Object.GetNamespace(), item.Object.GetName()),
+		logger.AppField:      item.Object.GetName(),
+	})
+
+	retry := retries && apierrors.IsRetryable(err)
+	if retries && argo.IsUnauthorized(err) {
+		authErr := argo.Reauthenticate()
+		if authErr != nil {
+			log.Errorf("Failed to renew argocd session, reason %v", authErr)
+		}
+		retry = authErr == nil
+	}
+
+	item.Attempts++
+	if !retry || item.Attempts >= maxAttempts {
+		// permanent errors, like missing application or forbidden request, won't disappear on retry,
+		// environment is updated again on next change of application
+		log.Errorf("Failed to update environment, drop update after %v attempts, reason: %v", item.Attempts, err)
+		return
+	}
+
+	delay := retryDelay(err, item.Attempts)
+	log.Warnf("Failed to update environment, retry in %v, reason: %v", delay, err)
+	time.AfterFunc(delay, func() {
+		GetInstance().Requeue(item)
+	})
 }
 
 func (processor *EnvQueueProcessor) Run() {
 	itemQueue := GetInstance()
 	for true {
 		if itemQueue.Size() > 0 {
-			item := itemQueue.Dequeue()
-			err, _ := updateEnv(item)
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to update environment, reason: %v", err)
-			}
+			processItem(itemQueue.Dequeue(), true)
 		}
 		time.Sleep(1 * time.Second)
 	}
 }
+
+// Drain processes all queued items synchronously without retries, it's used instead of Run when order of produced requests matters
+func (processor *EnvQueueProcessor) Drain() {
+	itemQueue := GetInstance()
+	for itemQueue.Size() > 0 {
+		processItem(itemQueue.Dequeue(), false)
+	}
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"testing"
+)
+
+func application(name string, revision string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetNamespace("argocd")
+	obj.SetName(name)
+	obj.SetResourceVersion(revision)
+	return obj
+}
+
+func TestEnqueueReplacesPendingItem(t *testing.T) {
+	itemQueue := (&ItemQueue{}).New()
+	itemQueue.Enqueue(context.Background(), application("app", "1"))
+	itemQueue.Enqueue(context.Background(), application("other", "1"))
+	itemQueue.Enqueue(context.Background(), application("app", "2"))
+
+	if itemQueue.Size() != 2 || itemQueue.Front().Object.GetResourceVersion() != "2" {
+		t.Errorf("'Enqueue' failed, expected '%v' items with newer update first, got '%v'", 2, itemQueue.Size())
+	}
+}
+
+func TestRequeueDropsItemWhenNewerWasPending(t *testing.T) {
+	itemQueue := (&ItemQueue{}).New()
+	itemQueue.Enqueue(context.Background(), application("app", "1"))
+	failed := itemQueue.Dequeue()
+	itemQueue.Enqueue(context.Background(), application("app", "2"))
+
+	if itemQueue.Requeue(failed) || itemQueue.Size() != 1 {
+		t.Errorf("'Requeue' failed, expected stale item to be dropped")
+	}
+}
+
+func TestRequeueDropsItemWhenNewerWasProcessedDuringBackoff(t *testing.T) {
+	itemQueue := (&ItemQueue{}).New()
+	itemQueue.Enqueue(context.Background(), application("app", "1"))
+	failed := itemQueue.Dequeue()
+
+	// newer update is enqueued and processed, while failed item waits for retry
+	itemQueue.Enqueue(context.Background(), application("app", "2"))
+	itemQueue.Dequeue()
+
+	if itemQueue.Requeue(failed) || !itemQueue.IsEmpty() {
+		t.Errorf("'Requeue' failed, expected item, that is older than processed update, to be dropped")
+	}
+}
+
+func TestRequeueKeepsLatestItem(t *testing.T) {
+	itemQueue := (&ItemQueue{}).New()
+	itemQueue.Enqueue(context.Background(), application("app", "1"))
+	failed := itemQueue.Dequeue()
+	itemQueue.Enqueue(context.Background(), application("other", "1"))
+
+	if !itemQueue.Requeue(failed) || itemQueue.Size() != 2 {
+		t.Errorf("'Requeue' failed, expected latest item of application to be requeued")
+	}
+}
@@ -0,0 +1,248 @@
+package reconcile
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/handler"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/queue"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// MissingCategory is application selected for sync, that has no environment in codefresh
+	MissingCategory = "missing"
+	// OrphanedCategory is environment of application, that doesn't exist in argocd anymore
+	OrphanedCategory = "orphaned"
+	// StaleCategory is environment, that wasn't successfully updated by agent, e.g. because event was missed
+	StaleCategory = "stale"
+)
+
+type Item struct {
+	Category    string `json:"category"`
+	Application string `json:"application"`
+	Environment string `json:"environment,omitempty"`
+	Repaired    bool   `json:"repaired"`
+	Error       string `json:"error,omitempty"`
+}
+
+type Report struct {
+	StartedAt    string `json:"startedAt"`
+	FinishedAt   string `json:"finishedAt"`
+	Applications int    `json:"applications"`
+	Environments int    `json:"environments"`
+	Items        []Item `json:"items"`
+}
+
+type application struct {
+	item argo.ApplicationItem
+	name string
+}
+
+type Reconciler struct {
+	argoApi      argo.ArgoApi
+	codefreshApi codefresh.EnvironmentsApi
+	integration  string
+	lastReport   *Report
+	lock         sync.Mutex
+}
+
+var reconciler *Reconciler
+
+func GetReconcilerInstance(codefreshApi codefresh.EnvironmentsApi, argoApi argo.ArgoApi) *Reconciler {
+	if reconciler != nil {
+		return reconciler
+	}
+	reconciler = &Reconciler{
+		argoApi:      argoApi,
+		codefreshApi: codefreshApi,
+		integration:  store.GetStore().Codefresh.Integration,
+	}
+	return reconciler
+}
+
+// managesEnvironments is true for sync modes, where agent creates and removes environments by itself
+func managesEnvironments() bool {
+	syncMode := store.GetStore().Codefresh.SyncMode
+	return syncMode == codefresh.ContinueSync || syncMode == codefresh.SelectSync
+}
+
+func isSynced(application argo.ApplicationItem) bool {
+	key := argo.ApplicationKey(application.Metadata.Namespace, application.Metadata.Name)
+	_, ok := state.GetInstance().Get(util.StateKey("environment", &key))
+	return ok
+}
+
+// Compare finds difference between argocd applications and codefresh environments of integration,
+// stale environments are found only when synced is set
+func Compare(applications []argo.ApplicationItem, environments []codefresh.CFEnvironment, integration string, managed bool, synced func(argo.ApplicationItem) bool) []Item {
+	items := make([]Item, 0)
+
+	apps := make(map[string]application)
+	for _, item := range applications {
+		name := argo.QualifiedName(item.Metadata.Namespace, item.Metadata.Name)
+		apps[name] = application{item: item, name: name}
+	}
+
+	envs := make(map[string]codefresh.CFEnvironment)
+	for _, env := range environments {
+		if env.Spec.Type != "argo" || env.Spec.Context != integration {
+			continue
+		}
+		envs[env.Spec.Application] = env
+
+		app, ok := apps[env.Spec.Application]
+		if !ok {
+			items = append(items, Item{Category: OrphanedCategory, Application: env.Spec.Application, Environment: env.Metadata.Name})
+			continue
+		}
+		if synced != nil && !synced(app.item) {
+			items = append(items, Item{Category: StaleCategory, Application: app.name, Environment: env.Metadata.Name})
+		}
+	}
+
+	if managed {
+		for name, app := range apps {
+			if _, ok := envs[name]; ok {
+				continue
+			}
+			if handler.IsSelectedForSync(app.item.Metadata, app.item.Spec) {
+				items = append(items, Item{Category: MissingCategory, Application: name})
+			}
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Category != items[j].Category {
+			return items[i].Category < items[j].Category
+		}
+		return items[i].Application < items[j].Application
+	})
+
+	return items
+}
+
+// Reconcile builds report and repairs items of categories from repair list
+func (reconciler *Reconciler) Reconcile(repair []string) (*Report, error) {
+	ctx, span := tracing.Start(context.Background(), "reconcile.Reconcile", tracing.KindInternal)
+	defer span.End()
+
+	report := &Report{StartedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	applications, err := reconciler.argoApi.GetApplicationsWithCredentialsFromStorage()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	environments, err := reconciler.codefreshApi.GetEnvironments()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	byName := make(map[string]argo.ApplicationItem)
+	for _, item := range applications {
+		byName[argo.QualifiedName(item.Metadata.Namespace, item.Metadata.Name)] = item
+	}
+
+	report.Applications = len(applications)
+	report.Environments = len(environments)
+	// state in memory is empty after restart, then every environment would look stale
+	var synced func(argo.ApplicationItem) bool
+	if state.GetInstance().Persistent() {
+		synced = isSynced
+	}
+	report.Items = Compare(applications, environments, reconciler.integration, managesEnvironments(), synced)
+
+	for i, item := range report.Items {
+		if !util.Contains(repair, item.Category) {
+			continue
+		}
+		err = reconciler.repair(ctx, item, byName[item.Application])
+		report.Items[i].Repaired = err == nil
+		if err != nil {
+			report.Items[i].Error = err.Error()
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	reconciler.lock.Lock()
+	reconciler.lastReport = report
+	reconciler.lock.Unlock()
+
+	return report, nil
+}
+
+func (reconciler *Reconciler) repair(ctx context.Context, item Item, app argo.ApplicationItem) error {
+	switch item.Category {
+	case OrphanedCategory:
+		return reconciler.codefreshApi.DeleteEnvironment(item.Environment)
+	case MissingCategory:
+		err := reconciler.codefreshApi.CreateEnvironment(item.Application, app.Spec.Project, item.Application)
+		if err != nil {
+			return err
+		}
+		// new environment is empty until application is sent, hash of application can be left from environment,
+		// that was removed on codefresh side, then update would be filtered out as unchanged
+		key := argo.ApplicationKey(app.Metadata.Namespace, app.Metadata.Name)
+		state.GetInstance().Delete(util.StateKey("environment", &key))
+		return enqueue(ctx, app)
+	case StaleCategory:
+		return enqueue(ctx, app)
+	}
+	return nil
+}
+
+// enqueue sends application through regular pipeline, so environment gets actual state
+func enqueue(ctx context.Context, app argo.ApplicationItem) error {
+	obj, err := argo.GetApplication(app.Metadata.Name, app.Metadata.Namespace)
+	if err != nil {
+		return err
+	}
+	queue.GetInstance().Enqueue(ctx, &unstructured.Unstructured{Object: obj})
+	return nil
+}
+
+// LastReport returns report of last finished reconciliation, nil if there wasn't any
+func (reconciler *Reconciler) LastReport() *Report {
+	reconciler.lock.Lock()
+	defer reconciler.lock.Unlock()
+	return reconciler.lastReport
+}
+
+// Run is called by scheduler, it logs summary of report
+func (reconciler *Reconciler) Run() {
+	report, err := reconciler.Reconcile(store.GetStore().Reconcile.Repair)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to reconcile applications with codefresh environments, reason %v", err)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, item := range report.Items {
+		counts[item.Category]++
+		log := logger.GetLogger().WithFields(logger.Fields{
+			logger.AppField: item.Application,
+			"category":      item.Category,
+			"environment":   item.Environment,
+			"repaired":      item.Repaired,
+		})
+		if item.Error != "" {
+			log.Errorf("Failed to repair %s environment, reason %v", item.Category, item.Error)
+		} else {
+			log.Infof("Found %s environment", item.Category)
+		}
+	}
+
+	logger.GetLogger().Infof("Reconciled %v applications with %v environments, missing %v, orphaned %v, stale %v",
+		report.Applications, report.Environments, counts[MissingCategory], counts[OrphanedCategory], counts[StaleCategory])
+}
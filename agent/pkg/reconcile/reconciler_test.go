@@ -0,0 +1,118 @@
+package reconcile
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/queue"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/util"
+	"testing"
+)
+
+type MockEnvironmentsApi struct {
+	created []string
+}
+
+func (api *MockEnvironmentsApi) GetEnvironments() ([]codefresh.CFEnvironment, error) {
+	return nil, nil
+}
+
+func (api *MockEnvironmentsApi) CreateEnvironment(name string, project string, application string) error {
+	api.created = append(api.created, name)
+	return nil
+}
+
+func (api *MockEnvironmentsApi) DeleteEnvironment(name string) error {
+	return nil
+}
+
+func environment(name string, context string, application string) codefresh.CFEnvironment {
+	env := codefresh.CFEnvironment{}
+	env.Metadata.Name = name
+	env.Spec.Type = "argo"
+	env.Spec.Context = context
+	env.Spec.Application = application
+	return env
+}
+
+func TestCompare(t *testing.T) {
+	store.SetSyncOptions(codefresh.ContinueSync, nil)
+
+	applications := []argo.ApplicationItem{
+		{Metadata: argo.ApplicationMetadata{Name: "synced", Namespace: "argocd"}},
+		{Metadata: argo.ApplicationMetadata{Name: "stale", Namespace: "argocd"}},
+		{Metadata: argo.ApplicationMetadata{Name: "new", Namespace: "team-a"}},
+		{Metadata: argo.ApplicationMetadata{Name: "ignored", Namespace: "argocd", Annotations: map[string]string{"codefresh.io/sync": "false"}}},
+	}
+	environments := []codefresh.CFEnvironment{
+		environment("synced", "argocd-prod", "synced"),
+		environment("stale", "argocd-prod", "stale"),
+		environment("removed", "argocd-prod", "removed"),
+		environment("other", "argocd-staging", "other"),
+	}
+
+	items := Compare(applications, environments, "argocd-prod", true, func(app argo.ApplicationItem) bool {
+		return app.Metadata.Name == "synced"
+	})
+
+	expected := []Item{
+		{Category: MissingCategory, Application: "team-a_new"},
+		{Category: OrphanedCategory, Application: "removed", Environment: "removed"},
+		{Category: StaleCategory, Application: "stale", Environment: "stale"},
+	}
+
+	if len(items) != len(expected) {
+		t.Fatalf("'Compare' failed, expected '%v', got '%v'", expected, items)
+	}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Errorf("'Compare' failed, expected '%v', got '%v'", expected[i], items[i])
+		}
+	}
+
+	items = Compare(applications, environments, "argocd-prod", false, func(app argo.ApplicationItem) bool {
+		return true
+	})
+	if len(items) != 1 || items[0].Category != OrphanedCategory {
+		t.Errorf("'Compare' failed, missing environments should be reported only in managed sync modes, got '%v'", items)
+	}
+}
+
+func TestCompareWithoutState(t *testing.T) {
+	applications := []argo.ApplicationItem{{Metadata: argo.ApplicationMetadata{Name: "app", Namespace: "argocd"}}}
+	environments := []codefresh.CFEnvironment{environment("app", "argocd-prod", "app")}
+
+	items := Compare(applications, environments, "argocd-prod", true, nil)
+	if len(items) != 0 {
+		t.Errorf("'Compare' failed, stale environments shouldn't be reported without state, got '%v'", items)
+	}
+}
+
+func TestRepairMissingForgetsState(t *testing.T) {
+	argo.SetApplicationCache(func(name string, namespace string) (map[string]interface{}, bool) {
+		return map[string]interface{}{"metadata": map[string]interface{}{"name": name, "namespace": namespace}}, true
+	})
+	defer argo.SetApplicationCache(nil)
+
+	key := argo.ApplicationKey("argocd", "recreated")
+	// hash is left from environment, that was removed on codefresh side
+	state.GetInstance().Set(util.StateKey("environment", &key), "hash")
+
+	codefreshApi := &MockEnvironmentsApi{}
+	reconciler := &Reconciler{codefreshApi: codefreshApi}
+	app := argo.ApplicationItem{Metadata: argo.ApplicationMetadata{Name: "recreated", Namespace: "argocd"}}
+	size := queue.GetInstance().Size()
+
+	err := reconciler.repair(context.Background(), Item{Category: MissingCategory, Application: "recreated"}, app)
+	if err != nil || len(codefreshApi.created) != 1 {
+		t.Errorf("'repair' failed, expected environment to be created, error '%v'", err)
+	}
+	if _, ok := state.GetInstance().Get(util.StateKey("environment", &key)); ok {
+		t.Errorf("'repair' failed, expected hash of application to be removed")
+	}
+	if queue.GetInstance().Size() != size+1 {
+		t.Errorf("'repair' failed, expected application to be enqueued")
+	}
+}
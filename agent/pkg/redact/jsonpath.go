@@ -0,0 +1,157 @@
+package redact
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is single step of json path, like ".name", "[*]", "[0]" or "..name"
+type segment struct {
+	name     string
+	index    int
+	wildcard bool
+	isIndex  bool
+	// recursive segment matches on any depth, like "..name"
+	recursive bool
+}
+
+// jsonPath is parsed subset of JSONPath: "$", ".name", "['name']", ".*", "[*]", "[n]" and recursive descent "..name"
+type jsonPath []segment
+
+func parsePath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path \"%s\" should start with \"$\"", path)
+	}
+
+	var result jsonPath
+	rest := path[1:]
+	for rest != "" {
+		var seg segment
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			seg.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			name, remaining := readName(rest)
+			if name == "" {
+				return nil, fmt.Errorf("json path \"%s\" has empty name after \"..\"", path)
+			}
+			seg.name, seg.wildcard = name, name == "*"
+			rest = remaining
+			result = append(result, seg)
+			continue
+		case strings.HasPrefix(rest, "."):
+			name, remaining := readName(rest[1:])
+			if name == "" {
+				return nil, fmt.Errorf("json path \"%s\" has empty name", path)
+			}
+			seg.name, seg.wildcard = name, name == "*"
+			rest = remaining
+			result = append(result, seg)
+			continue
+		case !strings.HasPrefix(rest, "["):
+			return nil, fmt.Errorf("json path \"%s\" has unexpected \"%s\"", path, rest)
+		}
+
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("json path \"%s\" has unclosed \"[\"", path)
+		}
+		selector := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+		switch {
+		case selector == "*":
+			seg.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			seg.name = selector[1 : len(selector)-1]
+		default:
+			index, err := strconv.Atoi(selector)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("json path \"%s\" has invalid selector \"[%s]\"", path, selector)
+			}
+			seg.index, seg.isIndex = index, true
+		}
+		result = append(result, seg)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("json path \"%s\" selects whole document", path)
+	}
+	return result, nil
+}
+
+func readName(path string) (string, string) {
+	end := strings.IndexAny(path, ".[")
+	if end < 0 {
+		return path, ""
+	}
+	return path[:end], path[end:]
+}
+
+func (seg segment) matchesKey(key string) bool {
+	return !seg.isIndex && (seg.wildcard || seg.name == key)
+}
+
+func (seg segment) matchesIndex(index int) bool {
+	return seg.wildcard || (seg.isIndex && seg.index == index)
+}
+
+// replace calls replacer for every value selected by path and stores result instead of value, it returns amount of replaced values
+func (path jsonPath) replace(node interface{}, replacer func(value interface{}) interface{}) int {
+	return path.walk(node, 0, replacer)
+}
+
+func (path jsonPath) walk(node interface{}, position int, replacer func(value interface{}) interface{}) int {
+	seg := path[position]
+	last := position == len(path)-1
+	count := 0
+
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		for key, value := range typed {
+			if !seg.matchesKey(key) {
+				continue
+			}
+			if last {
+				typed[key] = replacer(value)
+				count++
+			} else {
+				count += path.walk(value, position+1, replacer)
+			}
+		}
+		if seg.recursive {
+			for key, value := range typed {
+				// replaced values are already redacted
+				if last && seg.matchesKey(key) {
+					continue
+				}
+				count += path.walk(value, position, replacer)
+			}
+		}
+	case []interface{}:
+		for index, value := range typed {
+			if !seg.matchesIndex(index) {
+				continue
+			}
+			if last {
+				typed[index] = replacer(value)
+				count++
+			} else {
+				count += path.walk(value, position+1, replacer)
+			}
+		}
+		if seg.recursive {
+			for index, value := range typed {
+				if last && seg.matchesIndex(index) {
+					continue
+				}
+				count += path.walk(value, position, replacer)
+			}
+		}
+	}
+
+	return count
+}
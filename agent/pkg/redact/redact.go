@@ -0,0 +1,224 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Placeholder replaces redacted strings, numbers are replaced with 0 and booleans with false, so payload keeps its schema
+const Placeholder = "[REDACTED]"
+
+// LastAppliedAnnotation keeps whole manifest of resource, including values of secrets
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// DefaultEnvPattern matches names of env variables, that usually keep credentials
+const DefaultEnvPattern = `(?i)(password|passwd|secret|token|credential|api_?key|private_?key|auth)`
+
+// Built-in rules, they are applied to every payload
+const (
+	SecretDataRule  = "secret-data"
+	EnvValueRule    = "env-value"
+	LastAppliedRule = "last-applied-configuration"
+)
+
+// Rule redacts all values selected by JSONPath, like "$..spec.source.helm.values"
+type Rule struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type compiledRule struct {
+	name string
+	path jsonPath
+}
+
+// Redactor removes sensitive values from payloads and counts redactions per rule
+type Redactor struct {
+	envPattern *regexp.Regexp
+	rules      []compiledRule
+
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+var redactor *Redactor
+
+// GetInstance returns redactor configured by Init, only built-in rules are applied until Init is called
+func GetInstance() *Redactor {
+	if redactor == nil {
+		redactor, _ = New(nil, "")
+	}
+	return redactor
+}
+
+// Init replaces redactor with one, that applies rules in addition to built-in rules
+func Init(rules []Rule, envPattern string) error {
+	newRedactor, err := New(rules, envPattern)
+	if err != nil {
+		return err
+	}
+	redactor = newRedactor
+	return nil
+}
+
+// New creates redactor, envPattern overrides DefaultEnvPattern when not empty
+func New(rules []Rule, envPattern string) (*Redactor, error) {
+	if envPattern == "" {
+		envPattern = DefaultEnvPattern
+	}
+	pattern, err := regexp.Compile(envPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env pattern \"%s\", reason %v", envPattern, err)
+	}
+
+	result := &Redactor{
+		envPattern: pattern,
+		counts: map[string]int{
+			SecretDataRule:  0,
+			EnvValueRule:    0,
+			LastAppliedRule: 0,
+		},
+	}
+
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redaction rule with path \"%s\" has no name", rule.Path)
+		}
+		if _, exists := result.counts[rule.Name]; exists {
+			return nil, fmt.Errorf("redaction rule \"%s\" is defined twice", rule.Name)
+		}
+		path, err := parsePath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule \"%s\" is invalid, reason %v", rule.Name, err)
+		}
+		result.rules = append(result.rules, compiledRule{name: rule.Name, path: path})
+		result.counts[rule.Name] = 0
+	}
+
+	return result, nil
+}
+
+// Apply redacts generic json document (maps, slices and scalars) in place and returns amount of redacted values
+func (r *Redactor) Apply(document interface{}) int {
+	counts := make(map[string]int)
+	r.applyBuiltIn(document, counts)
+	for _, rule := range r.rules {
+		counts[rule.name] += rule.path.replace(document, redactValue)
+	}
+
+	total := 0
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for name, count := range counts {
+		r.counts[name] += count
+		total += count
+	}
+	return total
+}
+
+// Redact returns copy of value with redacted sensitive fields, value is converted through json, so it should be json serializable
+func (r *Redactor) Redact(value interface{}, result interface{}) (int, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+	var document interface{}
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return 0, err
+	}
+
+	count := r.Apply(document)
+
+	data, err = json.Marshal(document)
+	if err != nil {
+		return 0, err
+	}
+	return count, json.Unmarshal(data, result)
+}
+
+// HasCustomRules says if rules were configured in addition to built-in rules
+func (r *Redactor) HasCustomRules() bool {
+	return len(r.rules) > 0
+}
+
+// Counts returns amount of values redacted by every rule since start of agent, it's audit of redactions
+func (r *Redactor) Counts() map[string]int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := make(map[string]int, len(r.counts))
+	for name, count := range r.counts {
+		result[name] = count
+	}
+	return result
+}
+
+func (r *Redactor) applyBuiltIn(node interface{}, counts map[string]int) {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		if typed["kind"] == "Secret" {
+			for _, field := range []string{"data", "stringData"} {
+				if data, ok := typed[field].(map[string]interface{}); ok {
+					for key, value := range data {
+						data[key] = redactValue(value)
+						counts[SecretDataRule]++
+					}
+				}
+			}
+		}
+
+		if annotations, ok := typed["annotations"].(map[string]interface{}); ok {
+			if _, exists := annotations[LastAppliedAnnotation]; exists {
+				annotations[LastAppliedAnnotation] = Placeholder
+				counts[LastAppliedRule]++
+			}
+		}
+
+		if env, ok := typed["env"].([]interface{}); ok {
+			for _, item := range env {
+				variable, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := variable["name"].(string)
+				if _, hasValue := variable["value"]; hasValue && r.envPattern.MatchString(name) {
+					variable["value"] = redactValue(variable["value"])
+					counts[EnvValueRule]++
+				}
+			}
+		}
+
+		for _, value := range typed {
+			r.applyBuiltIn(value, counts)
+		}
+	case []interface{}:
+		for _, value := range typed {
+			r.applyBuiltIn(value, counts)
+		}
+	}
+}
+
+// redactValue keeps structure of maps and lists and replaces their leaf values
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = redactValue(item)
+		}
+		return typed
+	case []interface{}:
+		for index, item := range typed {
+			typed[index] = redactValue(item)
+		}
+		return typed
+	case float64:
+		return float64(0)
+	case bool:
+		return false
+	case nil:
+		return nil
+	}
+	return Placeholder
+}
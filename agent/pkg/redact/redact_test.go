@@ -0,0 +1,131 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func document(t *testing.T, data string) map[string]interface{} {
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		t.Fatalf("failed to parse document, reason %v", err)
+	}
+	return result
+}
+
+func TestBuiltInRules(t *testing.T) {
+	redactor, _ := New(nil, "")
+	doc := document(t, `{
+		"resources": [
+			{"kind": "Secret", "metadata": {"annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{}", "team": "a"}}, "data": {"password": "c2VjcmV0"}},
+			{"kind": "Deployment", "spec": {"containers": [{"env": [{"name": "DB_PASSWORD", "value": "secret"}, {"name": "LOG_LEVEL", "value": "debug"}]}]}}
+		]
+	}`)
+
+	count := redactor.Apply(doc)
+
+	if count != 3 {
+		t.Errorf("'Apply' failed, expected '%v', got '%v'", 3, count)
+	}
+	resources := doc["resources"].([]interface{})
+	secret := resources[0].(map[string]interface{})
+	if secret["data"].(map[string]interface{})["password"] != Placeholder {
+		t.Errorf("'Apply' failed, secret data wasn't redacted")
+	}
+	annotations := secret["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	if annotations[LastAppliedAnnotation] != Placeholder || annotations["team"] != "a" {
+		t.Errorf("'Apply' failed, unexpected annotations '%v'", annotations)
+	}
+	env := resources[1].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})["env"].([]interface{})
+	if env[0].(map[string]interface{})["value"] != Placeholder || env[1].(map[string]interface{})["value"] != "debug" {
+		t.Errorf("'Apply' failed, unexpected env '%v'", env)
+	}
+
+	counts := redactor.Counts()
+	if counts[SecretDataRule] != 1 || counts[EnvValueRule] != 1 || counts[LastAppliedRule] != 1 {
+		t.Errorf("'Counts' failed, unexpected counts '%v'", counts)
+	}
+}
+
+func TestJsonPathRules(t *testing.T) {
+	redactor, err := New([]Rule{
+		{Name: "helm-values", Path: "$..helm.values"},
+		{Name: "first-image", Path: "$.activities[0].targetImages[*]"},
+		{Name: "token", Path: "$['gitops']['token']"},
+	}, "")
+	if err != nil {
+		t.Errorf("'New' failed, reason %v", err)
+		return
+	}
+	if builtIn, _ := New(nil, ""); builtIn.HasCustomRules() || !redactor.HasCustomRules() {
+		t.Errorf("'HasCustomRules' failed, expected only redactor with rules to have custom rules")
+	}
+
+	doc := document(t, `{
+		"gitops": {"token": "abc"},
+		"operation": {"source": {"helm": {"values": {"replicas": 3, "enabled": true, "password": "x"}}}},
+		"activities": [{"targetImages": ["a:1", "b:1"]}, {"targetImages": ["c:1"]}]
+	}`)
+
+	count := redactor.Apply(doc)
+
+	if count != 4 {
+		t.Errorf("'Apply' failed, expected '%v', got '%v'", 4, count)
+	}
+	values := doc["operation"].(map[string]interface{})["source"].(map[string]interface{})["helm"].(map[string]interface{})["values"].(map[string]interface{})
+	if values["replicas"] != float64(0) || values["enabled"] != false || values["password"] != Placeholder {
+		t.Errorf("'Apply' failed, unexpected helm values '%v'", values)
+	}
+	activities := doc["activities"].([]interface{})
+	if activities[1].(map[string]interface{})["targetImages"].([]interface{})[0] != "c:1" {
+		t.Errorf("'Apply' failed, second activity shouldn't be redacted")
+	}
+	if doc["gitops"].(map[string]interface{})["token"] != Placeholder {
+		t.Errorf("'Apply' failed, token wasn't redacted")
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	rules := [][]Rule{
+		{{Name: "no-dollar", Path: "spec.values"}},
+		{{Name: "unclosed", Path: "$.spec[0"}},
+		{{Name: "", Path: "$.spec"}},
+		{{Name: EnvValueRule, Path: "$.spec"}},
+		{{Name: "whole", Path: "$"}},
+	}
+	for _, rule := range rules {
+		if _, err := New(rule, ""); err == nil {
+			t.Errorf("'New' failed, expected error for rule '%v'", rule)
+		}
+	}
+	if _, err := New(nil, "("); err == nil {
+		t.Errorf("'New' failed, expected error for invalid env pattern")
+	}
+}
+
+func TestRedactKeepsSchema(t *testing.T) {
+	type payload struct {
+		Name      string        `json:"name"`
+		Replicas  int           `json:"replicas"`
+		Resources []interface{} `json:"resources"`
+	}
+	redactor, _ := New([]Rule{{Name: "replicas", Path: "$.replicas"}}, "")
+	source := payload{
+		Name:      "app",
+		Replicas:  3,
+		Resources: []interface{}{map[string]interface{}{"kind": "Secret", "data": map[string]interface{}{"key": "dmFsdWU="}}},
+	}
+
+	var result payload
+	count, err := redactor.Redact(source, &result)
+
+	if err != nil || count != 2 {
+		t.Errorf("'Redact' failed, expected '%v', got '%v', error '%v'", 2, count, err)
+	}
+	if result.Name != "app" || result.Replicas != 0 {
+		t.Errorf("'Redact' failed, unexpected result '%v'", result)
+	}
+	if source.Resources[0].(map[string]interface{})["data"].(map[string]interface{})["key"] != "dmFsdWU=" {
+		t.Errorf("'Redact' failed, source was modified")
+	}
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"os"
+	"sync"
+)
+
+const (
+	ConfigEntry   = "config"
+	EventEntry    = "event"
+	ResponseEntry = "response"
+)
+
+const (
+	AddEvent    = "add"
+	UpdateEvent = "update"
+	DeleteEvent = "delete"
+)
+
+// Config is configuration of recorded agent, that affects produced payloads
+type Config struct {
+	Integration         string           `json:"integration"`
+	SyncMode            string           `json:"syncMode"`
+	ArgoNamespace       string           `json:"argoNamespace"`
+	ApplicationsForSync []string         `json:"applicationsForSync"`
+	SyncRules           []store.SyncRule `json:"syncRules"`
+}
+
+type Event struct {
+	// Time is when event was received, it's used as time of requests produced by replay
+	Time      string                 `json:"time"`
+	Type      string                 `json:"type"`
+	Object    map[string]interface{} `json:"object"`
+	OldObject map[string]interface{} `json:"oldObject,omitempty"`
+}
+
+// Response is response of argocd or github api, request is identified by method and path with query, host isn't kept
+type Response struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+// Entry is line of archive, archive is gzipped ndjson
+type Entry struct {
+	Kind     string    `json:"kind"`
+	Config   *Config   `json:"config,omitempty"`
+	Event    *Event    `json:"event,omitempty"`
+	Response *Response `json:"response,omitempty"`
+}
+
+type archiveWriter struct {
+	file    *os.File
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+	lock    sync.Mutex
+}
+
+func createArchive(path string) (*archiveWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gzipWriter := gzip.NewWriter(file)
+	return &archiveWriter{file: file, gzip: gzipWriter, encoder: json.NewEncoder(gzipWriter)}, nil
+}
+
+func (writer *archiveWriter) write(entry Entry) error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	err := writer.encoder.Encode(entry)
+	if err != nil {
+		return err
+	}
+	// flush every entry, so archive is readable even if agent is killed
+	return writer.gzip.Flush()
+}
+
+func (writer *archiveWriter) close() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	err := writer.gzip.Close()
+	if closeErr := writer.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadArchive reads all entries of archive
+func ReadArchive(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(gzipReader)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	// archive of killed agent can end with partial line, entries before it are still useful
+	if err := scanner.Err(); err != nil && len(entries) == 0 {
+		return nil, err
+	}
+	return entries, nil
+}
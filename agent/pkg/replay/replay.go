@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	recorder *archiveWriter
+	stub     *stubTransport
+)
+
+// StartRecording writes config, informer events and responses of argocd and github to archive until StopRecording
+func StartRecording(path string, config Config) error {
+	writer, err := createArchive(path)
+	if err != nil {
+		return err
+	}
+	err = writer.write(Entry{Kind: ConfigEntry, Config: &config})
+	if err != nil {
+		_ = writer.close()
+		return err
+	}
+	recorder = writer
+	return nil
+}
+
+func StopRecording() error {
+	if recorder == nil {
+		return nil
+	}
+	writer := recorder
+	recorder = nil
+	return writer.close()
+}
+
+func IsRecording() bool {
+	return recorder != nil
+}
+
+func objectOf(obj interface{}) map[string]interface{} {
+	if item, ok := obj.(*unstructured.Unstructured); ok && item != nil {
+		return item.Object
+	}
+	return nil
+}
+
+// RecordEvent keeps informer event, old object is nil for add and delete events
+func RecordEvent(eventType string, obj interface{}, oldObj interface{}) {
+	if recorder == nil {
+		return
+	}
+	_ = recorder.write(Entry{Kind: EventEntry, Event: &Event{Time: time.Now().UTC().Format(time.RFC3339Nano), Type: eventType, Object: objectOf(obj), OldObject: objectOf(oldObj)}})
+}
+
+// Transport records responses in recording mode and returns recorded responses in replay mode,
+// in other cases requests are passed to base transport as is
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func requestPath(req *http.Request) string {
+	return req.URL.RequestURI()
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if stub != nil {
+		return stub.RoundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || recorder == nil {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	_ = recorder.write(Entry{Kind: ResponseEntry, Response: &Response{
+		Method: req.Method,
+		Path:   requestPath(req),
+		Status: resp.StatusCode,
+		Body:   string(body),
+	}})
+
+	return resp, nil
+}
+
+// stubTransport returns recorded responses in order they were recorded, last response is repeated
+type stubTransport struct {
+	responses map[string][]Response
+	lock      sync.Mutex
+}
+
+func responseKey(method string, path string) string {
+	return method + " " + path
+}
+
+// UseResponses switches transports to replay mode, no requests to argocd and github are done after it
+func UseResponses(responses []Response) {
+	transport := &stubTransport{responses: make(map[string][]Response)}
+	for _, response := range responses {
+		key := responseKey(response.Method, response.Path)
+		transport.responses[key] = append(transport.responses[key], response)
+	}
+	stub = transport
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := responseKey(req.Method, requestPath(req))
+	responses := t.responses[key]
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+
+	response := responses[0]
+	if len(responses) > 1 {
+		t.responses[key] = responses[1:]
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status)),
+		StatusCode: response.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(response.Body))),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,110 @@
+package replay
+
+import (
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("'Get' failed, reason %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "archive.ndjson.gz")
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write([]byte(r.URL.RequestURI() + " " + string(rune('0'+calls))))
+	}))
+	defer server.Close()
+
+	err = StartRecording(path, Config{SyncMode: "CONTINUE_SYNC", ArgoNamespace: "argocd"})
+	if err != nil {
+		t.Fatalf("'StartRecording' failed, reason %v", err)
+	}
+
+	app := &unstructured.Unstructured{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "app"}}}
+	RecordEvent(AddEvent, app, nil)
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	get(t, client, server.URL+"/api/v1/applications?appNamespace=argocd")
+	get(t, client, server.URL+"/api/v1/applications?appNamespace=argocd")
+	get(t, client, server.URL+"/missing")
+
+	err = StopRecording()
+	if err != nil {
+		t.Fatalf("'StopRecording' failed, reason %v", err)
+	}
+	// events aren't recorded after stop
+	RecordEvent(DeleteEvent, app, nil)
+
+	entries, err := ReadArchive(path)
+	if err != nil {
+		t.Fatalf("'ReadArchive' failed, reason %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("'ReadArchive' failed, expected '%v', got '%v'", 5, len(entries))
+	}
+	if entries[0].Kind != ConfigEntry || entries[0].Config.SyncMode != "CONTINUE_SYNC" {
+		t.Errorf("'ReadArchive' failed, expected config first, got '%v'", entries[0])
+	}
+	if entries[1].Kind != EventEntry || entries[1].Event.Type != AddEvent || entries[1].Event.Time == "" {
+		t.Errorf("'ReadArchive' failed, expected add event, got '%v'", entries[1])
+	}
+
+	var responses []Response
+	for _, entry := range entries[2:] {
+		responses = append(responses, *entry.Response)
+	}
+	UseResponses(responses)
+	defer func() {
+		stub = nil
+	}()
+
+	// host of request doesn't matter in replay mode
+	recordedCalls := calls
+	host := "http://argocd.replay"
+	expectations := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/api/v1/applications?appNamespace=argocd", 200, "/api/v1/applications?appNamespace=argocd 1"},
+		{"/api/v1/applications?appNamespace=argocd", 200, "/api/v1/applications?appNamespace=argocd 2"},
+		// last response is repeated
+		{"/api/v1/applications?appNamespace=argocd", 200, "/api/v1/applications?appNamespace=argocd 2"},
+		{"/missing", 404, "/missing 3"},
+	}
+	for _, expectation := range expectations {
+		status, body := get(t, client, host+expectation.path)
+		if status != expectation.status || body != expectation.body {
+			t.Errorf("'RoundTrip' failed, expected '%v %v', got '%v %v'", expectation.status, expectation.body, status, body)
+		}
+	}
+
+	_, err = client.Get(host + "/unknown")
+	if err == nil {
+		t.Errorf("'RoundTrip' failed, expected error for request without recorded response")
+	}
+	if calls != recordedCalls {
+		t.Errorf("'RoundTrip' failed, expected no requests in replay mode, got '%v'", calls-recordedCalls)
+	}
+}
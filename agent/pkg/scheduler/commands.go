@@ -0,0 +1,15 @@
+package scheduler
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/command"
+	"github.com/robfig/cron/v3"
+)
+
+func StartCommandsPoller() {
+	executor := command.GetExecutorInstance(codefresh.GetInstance(), argo.GetInstance())
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	_, _ = c.AddFunc("@every 5s", executor.Poll)
+	c.Start()
+}
@@ -0,0 +1,31 @@
+package scheduler
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dora"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/robfig/cron/v3"
+)
+
+func sendDoraSummary(tracker *dora.Tracker) {
+	summary := tracker.Summary()
+	err := codefresh.GetInstance().SendDoraSummary(summary)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to send DORA metrics, reason %v", err)
+		return
+	}
+	logger.GetLogger().Infof("Successfully sent DORA metrics of %v applications", len(summary.Applications))
+}
+
+// StartDoraSummaries periodically sends DORA metrics of tracker to codefresh
+func StartDoraSummaries(interval string, tracker *dora.Tracker) error {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	_, err := c.AddFunc("@every "+interval, func() {
+		sendDoraSummary(tracker)
+	})
+	if err != nil {
+		return err
+	}
+	c.Start()
+	return nil
+}
@@ -1,10 +1,15 @@
 package scheduler
 
 import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/extract"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/sink"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
 	"github.com/jasonlvhit/gocron"
 )
 
@@ -20,45 +25,84 @@ func isNewEnv(existingEnvs []store.Environment, newEnv codefresh.CFEnvironment)
 	return true
 }
 
-func handleNewApplications(applications []string) {
-	for _, application := range applications {
-		newApp, err := extract.ExtractNewApplication(application)
-		if err != nil {
-			logger.GetLogger().Errorf("Failed to handle new gitops application %v, reason: %v", application, err)
-			continue
-		}
-		logger.GetLogger().Infof("Detect new gitops application %s, initiate initialization", application)
-		_, err = codefresh.GetInstance().SendEnvironment(*newApp)
-		if err != nil {
-			logger.GetLogger().Errorf("Failed to send environment, reason %v", err)
+// initApplication sends environment of new application, it returns false when initialization should be repeated on next run
+func initApplication(application string) bool {
+	ctx, span := tracing.Start(context.Background(), "scheduler.InitEnvironment", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("argocd.application", application)
+
+	var newApp *codefresh.Environment
+	err := argo.WithReauthentication(func() error {
+		var err error
+		newApp, err = extract.ExtractNewApplication(ctx, application)
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		logger.GetLogger().Errorf("Failed to handle new gitops application %v, reason: %v", application, err)
+		return !isRetryable(err)
+	}
+	logger.GetLogger().Infof("Detect new gitops application %s, initiate initialization", application)
+	err = sink.GetDispatcherInstance().Dispatch(ctx, sink.EnvironmentUpdated, *newApp)
+	span.RecordError(err)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to send environment, reason %v", err)
+		return !isRetryable(err)
+	}
+	return true
+}
+
+// isRetryable says if failed initialization makes sense to repeat, permanent errors (like removed application) are dropped
+func isRetryable(err error) bool {
+	return apierrors.IsRetryable(err) || apierrors.Is(err, apierrors.Unauthorized)
+}
+
+// handleNewApplications initializes environments of new applications and returns names of environments,
+// that failed with temporary error and have to stay unknown
+func handleNewApplications(environments []codefresh.CFEnvironment) map[string]bool {
+	failed := make(map[string]bool)
+	for _, env := range environments {
+		if !initApplication(env.Spec.Application) {
+			failed[env.Metadata.Name] = true
 		}
 	}
+	return failed
 }
 
 func handleEnvDifference() {
 	storeInst := store.GetStore()
+	envs, err := codefresh.GetInstance().GetEnvironments()
+	if err != nil {
+		// keep known environments, otherwise all of them would be initialized again on next run
+		logger.GetLogger().Errorf("Failed to get environments, reason: %v", err)
+		return
+	}
+
 	var newEnvs []store.Environment
-	var applications []string
-	envs, _ := codefresh.GetInstance().GetEnvironments()
+	var created []codefresh.CFEnvironment
 	for _, env := range envs {
 		if env.Spec.Type != "argo" {
 			continue
 		}
 
-		newEnv := store.Environment{
+		newEnvs = append(newEnvs, store.Environment{
 			Name: env.Metadata.Name,
-		}
-		newEnvs = append(newEnvs, newEnv)
+		})
 
 		if isNewEnv(storeInst.Environments, env) {
-			applications = append(applications, env.Spec.Application)
+			created = append(created, env)
 		}
 	}
 
-	store.SetEnvironments(newEnvs)
-
-	handleNewApplications(applications)
+	failed := handleNewApplications(created)
 
+	var knownEnvs []store.Environment
+	for _, env := range newEnvs {
+		if !failed[env.Name] {
+			knownEnvs = append(knownEnvs, env)
+		}
+	}
+	store.SetEnvironments(knownEnvs)
 }
 
 func StartEnvInitializer() {
@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/reconcile"
+	"github.com/robfig/cron/v3"
+)
+
+// StartReconciliation periodically compares argocd applications with codefresh environments
+func StartReconciliation(interval string) error {
+	reconciler := reconcile.GetReconcilerInstance(codefresh.GetInstance(), argo.GetInstance())
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	_, err := c.AddFunc("@every "+interval, reconciler.Run)
+	if err != nil {
+		return err
+	}
+	c.Start()
+	return nil
+}
@@ -0,0 +1,86 @@
+package sink
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
+)
+
+const (
+	StructuredMode = "structured"
+	BinaryMode     = "binary"
+)
+
+const cloudEventTypePrefix = "io.codefresh.argocd."
+
+// CloudEventsSink sends events in cloudevents 1.0 http format
+type CloudEventsSink struct {
+	config Config
+}
+
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	Subject         string          `json:"subject"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+func newCloudEventsSink(config Config) *CloudEventsSink {
+	return &CloudEventsSink{config: config}
+}
+
+func (sink *CloudEventsSink) Name() string {
+	return sink.config.Name
+}
+
+func newEventId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func (sink *CloudEventsSink) Send(event Event) error {
+	data, err := renderPayload(sink.config, event, event)
+	if err != nil {
+		return err
+	}
+
+	ce := cloudEvent{
+		SpecVersion:     "1.0",
+		Id:              newEventId(),
+		Source:          "argocd-agent/" + store.GetStore().Codefresh.Integration,
+		Type:            cloudEventTypePrefix + event.Type,
+		Time:            event.Time,
+		Subject:         event.Environment.Name,
+		DataContentType: "application/json",
+		Data:            data,
+	}
+
+	headers := make(map[string]string)
+	for key, value := range sink.config.Headers {
+		headers[key] = value
+	}
+
+	if sink.config.Mode == BinaryMode {
+		headers["Content-Type"] = ce.DataContentType
+		headers["ce-specversion"] = ce.SpecVersion
+		headers["ce-id"] = ce.Id
+		headers["ce-source"] = ce.Source
+		headers["ce-type"] = ce.Type
+		headers["ce-time"] = ce.Time
+		headers["ce-subject"] = ce.Subject
+		return post(event.Context(), sink.config.Url, headers, data)
+	}
+
+	body, err := json.Marshal(ce)
+	if err != nil {
+		return err
+	}
+	headers["Content-Type"] = "application/cloudevents+json"
+	return post(event.Context(), sink.config.Url, headers, body)
+}
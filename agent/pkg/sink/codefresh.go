@@ -0,0 +1,36 @@
+package sink
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+)
+
+// CodefreshSink sends environment to codefresh, it's primary sink of agent
+type CodefreshSink struct {
+}
+
+func (sink *CodefreshSink) Name() string {
+	return "codefresh"
+}
+
+// Deferred says if updates are batched, then codefresh accepts them after Send returns
+func (sink *CodefreshSink) Deferred() bool {
+	return codefresh.GetBatcher() != nil
+}
+
+func (sink *CodefreshSink) Send(event Event) error {
+	if event.Type == EnvironmentHealthChanged {
+		// codefresh receives same environment with update event
+		return nil
+	}
+	if batcher := codefresh.GetBatcher(); batcher != nil {
+		// event is marked as sent and delivered to other sinks, when batch is delivered
+		batcher.Add(event.Environment)
+		return nil
+	}
+	_, err := codefresh.GetInstance().SendEnvironment(event.Context(), event.Environment)
+	if err == nil {
+		diagnostics.MarkEventSent()
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/redact"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"sync"
+	"time"
+)
+
+const eventsBufferSize = 100
+
+type asyncSink struct {
+	sink   Sink
+	config Config
+	events chan Event
+}
+
+// deferredSink accepts events, that it delivers later, like batched updates of codefresh
+type deferredSink interface {
+	Deferred() bool
+}
+
+// Dispatcher delivers environment events to codefresh synchronously and to configured sinks in background,
+// so slow sink never blocks events queue
+type Dispatcher struct {
+	primary        Sink
+	sinks          []*asyncSink
+	healthStatuses map[string]string
+	// pending are events of environments, that wait for delivery of batch to codefresh, only latest update is kept like in batch
+	pending map[string][]Event
+	lock    sync.Mutex
+}
+
+var dispatcher *Dispatcher
+
+func GetDispatcherInstance() *Dispatcher {
+	if dispatcher != nil {
+		return dispatcher
+	}
+	dispatcher = &Dispatcher{
+		primary:        &CodefreshSink{},
+		healthStatuses: make(map[string]string),
+	}
+	return dispatcher
+}
+
+// Backlog returns amount of events, that wait for delivery to sinks
+func (d *Dispatcher) Backlog() int {
+	backlog := 0
+	for _, item := range d.sinks {
+		backlog += len(item.events)
+	}
+	return backlog
+}
+
+// Init creates sinks by configs and starts their delivery workers
+func Init(configs []Config) error {
+	d := GetDispatcherInstance()
+	for _, config := range configs {
+		// dispatcher filters events by config, so it needs defaults, that sink gets
+		config = config.withDefaults()
+		sink, err := New(config)
+		if err != nil {
+			return err
+		}
+		item := &asyncSink{
+			sink:   sink,
+			config: config,
+			events: make(chan Event, eventsBufferSize),
+		}
+		d.sinks = append(d.sinks, item)
+		go item.run()
+	}
+	return nil
+}
+
+func (item *asyncSink) run() {
+	for event := range item.events {
+		backoff := item.config.Retry.backoff()
+		attempts := item.config.Retry.attempts()
+
+		for attempt := 1; attempt <= attempts; attempt++ {
+			err := item.sink.Send(event)
+			if err == nil {
+				break
+			}
+			if attempt == attempts {
+				logger.GetLogger().Errorf("Failed to send event \"%s\" of \"%s\" to sink \"%s\" after %v attempts, reason %v", event.Type, event.Environment.Name, item.sink.Name(), attempts, err)
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// events returns events of environment update, health status is remembered by remember, when update is delivered to codefresh
+func (d *Dispatcher) events(ctx context.Context, eventType string, env codefresh.Environment) []Event {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	previousHealthStatus, known := d.healthStatuses[env.Name]
+
+	events := []Event{{Type: eventType, Time: now, PreviousHealthStatus: previousHealthStatus, Environment: env, ctx: ctx}}
+
+	if eventType != EnvironmentDeleted && known && previousHealthStatus != env.HealthStatus {
+		events = append(events, Event{Type: EnvironmentHealthChanged, Time: now, PreviousHealthStatus: previousHealthStatus, Environment: env, ctx: ctx})
+	}
+
+	return events
+}
+
+func (d *Dispatcher) remember(eventType string, env codefresh.Environment) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if eventType == EnvironmentDeleted {
+		delete(d.healthStatuses, env.Name)
+		return
+	}
+	d.healthStatuses[env.Name] = env.HealthStatus
+}
+
+// Dispatch returns error of codefresh delivery only, other sinks are best effort. Other sinks get events only after
+// codefresh accepted update, otherwise retry of update would deliver same events to them again.
+// Events of batched update are delivered to sinks by Delivered, when batch is accepted
+func (d *Dispatcher) Dispatch(ctx context.Context, eventType string, env codefresh.Environment) error {
+	ctx, span := tracing.Start(ctx, "sink.Dispatch", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("event.type", eventType)
+
+	// redaction is applied before any sink, so secrets never leave cluster
+	var redacted codefresh.Environment
+	count, err := redact.GetInstance().Redact(env, &redacted)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to redact environment \"%s\", reason %w", env.Name, err)
+	}
+	if count > 0 {
+		logger.GetLogger().WithField(logger.AppField, env.Name).Debugf("Redacted %v values of environment", count)
+	}
+	env = redacted
+
+	events := d.events(ctx, eventType, env)
+
+	err = d.primary.Send(events[0])
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if deferred, ok := d.primary.(deferredSink); ok && deferred.Deferred() {
+		d.lock.Lock()
+		if d.pending == nil {
+			d.pending = make(map[string][]Event)
+		}
+		d.pending[env.Name] = events
+		d.lock.Unlock()
+		return nil
+	}
+
+	d.deliver(events)
+	return nil
+}
+
+// Delivered sends pending events of environment to sinks, when codefresh accepted batch with it
+func (d *Dispatcher) Delivered(env codefresh.Environment) {
+	d.lock.Lock()
+	events, ok := d.pending[env.Name]
+	delete(d.pending, env.Name)
+	d.lock.Unlock()
+	if ok {
+		d.deliver(events)
+	}
+}
+
+// Dropped forgets pending events of environment, that codefresh never got
+func (d *Dispatcher) Dropped(env codefresh.Environment) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.pending, env.Name)
+}
+
+// deliver remembers health status of environment accepted by codefresh and enqueues its events to sinks
+func (d *Dispatcher) deliver(events []Event) {
+	env := events[0].Environment
+	d.remember(events[0].Type, env)
+
+	for _, item := range d.sinks {
+		for _, event := range events {
+			if !item.config.Filter.Matches(event) {
+				continue
+			}
+			select {
+			case item.events <- event:
+			default:
+				logger.GetLogger().Errorf("Events buffer of sink \"%s\" is full, drop event \"%s\" of \"%s\"", item.sink.Name(), event.Type, env.Name)
+			}
+		}
+	}
+}
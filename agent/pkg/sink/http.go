@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dryrun"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/httpclient"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+)
+
+// getHttpClient returns client shared by all sinks, deliveries are retried by sinks themselves, as they are POST requests
+func getHttpClient() *http.Client {
+	httpClientOnce.Do(func() {
+		httpClient = httpclient.New(httpclient.DefaultConfig(), func(tr http.RoundTripper) http.RoundTripper {
+			return tracing.NewTransport(tr)
+		})
+	})
+	return httpClient
+}
+
+// record writes delivery to dry run output instead of sending it, only host of url is written,
+// because urls of webhooks, like slack ones, contain tokens
+func record(recorder *dryrun.Recorder, sinkUrl string, body []byte) error {
+	host := sinkUrl
+	if parsed, err := url.Parse(sinkUrl); err == nil {
+		host = parsed.Host
+	}
+	var payload interface{} = string(body)
+	if json.Valid(body) {
+		payload = json.RawMessage(body)
+	}
+	return recorder.Record("POST", "sink://"+host, nil, payload)
+}
+
+func post(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	if recorder := dryrun.GetRecorder(); recorder != nil {
+		// dry run mode, nothing is sent to sinks
+		return record(recorder, url, body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := getHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request to %s failed with status %v", url, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"path"
+	"time"
+)
+
+const (
+	EnvironmentUpdated       = "environment.updated"
+	EnvironmentDeleted       = "environment.deleted"
+	EnvironmentHealthChanged = "environment.health-changed"
+)
+
+const (
+	WebhookType     = "webhook"
+	CloudEventsType = "cloudevents"
+	SlackType       = "slack"
+)
+
+// Event is change of environment, that is delivered to sinks
+type Event struct {
+	Type                 string                `json:"type"`
+	Time                 string                `json:"time"`
+	PreviousHealthStatus string                `json:"previousHealthStatus"`
+	Environment          codefresh.Environment `json:"environment"`
+	// ctx carries trace of environment update, that produced event
+	ctx context.Context
+}
+
+// Context returns context of pipeline, that produced event
+func (event Event) Context() context.Context {
+	if event.ctx == nil {
+		return context.Background()
+	}
+	return event.ctx
+}
+
+type Sink interface {
+	Name() string
+	Send(event Event) error
+}
+
+type Filter struct {
+	// Applications are path patterns of application names, like "payments-*"
+	Applications []string `json:"applications"`
+	EventTypes   []string `json:"eventTypes"`
+}
+
+type Retry struct {
+	Attempts int    `json:"attempts"`
+	Backoff  string `json:"backoff"`
+}
+
+type Config struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Secret is used for HMAC signature of webhook payload
+	Secret string `json:"secret"`
+	// Mode of cloudevents sink, "structured" or "binary"
+	Mode string `json:"mode"`
+	// Template is go template of payload, rendered with Event
+	Template string `json:"template"`
+	Filter   Filter `json:"filter"`
+	Retry    Retry  `json:"retry"`
+}
+
+func (filter Filter) Matches(event Event) bool {
+	if len(filter.EventTypes) > 0 {
+		matched := false
+		for _, eventType := range filter.EventTypes {
+			if eventType == event.Type {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(filter.Applications) > 0 {
+		for _, pattern := range filter.Applications {
+			if matched, _ := path.Match(pattern, event.Environment.Name); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+func (retry Retry) attempts() int {
+	if retry.Attempts <= 0 {
+		return 3
+	}
+	return retry.Attempts
+}
+
+func (retry Retry) backoff() time.Duration {
+	backoff, err := time.ParseDuration(retry.Backoff)
+	if err != nil || backoff <= 0 {
+		return time.Second
+	}
+	return backoff
+}
+
+// withDefaults returns config with defaults of its sink type, slack sink gets only health changes by default
+func (config Config) withDefaults() Config {
+	if config.Type == SlackType && len(config.Filter.EventTypes) == 0 {
+		config.Filter.EventTypes = []string{EnvironmentHealthChanged}
+	}
+	return config
+}
+
+// New creates sink by its config
+func New(config Config) (Sink, error) {
+	config = config.withDefaults()
+	if config.Url == "" {
+		return nil, fmt.Errorf("sink \"%s\" has no url", config.Name)
+	}
+
+	if config.Template != "" {
+		if _, err := parseTemplate(config); err != nil {
+			return nil, err
+		}
+	}
+
+	switch config.Type {
+	case WebhookType:
+		return newWebhookSink(config), nil
+	case CloudEventsType:
+		if config.Mode != "" && config.Mode != StructuredMode && config.Mode != BinaryMode {
+			return nil, fmt.Errorf("sink \"%s\" has unknown cloudevents mode \"%s\"", config.Name, config.Mode)
+		}
+		return newCloudEventsSink(config), nil
+	case SlackType:
+		return newSlackSink(config), nil
+	}
+
+	return nil, fmt.Errorf("sink \"%s\" has unknown type \"%s\"", config.Name, config.Type)
+}
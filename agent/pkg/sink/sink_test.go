@@ -0,0 +1,239 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dryrun"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type MockSink struct {
+	events []Event
+}
+
+func (sink *MockSink) Name() string {
+	return "mock"
+}
+
+func (sink *MockSink) Send(event Event) error {
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+func TestDispatchHealthChanged(t *testing.T) {
+	primary := &MockSink{}
+	d := &Dispatcher{primary: primary, healthStatuses: make(map[string]string)}
+
+	_ = d.Dispatch(context.Background(), EnvironmentUpdated, codefresh.Environment{Name: "app", HealthStatus: "Progressing"})
+	events := d.events(context.Background(), EnvironmentUpdated, codefresh.Environment{Name: "app", HealthStatus: "Degraded"})
+
+	if len(events) != 2 || events[1].Type != EnvironmentHealthChanged || events[1].PreviousHealthStatus != "Progressing" {
+		t.Errorf("Health change should be detected, got %v", events)
+	}
+
+	if len(primary.events) != 1 || primary.events[0].Type != EnvironmentUpdated {
+		t.Errorf("Primary sink should receive update event, got %v", primary.events)
+	}
+}
+
+type FailingSink struct {
+}
+
+func (sink *FailingSink) Name() string {
+	return "failing"
+}
+
+func (sink *FailingSink) Send(event Event) error {
+	return errors.New("codefresh is unavailable")
+}
+
+func TestDispatchToSinksAfterPrimary(t *testing.T) {
+	secondary := &asyncSink{sink: &MockSink{}, events: make(chan Event, eventsBufferSize)}
+	d := &Dispatcher{primary: &FailingSink{}, sinks: []*asyncSink{secondary}, healthStatuses: make(map[string]string)}
+	env := codefresh.Environment{Name: "app", HealthStatus: "Healthy"}
+
+	err := d.Dispatch(context.Background(), EnvironmentUpdated, env)
+	if err == nil || len(secondary.events) != 0 {
+		t.Errorf("'Dispatch' failed, expected no events for sinks, when codefresh failed, got '%v'", len(secondary.events))
+	}
+
+	d.primary = &MockSink{}
+	err = d.Dispatch(context.Background(), EnvironmentUpdated, env)
+	if err != nil || len(secondary.events) != 1 {
+		t.Errorf("'Dispatch' failed, expected '%v' event for sinks, got '%v'", 1, len(secondary.events))
+	}
+}
+
+type BatchingSink struct {
+	MockSink
+}
+
+func (sink *BatchingSink) Deferred() bool {
+	return true
+}
+
+func TestDispatchToSinksAfterBatch(t *testing.T) {
+	secondary := &asyncSink{sink: &MockSink{}, events: make(chan Event, eventsBufferSize)}
+	d := &Dispatcher{primary: &BatchingSink{}, sinks: []*asyncSink{secondary}, healthStatuses: make(map[string]string)}
+
+	_ = d.Dispatch(context.Background(), EnvironmentUpdated, codefresh.Environment{Name: "app", HealthStatus: "Progressing"})
+	_ = d.Dispatch(context.Background(), EnvironmentUpdated, codefresh.Environment{Name: "app", HealthStatus: "Healthy"})
+	if len(secondary.events) != 0 {
+		t.Errorf("'Dispatch' failed, expected no events for sinks before batch is delivered, got '%v'", len(secondary.events))
+	}
+
+	d.Delivered(codefresh.Environment{Name: "app", HealthStatus: "Healthy"})
+	if len(secondary.events) != 1 {
+		t.Errorf("'Delivered' failed, expected only latest update for sinks, got '%v'", len(secondary.events))
+	}
+	if event := <-secondary.events; event.Environment.HealthStatus != "Healthy" {
+		t.Errorf("'Delivered' failed, expected '%v', got '%v'", "Healthy", event.Environment.HealthStatus)
+	}
+
+	_ = d.Dispatch(context.Background(), EnvironmentUpdated, codefresh.Environment{Name: "app", HealthStatus: "Degraded"})
+	d.Dropped(codefresh.Environment{Name: "app"})
+	d.Delivered(codefresh.Environment{Name: "app", HealthStatus: "Degraded"})
+	if len(secondary.events) != 0 {
+		t.Errorf("'Dropped' failed, expected no events of dropped update, got '%v'", len(secondary.events))
+	}
+}
+
+func TestInitSlackDefaultFilter(t *testing.T) {
+	defer func() { dispatcher = nil }()
+	dispatcher = &Dispatcher{primary: &MockSink{}, healthStatuses: make(map[string]string)}
+
+	err := Init([]Config{{Name: "slack", Type: SlackType, Url: "http://localhost"}})
+	if err != nil {
+		t.Errorf("'Init' failed, unexpected error '%v'", err)
+		return
+	}
+
+	filter := dispatcher.sinks[0].config.Filter
+	updated := Event{Type: EnvironmentUpdated, Environment: codefresh.Environment{Name: "app"}}
+	healthChanged := Event{Type: EnvironmentHealthChanged, Environment: codefresh.Environment{Name: "app"}}
+	if filter.Matches(updated) || !filter.Matches(healthChanged) {
+		t.Errorf("'Init' failed, expected slack sink to get only health changes, got filter '%v'", filter)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	filter := Filter{Applications: []string{"payments-*"}, EventTypes: []string{EnvironmentHealthChanged}}
+
+	if !filter.Matches(Event{Type: EnvironmentHealthChanged, Environment: codefresh.Environment{Name: "payments-api"}}) {
+		t.Errorf("Filter should match application by pattern")
+	}
+
+	if filter.Matches(Event{Type: EnvironmentUpdated, Environment: codefresh.Environment{Name: "payments-api"}}) {
+		t.Errorf("Filter should not match other event types")
+	}
+
+	if filter.Matches(Event{Type: EnvironmentHealthChanged, Environment: codefresh.Environment{Name: "orders"}}) {
+		t.Errorf("Filter should not match other applications")
+	}
+}
+
+func TestWebhookSignatureAndTemplate(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer server.Close()
+
+	sink, err := New(Config{
+		Name:     "webhook",
+		Type:     WebhookType,
+		Url:      server.URL,
+		Secret:   "secret",
+		Template: `{"app":{{ .Environment.Name | toJson }}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Send(Event{Type: EnvironmentUpdated, Environment: codefresh.Environment{Name: "app"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != `{"app":"app"}` {
+		t.Errorf("Webhook payload should be rendered with template, got %s", body)
+	}
+
+	if signature != sign(body, "secret") {
+		t.Errorf("Webhook payload should be signed, got signature %s", signature)
+	}
+}
+
+func TestDryRunSendsNothing(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "dryrun")
+	defer os.RemoveAll(dir)
+	err := dryrun.Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dryrun.Close()
+
+	for _, sinkType := range []string{WebhookType, CloudEventsType, SlackType} {
+		sink, err := New(Config{Name: sinkType, Type: sinkType, Url: server.URL + "/token"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sink.Send(Event{Type: EnvironmentHealthChanged, Environment: codefresh.Environment{Name: "app"}})
+		if err != nil {
+			t.Errorf("'Send' of %s sink failed, reason %v", sinkType, err)
+		}
+	}
+
+	if requests != 0 {
+		t.Errorf("Sinks should send nothing in dry run, got %v requests", requests)
+	}
+
+	records, _ := dryrun.ReadRecords(filepath.Join(dir, dryrun.FileName))
+	if len(records) != 3 || strings.Contains(records[0].Path, "token") {
+		t.Errorf("Deliveries should be recorded without path of url, got '%v'", records)
+	}
+}
+
+func TestCloudEventsModes(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	event := Event{Type: EnvironmentUpdated, Environment: codefresh.Environment{Name: "app"}}
+
+	binary, _ := New(Config{Name: "binary", Type: CloudEventsType, Url: server.URL, Mode: BinaryMode})
+	_ = binary.Send(event)
+
+	if headers.Get("ce-specversion") != "1.0" || headers.Get("ce-type") != "io.codefresh.argocd.environment.updated" || headers.Get("ce-subject") != "app" {
+		t.Errorf("Binary cloudevent should have ce headers, got %v", headers)
+	}
+
+	structured, _ := New(Config{Name: "structured", Type: CloudEventsType, Url: server.URL})
+	_ = structured.Send(event)
+
+	var ce cloudEvent
+	_ = json.Unmarshal(body, &ce)
+	if headers.Get("Content-Type") != "application/cloudevents+json" || ce.SpecVersion != "1.0" || ce.Subject != "app" {
+		t.Errorf("Structured cloudevent should be sent in body, got %s", body)
+	}
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SlackSink posts messages to slack incoming webhook, template of sink is used for message text
+type SlackSink struct {
+	config Config
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func newSlackSink(config Config) *SlackSink {
+	return &SlackSink{config: config}
+}
+
+func (sink *SlackSink) Name() string {
+	return sink.config.Name
+}
+
+func defaultSlackText(event Event) string {
+	env := event.Environment
+	switch event.Type {
+	case EnvironmentHealthChanged:
+		return fmt.Sprintf("Application *%s* health changed from *%s* to *%s*, sync status *%s*, revision `%s`",
+			env.Name, event.PreviousHealthStatus, env.HealthStatus, env.SyncStatus, env.SyncRevision)
+	case EnvironmentDeleted:
+		return fmt.Sprintf("Application *%s* was deleted", env.Name)
+	}
+	return fmt.Sprintf("Application *%s* is *%s* and *%s*, revision `%s`", env.Name, env.HealthStatus, env.SyncStatus, env.SyncRevision)
+}
+
+func (sink *SlackSink) Send(event Event) error {
+	text := defaultSlackText(event)
+
+	if sink.config.Template != "" {
+		rendered, err := renderPayload(sink.config, event, nil)
+		if err != nil {
+			return err
+		}
+		text = string(rendered)
+	}
+
+	body, err := json.Marshal(slackMessage{Text: text})
+	if err != nil {
+		return err
+	}
+
+	return post(event.Context(), sink.config.Url, map[string]string{"Content-Type": "application/json"}, body)
+}
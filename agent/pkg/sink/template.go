@@ -0,0 +1,32 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Masterminds/sprig"
+	"text/template"
+)
+
+func parseTemplate(config Config) (*template.Template, error) {
+	return template.New(config.Name).Funcs(sprig.TxtFuncMap()).Parse(config.Template)
+}
+
+// renderPayload renders template of sink with event or returns json of fallback when template isn't defined
+func renderPayload(config Config, event Event, fallback interface{}) ([]byte, error) {
+	if config.Template == "" {
+		return json.Marshal(fallback)
+	}
+
+	tmpl, err := parseTemplate(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload bytes.Buffer
+	err = tmpl.Execute(&payload, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignatureHeader contains HMAC SHA256 signature of webhook payload, when sink has secret
+const SignatureHeader = "X-Codefresh-Signature-256"
+
+type WebhookSink struct {
+	config Config
+}
+
+func newWebhookSink(config Config) *WebhookSink {
+	return &WebhookSink{config: config}
+}
+
+func (sink *WebhookSink) Name() string {
+	return sink.config.Name
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (sink *WebhookSink) Send(event Event) error {
+	body, err := renderPayload(sink.config, event, event)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	for key, value := range sink.config.Headers {
+		headers[key] = value
+	}
+	if sink.config.Secret != "" {
+		headers[SignatureHeader] = sign(body, sink.config.Secret)
+	}
+
+	return post(event.Context(), sink.config.Url, headers, body)
+}
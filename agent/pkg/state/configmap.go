@@ -0,0 +1,65 @@
+package state
+
+import (
+	"encoding/json"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const configMapKey = "state.json"
+
+// ConfigMapBackend keeps state in configmap, configmap is created on first save
+type ConfigMapBackend struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+func (backend *ConfigMapBackend) Load() (map[string]string, error) {
+	hashes := make(map[string]string)
+	configMap, err := backend.Client.CoreV1().ConfigMaps(backend.Namespace).Get(backend.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := configMap.Data[configMapKey]
+	if !ok {
+		return hashes, nil
+	}
+	err = json.Unmarshal([]byte(data), &hashes)
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (backend *ConfigMapBackend) Save(hashes map[string]string) error {
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+
+	configMaps := backend.Client.CoreV1().ConfigMaps(backend.Namespace)
+	configMap, err := configMaps.Get(backend.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: backend.Name, Namespace: backend.Namespace},
+			Data:       map[string]string{configMapKey: string(data)},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[configMapKey] = string(data)
+	_, err = configMaps.Update(configMap)
+	return err
+}
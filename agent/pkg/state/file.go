@@ -0,0 +1,53 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileBackend keeps state in json file, file should be on persistent volume to survive restart of pod
+type FileBackend struct {
+	Path string
+}
+
+func (backend *FileBackend) Load() (map[string]string, error) {
+	hashes := make(map[string]string)
+	data, err := ioutil.ReadFile(backend.Path)
+	if os.IsNotExist(err) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &hashes)
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (backend *FileBackend) Save(hashes map[string]string) error {
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file first, so state isn't corrupted if agent is killed in the middle of write
+	tmpFile, err := ioutil.TempFile(filepath.Dir(backend.Path), filepath.Base(backend.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), backend.Path)
+}
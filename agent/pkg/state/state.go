@@ -0,0 +1,263 @@
+package state
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSize is enough for few thousands of applications with their environments
+const DefaultMaxSize = 10000
+
+// rememberedPrefix marks remembered keys in backend, they are saved with hashes, but aren't part of lru
+const rememberedPrefix = "remembered."
+
+// Backend persists state between restarts of agent
+type Backend interface {
+	Load() (map[string]string, error)
+	Save(hashes map[string]string) error
+}
+
+type item struct {
+	key  string
+	hash string
+}
+
+// Store keeps hashes of items, that were sent to codefresh, least recently used items are evicted when store is full
+type Store struct {
+	entries map[string]*list.Element
+	lru     *list.List
+	maxSize int
+	// remembered keeps keys till their expiration, they aren't evicted when store is full
+	remembered map[string]time.Time
+	backend    Backend
+	dirty      bool
+	lock       sync.Mutex
+}
+
+var (
+	store     *Store
+	storeOnce sync.Once
+	storeLock sync.RWMutex
+)
+
+// GetInstance returns store, that is not persisted, until Init is called
+func GetInstance() *Store {
+	storeOnce.Do(func() {
+		storeLock.Lock()
+		defer storeLock.Unlock()
+		if store == nil {
+			store, _ = New(DefaultMaxSize, nil)
+		}
+	})
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+	return store
+}
+
+// Init replaces store with one, that is restored from backend and persisted to it every interval
+func Init(maxSize int, backend Backend, interval time.Duration) error {
+	newStore, err := New(maxSize, backend)
+	if err != nil {
+		return err
+	}
+	storeLock.Lock()
+	store = newStore
+	storeLock.Unlock()
+	if backend != nil {
+		go newStore.persist(interval)
+	}
+	return nil
+}
+
+func New(maxSize int, backend Backend) (*Store, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	s := &Store{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxSize:    maxSize,
+		remembered: make(map[string]time.Time),
+		backend:    backend,
+	}
+
+	if backend == nil {
+		return s, nil
+	}
+
+	hashes, err := backend.Load()
+	if err != nil {
+		return nil, err
+	}
+	for key, hash := range hashes {
+		if strings.HasPrefix(key, rememberedPrefix) {
+			until, err := time.Parse(time.RFC3339, hash)
+			if err == nil {
+				s.remembered[strings.TrimPrefix(key, rememberedPrefix)] = until
+			}
+			continue
+		}
+		s.set(key, hash)
+	}
+	s.dirty = false
+
+	return s, nil
+}
+
+// Hash returns sha256 of json representation of data, so only hash is kept in memory instead of whole payload
+func Hash(data interface{}) (string, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Persistent says if store is restored from backend after restart
+func (s *Store) Persistent() bool {
+	return s.backend != nil
+}
+
+func (s *Store) Get(key string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*item).hash, true
+}
+
+func (s *Store) Set(key string, hash string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.set(key, hash)
+}
+
+func (s *Store) set(key string, hash string) {
+	s.dirty = true
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*item).hash = hash
+		s.lru.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&item{key: key, hash: hash})
+
+	for s.lru.Len() > s.maxSize {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*item).key)
+	}
+}
+
+// Delete evicts item, so deleted application doesn't stay in store forever
+func (s *Store) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.lru.Remove(element)
+		delete(s.entries, key)
+		s.dirty = true
+	}
+}
+
+// DeletePrefix evicts all items with keys, that start with prefix
+func (s *Store) DeletePrefix(prefix string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, element := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.lru.Remove(element)
+			delete(s.entries, key)
+			s.dirty = true
+		}
+	}
+}
+
+// Remember keeps key till time, like id of executed command till its expiration, expired keys are forgotten
+func (s *Store) Remember(key string, until time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for remembered, expiresAt := range s.remembered {
+		if now.After(expiresAt) {
+			delete(s.remembered, remembered)
+		}
+	}
+	s.remembered[key] = until
+	s.dirty = true
+}
+
+// Remembers says if key was remembered and isn't expired yet
+func (s *Store) Remembers(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	until, ok := s.remembered[key]
+	return ok && !time.Now().After(until)
+}
+
+func (s *Store) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lru.Len()
+}
+
+// Snapshot returns copy of all hashes
+func (s *Store) Snapshot() map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	hashes := make(map[string]string, len(s.entries))
+	for key, element := range s.entries {
+		hashes[key] = element.Value.(*item).hash
+	}
+	return hashes
+}
+
+// Flush saves state to backend, if it was changed after last save
+func (s *Store) Flush() error {
+	if s.backend == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	if !s.dirty {
+		s.lock.Unlock()
+		return nil
+	}
+	s.dirty = false
+	s.lock.Unlock()
+
+	hashes := s.Snapshot()
+	s.lock.Lock()
+	for key, until := range s.remembered {
+		hashes[rememberedPrefix+key] = until.UTC().Format(time.RFC3339)
+	}
+	s.lock.Unlock()
+
+	err := s.backend.Save(hashes)
+	if err != nil {
+		s.lock.Lock()
+		s.dirty = true
+		s.lock.Unlock()
+	}
+	return err
+}
+
+func (s *Store) persist(interval time.Duration) {
+	for range time.Tick(interval) {
+		err := s.Flush()
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to persist state, reason %v", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"io/ioutil"
+	"k8s.io/client-go/kubernetes/fake"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEviction(t *testing.T) {
+	s, _ := New(2, nil)
+	s.Set("environment.argocd/a", "1")
+	s.Set("environment.argocd/b", "2")
+	s.Get("environment.argocd/a")
+	s.Set("environment.argocd/c", "3")
+
+	if _, ok := s.Get("environment.argocd/b"); ok {
+		t.Errorf("'Set' failed, least recently used item should be evicted")
+	}
+	if hash, _ := s.Get("environment.argocd/a"); hash != "1" {
+		t.Errorf("'Get' failed, expected '1', got '%v'", hash)
+	}
+
+	s.Delete("environment.argocd/a")
+	if s.Len() != 1 {
+		t.Errorf("'Delete' failed, expected '1', got '%v'", s.Len())
+	}
+}
+
+func TestHash(t *testing.T) {
+	hash1, _ := Hash(map[string]interface{}{"name": "app", "health": "Healthy"})
+	hash2, _ := Hash(map[string]interface{}{"health": "Healthy", "name": "app"})
+	hash3, _ := Hash(map[string]interface{}{"health": "Degraded", "name": "app"})
+
+	if hash1 != hash2 || hash1 == hash3 {
+		t.Errorf("'Hash' failed, hash should depend on content only")
+	}
+}
+
+func testBackend(t *testing.T, backend Backend) {
+	s, err := New(10, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("projects", "1")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("applications", "2")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := New(10, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, _ := restored.Get("applications"); hash != "2" || restored.Len() != 2 {
+		t.Errorf("'Load' failed, expected '2', got '%v'", hash)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "state")
+	defer os.RemoveAll(dir)
+
+	testBackend(t, &FileBackend{Path: filepath.Join(dir, "state.json")})
+}
+
+func TestConfigMapBackend(t *testing.T) {
+	testBackend(t, &ConfigMapBackend{Client: fake.NewSimpleClientset(), Namespace: "argocd", Name: "state"})
+}
+
+func TestGetInstance(t *testing.T) {
+	instances := make(chan *Store, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			instances <- GetInstance()
+		}()
+	}
+	first := <-instances
+	for i := 1; i < 10; i++ {
+		if instance := <-instances; instance != first {
+			t.Errorf("'GetInstance' failed, expected same store for all callers")
+		}
+	}
+
+	_ = Init(0, nil, 0)
+	if GetInstance() == first {
+		t.Errorf("'Init' failed, expected store to be replaced")
+	}
+}
+
+func TestRemember(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "state")
+	defer os.RemoveAll(dir)
+	backend := &FileBackend{Path: filepath.Join(dir, "state.json")}
+
+	s, _ := New(1, backend)
+	s.Remember("command.1", time.Now().Add(time.Minute))
+	s.Set("environment.1", "1")
+	s.Set("environment.2", "2")
+	if !s.Remembers("command.1") {
+		t.Errorf("'Remembers' failed, remembered key shouldn't be evicted")
+	}
+
+	s.Remember("command.2", time.Now().Add(-time.Minute))
+	if s.Remembers("command.2") {
+		t.Errorf("'Remembers' failed, expired key should be forgotten")
+	}
+
+	_ = s.Flush()
+	restored, _ := New(1, backend)
+	if !restored.Remembers("command.1") || restored.Len() != 1 {
+		t.Errorf("'New' failed, expected remembered key to be restored apart from hashes, got '%v'", restored.Snapshot())
+	}
+}
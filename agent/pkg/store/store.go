@@ -1,5 +1,7 @@
 package store
 
+import "time"
+
 var (
 	store *Values
 )
@@ -8,6 +10,18 @@ type Environment struct {
 	Name string
 }
 
+// SyncRule selects applications for sync in SELECT mode, all non empty conditions of rule should match
+type SyncRule struct {
+	// Labels is kubernetes label selector, like "team=a,env in (prod,staging)"
+	Labels   string   `json:"labels"`
+	Projects []string `json:"projects"`
+	// NameRegex is matched against application name
+	NameRegex string `json:"nameRegex"`
+	// Namespaces and Clusters are matched against application destination
+	Namespaces []string `json:"namespaces"`
+	Clusters   []string `json:"clusters"`
+}
+
 type (
 	Values struct {
 		Agent struct {
@@ -17,8 +31,12 @@ type (
 			Token string
 		}
 		Argo struct {
-			Token string
-			Host  string
+			Token     string
+			Host      string
+			Namespace string
+			// Username and Password are kept only when token was created from them, so it can be renewed
+			Username string
+			Password string
 		}
 		Codefresh struct {
 			Host                string
@@ -26,9 +44,23 @@ type (
 			Integration         string
 			SyncMode            string
 			ApplicationsForSync []string
+			SyncRules           []SyncRule
+			// Gzip enables compression of request bodies
+			Gzip bool
+			// BatchInterval is how long environment updates are collected before sending, batching is disabled when zero
+			BatchInterval time.Duration
+			// MaxPayloadSize limits size of batches and, when Chunking is enabled, of resources lists
+			MaxPayloadSize int
+			// Chunking enables sending of bigger resources lists in chunks, that codefresh reassembles by snapshot id
+			Chunking bool
 		}
-		Heartbeat struct {
-			Error string
+		Commands struct {
+			Secret  string
+			Allowed []string
+		}
+		Reconcile struct {
+			Interval string
+			Repair   []string
 		}
 		Environments []Environment
 	}
@@ -49,6 +81,19 @@ func SetArgo(token string, host string) *Values {
 	return values
 }
 
+func SetArgoCredentials(username string, password string) *Values {
+	values := GetStore()
+	values.Argo.Username = username
+	values.Argo.Password = password
+	return values
+}
+
+func SetArgoNamespace(namespace string) *Values {
+	values := GetStore()
+	values.Argo.Namespace = namespace
+	return values
+}
+
 func SetCodefresh(host string, token string, integration string) *Values {
 	values := GetStore()
 	values.Codefresh.Token = token
@@ -57,6 +102,15 @@ func SetCodefresh(host string, token string, integration string) *Values {
 	return values
 }
 
+func SetCodefreshUploads(gzip bool, batchInterval time.Duration, maxPayloadSize int, chunking bool) *Values {
+	values := GetStore()
+	values.Codefresh.Gzip = gzip
+	values.Codefresh.BatchInterval = batchInterval
+	values.Codefresh.MaxPayloadSize = maxPayloadSize
+	values.Codefresh.Chunking = chunking
+	return values
+}
+
 func SetSyncOptions(syncMode string, applicationsToSync []string) *Values {
 	values := GetStore()
 	values.Codefresh.SyncMode = syncMode
@@ -64,9 +118,23 @@ func SetSyncOptions(syncMode string, applicationsToSync []string) *Values {
 	return values
 }
 
-func SetHeartbeatError(error string) *Values {
+func SetSyncRules(syncRules []SyncRule) *Values {
+	values := GetStore()
+	values.Codefresh.SyncRules = syncRules
+	return values
+}
+
+func SetCommands(secret string, allowed []string) *Values {
+	values := GetStore()
+	values.Commands.Secret = secret
+	values.Commands.Allowed = allowed
+	return values
+}
+
+func SetReconcile(interval string, repair []string) *Values {
 	values := GetStore()
-	values.Heartbeat.Error = error
+	values.Reconcile.Interval = interval
+	values.Reconcile.Repair = repair
 	return values
 }
 
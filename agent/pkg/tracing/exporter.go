@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxBatchSize  = 512
+	maxQueueSize  = 4096
+	flushInterval = 5 * time.Second
+	// failures of unavailable collector repeat on every flush, so they are logged not more often than that
+	failureLogInterval = time.Minute
+)
+
+// Exporter sends finished spans to OTLP http endpoint with json encoding
+type Exporter struct {
+	url     string
+	headers map[string]string
+	service string
+	client  *http.Client
+	spans   []*Span
+	lock    sync.Mutex
+	flushCh chan struct{}
+	// loggedFailureAt is time of last logged failure, used only by export
+	loggedFailureAt time.Time
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func newExporter(cfg Config) *Exporter {
+	return &Exporter{
+		url:     strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces",
+		headers: cfg.Headers,
+		service: cfg.ServiceName,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		flushCh: make(chan struct{}, 1),
+	}
+}
+
+func (e *Exporter) add(span *Span) {
+	e.lock.Lock()
+	if len(e.spans) < maxQueueSize {
+		e.spans = append(e.spans, span)
+	}
+	full := len(e.spans) >= maxBatchSize
+	e.lock.Unlock()
+
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Exporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flushCh:
+		}
+		e.export()
+	}
+}
+
+// export flushes spans and reports result to diagnostics, failures are logged with rate limit
+func (e *Exporter) export() {
+	err := e.Flush()
+	diagnostics.ReportError(diagnostics.TracingSubsystem, "export", err)
+	if err == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(e.loggedFailureAt) < failureLogInterval {
+		return
+	}
+	e.loggedFailureAt = now
+	logger.GetLogger().Warnf("Failed to export spans to \"%s\", reason %v", e.url, err)
+}
+
+func attribute(key string, value interface{}) otlpAttribute {
+	var v otlpValue
+	switch typed := value.(type) {
+	case string:
+		v.StringValue = &typed
+	case bool:
+		v.BoolValue = &typed
+	case int:
+		s := strconv.Itoa(typed)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(typed, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &typed
+	default:
+		s := fmt.Sprintf("%v", typed)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
+
+func convert(span *Span) otlpSpan {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	result := otlpSpan{
+		TraceId:           span.context.TraceId.String(),
+		SpanId:            span.context.SpanId.String(),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        make([]otlpAttribute, 0, len(span.attributes)),
+		Status:            otlpStatus{Code: span.statusCode, Message: span.statusMsg},
+	}
+	if span.parentSpanId != (SpanId{}) {
+		result.ParentSpanId = span.parentSpanId.String()
+	}
+	for key, value := range span.attributes {
+		result.Attributes = append(result.Attributes, attribute(key, value))
+	}
+	return result
+}
+
+// Flush sends all finished spans
+func (e *Exporter) Flush() error {
+	e.lock.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.lock.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	scopeSpans := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scopeSpans.Scope.Name = e.service
+	for _, span := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, convert(span))
+	}
+
+	resourceSpans := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scopeSpans}}
+	resourceSpans.Resource.Attributes = []otlpAttribute{attribute("service.name", e.service)}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{resourceSpans}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to export %v spans, status %v", len(spans), resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is w3c trace context header
+const TraceparentHeader = "traceparent"
+
+// Inject writes trace context of span from context to request headers
+func Inject(ctx context.Context, header http.Header) {
+	sc, ok := parentSpanContext(ctx)
+	if !ok {
+		return
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	header.Set(TraceparentHeader, fmt.Sprintf("00-%s-%s-%s", sc.TraceId, sc.SpanId, flags))
+}
+
+// Extract reads trace context from request headers
+func Extract(header http.Header) (SpanContext, bool) {
+	parts := strings.Split(header.Get(TraceparentHeader), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	traceId, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	spanId, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	copy(sc.TraceId[:], traceId)
+	copy(sc.SpanId[:], spanId)
+	sc.Sampled = parts[3] == "01"
+
+	return sc, sc.IsValid()
+}
@@ -0,0 +1,226 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+const (
+	StatusUnset = 0
+	StatusOk    = 1
+	StatusError = 2
+)
+
+type TraceId [16]byte
+
+type SpanId [8]byte
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext identifies span across process boundaries
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId != TraceId{} && sc.SpanId != SpanId{}
+}
+
+type Span struct {
+	name         string
+	kind         int
+	context      SpanContext
+	parentSpanId SpanId
+	start        time.Time
+	end          time.Time
+	attributes   map[string]interface{}
+	statusCode   int
+	statusMsg    string
+	lock         sync.Mutex
+	ended        bool
+}
+
+type Config struct {
+	// Endpoint is OTLP http endpoint, like http://localhost:4318, tracing is disabled when empty
+	Endpoint    string
+	ServiceName string
+	// SampleRatio is part of new traces, that are recorded, from 0 to 1
+	SampleRatio float64
+	Headers     map[string]string
+}
+
+var (
+	config   Config
+	exporter *Exporter
+)
+
+// Init enables tracing, spans are created but not exported until Init is called
+func Init(cfg Config) {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "argocd-agent"
+	}
+	config = cfg
+	exporter = newExporter(cfg)
+	go exporter.run()
+}
+
+// ParseHeaders parses headers in "key1=value1,key2=value2" format of OTEL_EXPORTER_OTLP_HEADERS
+func ParseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return headers
+}
+
+// Enabled returns true if spans are exported
+func Enabled() bool {
+	return exporter != nil
+}
+
+type spanKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+type remoteKey struct{}
+
+// ContextWithRemoteSpanContext makes spans started with returned context children of span from other process or goroutine
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func parentSpanContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context, true
+	}
+	if ctx != nil {
+		if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok && sc.IsValid() {
+			return sc, true
+		}
+	}
+	return SpanContext{}, false
+}
+
+func randomBytes(b []byte) {
+	_, _ = rand.Read(b)
+}
+
+// shouldSample keeps sampling decision of parent, new traces are sampled by trace id, so decision is deterministic
+func shouldSample(traceId TraceId) bool {
+	if config.SampleRatio >= 1 {
+		return true
+	}
+	if config.SampleRatio <= 0 {
+		return false
+	}
+	bound := uint64(config.SampleRatio * float64(^uint64(0)>>1))
+	return binary.BigEndian.Uint64(traceId[8:])>>1 < bound
+}
+
+// Start creates span, that is child of span from context
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	return StartAt(ctx, name, kind, time.Now())
+}
+
+// StartAt creates span with explicit start time, like time when item was put to queue
+func StartAt(ctx context.Context, name string, kind int, start time.Time) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      start,
+		attributes: make(map[string]interface{}),
+	}
+
+	if parent, ok := parentSpanContext(ctx); ok {
+		span.context.TraceId = parent.TraceId
+		span.context.Sampled = parent.Sampled
+		span.parentSpanId = parent.SpanId
+	} else {
+		randomBytes(span.context.TraceId[:])
+		span.context.Sampled = shouldSample(span.context.TraceId)
+	}
+	randomBytes(span.context.SpanId[:])
+
+	return ContextWithSpan(ctx, span), span
+}
+
+func (span *Span) Context() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+	return span.context
+}
+
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.attributes[key] = value
+}
+
+// RecordError marks span as failed, nil error is ignored
+func (span *Span) RecordError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.statusCode = StatusError
+	span.statusMsg = err.Error()
+}
+
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	if span.ended {
+		span.lock.Unlock()
+		return
+	}
+	span.ended = true
+	span.end = time.Now()
+	span.lock.Unlock()
+
+	if exporter != nil && span.context.Sampled {
+		exporter.add(span)
+	}
+}
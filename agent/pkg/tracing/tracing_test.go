@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSpansAreExportedToCollector(t *testing.T) {
+	var received otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("'Flush' failed, expected path '/v1/traces', got '%v'", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer collector.Close()
+
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(TraceparentHeader)
+	}))
+	defer backend.Close()
+
+	config = Config{ServiceName: "test", SampleRatio: 1}
+	exporter = newExporter(Config{Endpoint: collector.URL, ServiceName: "test"})
+	defer func() { exporter = nil }()
+
+	ctx, parent := Start(context.Background(), "queue.Process", KindInternal)
+	req, _ := http.NewRequestWithContext(ctx, "POST", backend.URL+"/api/environments-v2/argo/events", nil)
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, traceparent)
+	sc, ok := Extract(header)
+	if !ok || sc.TraceId != parent.Context().TraceId || !sc.Sampled {
+		t.Errorf("'Inject' failed, expected trace '%v', got '%v'", parent.Context().TraceId, traceparent)
+	}
+
+	if err := exporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("'Flush' failed, expected '2' spans, got '%v'", len(spans))
+	}
+	if spans[0].Name != "HTTP POST" || spans[0].ParentSpanId != parent.Context().SpanId.String() {
+		t.Errorf("'Flush' failed, expected client span child of '%v', got '%v'", parent.Context().SpanId, spans[0].ParentSpanId)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	config = Config{SampleRatio: 0}
+	defer func() { config = Config{} }()
+
+	ctx, span := Start(context.Background(), "informer.Update", KindInternal)
+	if span.Context().Sampled {
+		t.Errorf("'Start' failed, expected not sampled span with ratio '0'")
+	}
+
+	remote := SpanContext{Sampled: true}
+	remote.TraceId[0], remote.SpanId[0] = 1, 1
+	_, child := Start(ContextWithRemoteSpanContext(ctx, remote), "queue.Process", KindInternal)
+	if child.Context().TraceId != span.Context().TraceId {
+		t.Errorf("'Start' failed, span from context should be preferred over remote one")
+	}
+
+	_, remoteChild := Start(ContextWithRemoteSpanContext(context.Background(), remote), "queue.Process", KindInternal)
+	if !remoteChild.Context().Sampled || remoteChild.Context().TraceId != remote.TraceId {
+		t.Errorf("'Start' failed, expected sampling decision of parent, got '%v'", remoteChild.Context())
+	}
+}
+
+func TestFailedExportIsReportedToDiagnostics(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer collector.Close()
+
+	e := newExporter(Config{Endpoint: collector.URL, ServiceName: "test"})
+	e.add(&Span{name: "queue.Process"})
+	e.export()
+
+	if _, ok := diagnostics.Errors()[diagnostics.TracingSubsystem]; !ok {
+		t.Errorf("'export' failed, expected error of '%v' subsystem", diagnostics.TracingSubsystem)
+	}
+	if e.loggedFailureAt.IsZero() {
+		t.Errorf("'export' failed, expected failure to be logged")
+	}
+
+	loggedAt := e.loggedFailureAt
+	e.add(&Span{name: "queue.Process"})
+	e.export()
+	if e.loggedFailureAt != loggedAt {
+		t.Errorf("'export' failed, expected repeated failure not to be logged within '%v'", failureLogInterval)
+	}
+
+	atomic.StoreInt32(&status, http.StatusOK)
+	e.add(&Span{name: "queue.Process"})
+	e.export()
+	if _, ok := diagnostics.Errors()[diagnostics.TracingSubsystem]; ok {
+		t.Errorf("'export' failed, expected error to be cleared after successful export")
+	}
+}
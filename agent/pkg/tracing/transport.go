@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Transport creates client span for every outbound request and propagates trace context with request headers
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), fmt.Sprintf("HTTP %s", req.Method), KindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+	span.SetAttribute("net.peer.name", req.URL.Hostname())
+
+	// request should not be modified by round tripper
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.RecordError(fmt.Errorf("request failed with status %v", resp.Status))
+	}
+
+	return resp, nil
+}
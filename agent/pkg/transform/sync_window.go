@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"context"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"sync"
+	"time"
+)
+
+const defaultProject = "default"
+
+// SyncWindowsRefresh sends application through pipeline again, when its sync windows open or close
+type SyncWindowsRefresh func(namespace string, name string)
+
+var (
+	syncWindowsRefresh SyncWindowsRefresh
+	refreshTimers      = make(map[string]*time.Timer)
+	refreshLock        sync.Mutex
+)
+
+// SetSyncWindowsRefresh enables refresh of applications at starts and ends of their sync windows,
+// without it state of windows is updated only with next update of application
+func SetSyncWindowsRefresh(refresh SyncWindowsRefresh) {
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+	syncWindowsRefresh = refresh
+}
+
+// ForgetSyncWindows cancels refresh of deleted application
+func ForgetSyncWindows(namespace string, name string) {
+	scheduleRefresh(namespace, name, time.Time{})
+}
+
+// scheduleRefresh replaces planned refresh of application, refresh is cancelled when time is zero
+func scheduleRefresh(namespace string, name string, at time.Time) {
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
+	key := argo.ApplicationKey(namespace, name)
+	if timer, ok := refreshTimers[key]; ok {
+		timer.Stop()
+		delete(refreshTimers, key)
+	}
+	if at.IsZero() || syncWindowsRefresh == nil {
+		return
+	}
+
+	refresh := syncWindowsRefresh
+	refreshTimers[key] = time.AfterFunc(at.Sub(now()), func() {
+		refreshLock.Lock()
+		delete(refreshTimers, key)
+		refreshLock.Unlock()
+		refresh(namespace, name)
+	})
+}
+
+// prepareSyncWindows evaluates sync windows of project of application, it returns nil when project or its windows can't be read,
+// so codefresh doesn't show freeze, that isn't known
+func (envTransformer *EnvTransformer) prepareSyncWindows(ctx context.Context, app argo.ArgoApplication) *codefresh2.EnvironmentSyncWindows {
+	projectName := app.Spec.Project
+	if projectName == "" {
+		projectName = defaultProject
+	}
+
+	log := logger.GetLogger().WithFields(logger.Fields{
+		logger.AppField:     app.Metadata.Name,
+		logger.ProjectField: projectName,
+	})
+
+	project, err := envTransformer.argoApi.GetProject(ctx, projectName)
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "project", err)
+	if err != nil {
+		log.Warnf("Failed to retrieve project to evaluate sync windows, reason %v", err)
+		return nil
+	}
+
+	state, err := argo.EvaluateSyncWindows(project.Spec.SyncWindows, app.Metadata.Name, app.Spec.Destination, now())
+	if err != nil {
+		log.Warnf("Failed to evaluate sync windows, reason %v", err)
+		return nil
+	}
+	scheduleRefresh(app.Metadata.Namespace, app.Metadata.Name, state.ChangesAt)
+
+	result := &codefresh2.EnvironmentSyncWindows{
+		Windows:           make([]codefresh2.SyncWindow, 0, len(state.Windows)),
+		Active:            make([]codefresh2.SyncWindow, 0, len(state.Active)),
+		SyncAllowed:       state.SyncAllowed,
+		ManualSyncAllowed: state.ManualSyncAllowed,
+		OperationBlocked:  argo.IsBlockedBySyncWindow(app.Status.OperationState.Message),
+	}
+	for _, window := range state.Windows {
+		result.Windows = append(result.Windows, adaptSyncWindow(window))
+	}
+	for _, window := range state.Active {
+		active := adaptSyncWindow(window.SyncWindow)
+		active.StartedAt = window.StartedAt.UTC().Format(time.RFC3339)
+		active.EndsAt = window.EndsAt.UTC().Format(time.RFC3339)
+		result.Active = append(result.Active, active)
+	}
+	if !state.NextSyncAt.IsZero() {
+		result.NextSyncAt = state.NextSyncAt.UTC().Format(time.RFC3339)
+	}
+	return result
+}
+
+func adaptSyncWindow(window argo.SyncWindow) codefresh2.SyncWindow {
+	return codefresh2.SyncWindow{
+		Kind:         window.Kind,
+		Schedule:     window.Schedule,
+		Duration:     window.Duration,
+		TimeZone:     window.TimeZone,
+		ManualSync:   window.ManualSync,
+		Applications: window.Applications,
+		Namespaces:   window.Namespaces,
+		Clusters:     window.Clusters,
+	}
+}
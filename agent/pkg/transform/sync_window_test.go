@@ -0,0 +1,93 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"testing"
+	"time"
+)
+
+type projectArgoApi struct {
+	MockArgoApi
+	project *argo.ProjectItem
+}
+
+func (m projectArgoApi) GetProject(ctx context.Context, name string) (*argo.ProjectItem, error) {
+	if m.project == nil {
+		return nil, errors.New("project not found")
+	}
+	return m.project, nil
+}
+
+func TestPrepareSyncWindows(t *testing.T) {
+	SetClock(func() time.Time {
+		parsed, _ := time.Parse(time.RFC3339, "2021-01-10T23:00:00Z")
+		return parsed
+	})
+	defer SetClock(time.Now)
+
+	project := &argo.ProjectItem{}
+	project.Spec.SyncWindows = []argo.SyncWindow{
+		{Kind: argo.DenyWindow, Schedule: "0 22 * * *", Duration: "10h", Applications: []string{"*"}},
+	}
+	app := timelineApplication("frozen-app", "Healthy", "", "")
+	app.Status.OperationState.Message = "one or more synchronization tasks are not valid. Sync operation blocked by sync window"
+
+	transformer := &EnvTransformer{argoApi: projectArgoApi{project: project}}
+	windows := transformer.prepareSyncWindows(context.Background(), app)
+	if windows == nil {
+		t.Errorf("'prepareSyncWindows' failed, expected sync windows")
+		return
+	}
+	if windows.SyncAllowed || !windows.OperationBlocked || windows.NextSyncAt != "2021-01-11T08:00:00Z" {
+		t.Errorf("'prepareSyncWindows' failed, unexpected '%+v'", windows)
+	}
+	if len(windows.Active) != 1 || windows.Active[0].StartedAt != "2021-01-10T22:00:00Z" || windows.Active[0].EndsAt != "2021-01-11T08:00:00Z" {
+		t.Errorf("'prepareSyncWindows' failed, unexpected active windows '%+v'", windows.Active)
+	}
+
+	transformer = &EnvTransformer{argoApi: projectArgoApi{}}
+	if transformer.prepareSyncWindows(context.Background(), app) != nil {
+		t.Errorf("'prepareSyncWindows' failed, expected nil when project is unknown")
+	}
+}
+
+func TestSyncWindowsRefresh(t *testing.T) {
+	current, _ := time.Parse(time.RFC3339, "2021-01-10T21:59:59.9Z")
+	SetClock(func() time.Time {
+		return current
+	})
+	defer SetClock(time.Now)
+
+	refreshed := make(chan string, 1)
+	SetSyncWindowsRefresh(func(namespace string, name string) {
+		refreshed <- argo.ApplicationKey(namespace, name)
+	})
+	defer SetSyncWindowsRefresh(nil)
+
+	project := &argo.ProjectItem{}
+	project.Spec.SyncWindows = []argo.SyncWindow{
+		{Kind: argo.DenyWindow, Schedule: "0 22 * * *", Duration: "1h", Applications: []string{"*"}},
+	}
+	app := timelineApplication("refreshed-app", "Healthy", "", "")
+	transformer := &EnvTransformer{argoApi: projectArgoApi{project: project}}
+	transformer.prepareSyncWindows(context.Background(), app)
+
+	select {
+	case key := <-refreshed:
+		if key != "argocd/refreshed-app" {
+			t.Errorf("'prepareSyncWindows' failed, expected refresh of '%v', got '%v'", "argocd/refreshed-app", key)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("'prepareSyncWindows' failed, expected refresh of application, when window opens")
+	}
+
+	transformer.prepareSyncWindows(context.Background(), app)
+	ForgetSyncWindows("argocd", "refreshed-app")
+	select {
+	case <-refreshed:
+		t.Errorf("'ForgetSyncWindows' failed, expected refresh to be cancelled")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
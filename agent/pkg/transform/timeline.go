@@ -0,0 +1,171 @@
+package transform
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"sync"
+	"time"
+)
+
+const healthyStatus = "Healthy"
+
+// timelineRecord is what agent observed about last sync of application
+type timelineRecord struct {
+	finishedAt string
+	// observed is true when sync was seen before application became healthy
+	observed  bool
+	healthyAt time.Time
+	historyId int64
+	// rollback is kept for all updates of same sync
+	rollbackHistoryId int64
+	rollbackFrom      string
+}
+
+var (
+	timelines     = make(map[string]*timelineRecord)
+	timelinesLock sync.Mutex
+	now           = time.Now
+)
+
+// SetClock replaces source of time, when application is seen healthy, replay uses it to produce same output for same archive
+func SetClock(clock func() time.Time) {
+	now = clock
+}
+
+// ForgetTimeline removes observations of deleted application
+func ForgetTimeline(namespace string, name string) {
+	timelinesLock.Lock()
+	defer timelinesLock.Unlock()
+	delete(timelines, argo.ApplicationKey(namespace, name))
+}
+
+// KnownTimelines returns amount of applications, which syncs are observed
+func KnownTimelines() int {
+	timelinesLock.Lock()
+	defer timelinesLock.Unlock()
+	return len(timelines)
+}
+
+func parseTime(value string) (time.Time, bool) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	return parsed, err == nil
+}
+
+// prepareTimeline computes durations of last sync and detects rollback
+func prepareTimeline(app argo.ArgoApplication, historyId int64) codefresh2.EnvironmentTimeline {
+	operationState := app.Status.OperationState
+	timeline := codefresh2.EnvironmentTimeline{
+		SyncStartedAt:  operationState.StartedAt,
+		SyncFinishedAt: operationState.FinishedAt,
+	}
+
+	startedAt, startedOk := parseTime(operationState.StartedAt)
+	finishedAt, finishedOk := parseTime(operationState.FinishedAt)
+	if startedOk && finishedOk {
+		timeline.SyncDuration = int64(finishedAt.Sub(startedAt).Seconds())
+	}
+
+	key := argo.ApplicationKey(app.Metadata.Namespace, app.Metadata.Name)
+
+	timelinesLock.Lock()
+	defer timelinesLock.Unlock()
+
+	record, known := timelines[key]
+	if !known {
+		record = &timelineRecord{historyId: -1, rollbackHistoryId: -1}
+		timelines[key] = record
+	}
+
+	rollback, rollbackFrom := detectRollback(app.Status.History, operationState.SyncResult.Revision, historyId, record.historyId)
+	if rollback {
+		record.rollbackHistoryId = historyId
+		record.rollbackFrom = rollbackFrom
+	} else if historyId >= 0 && historyId == record.rollbackHistoryId {
+		rollback, rollbackFrom = true, record.rollbackFrom
+	}
+	timeline.Rollback = rollback
+	timeline.PreviousRevision = rollbackFrom
+	if historyId >= 0 {
+		record.historyId = historyId
+	}
+
+	if !finishedOk {
+		return timeline
+	}
+
+	if record.finishedAt != operationState.FinishedAt {
+		// new sync, previous observations don't matter
+		record.finishedAt = operationState.FinishedAt
+		record.observed = false
+		record.healthyAt = time.Time{}
+	}
+
+	if app.Status.Health.Status != healthyStatus {
+		record.observed = true
+		record.healthyAt = time.Time{}
+		return timeline
+	}
+
+	if record.healthyAt.IsZero() {
+		record.healthyAt = healthyAt(app, finishedAt, record.observed)
+	}
+	if !record.healthyAt.IsZero() {
+		timeline.HealthyAt = record.healthyAt.UTC().Format(time.RFC3339)
+		timeline.TimeToHealthy = int64(record.healthyAt.Sub(finishedAt).Seconds())
+	}
+
+	return timeline
+}
+
+// healthyAt returns when application became healthy after sync, it's zero when it isn't known,
+// e.g. agent was started after application became healthy and argocd doesn't report time of health transition
+func healthyAt(app argo.ArgoApplication, finishedAt time.Time, observed bool) time.Time {
+	if transition, ok := parseTime(app.Status.Health.LastTransitionTime); ok {
+		if transition.Before(finishedAt) {
+			// application stayed healthy during sync
+			return finishedAt
+		}
+		return transition
+	}
+	if observed {
+		return now()
+	}
+	return time.Time{}
+}
+
+// detectRollback says if application was synced to revision of older history item, than previous one,
+// or history id decreased since last update, it returns revision, that was replaced by rollback
+func detectRollback(history []argo.ArgoApplicationHistoryItem, revision string, historyId int64, previousHistoryId int64) (bool, string) {
+	var previous *argo.ArgoApplicationHistoryItem
+	for i := range history {
+		item := &history[i]
+		if item.Id < historyId && (previous == nil || item.Id > previous.Id) {
+			previous = item
+		}
+	}
+
+	if historyId >= 0 && previousHistoryId >= 0 && historyId < previousHistoryId {
+		return true, previousRevision(history, previousHistoryId)
+	}
+
+	// sync of same revision, as previous one, is redeploy, not rollback
+	if previous == nil || previous.Revision == revision {
+		return false, ""
+	}
+
+	for _, item := range history {
+		if item.Id < previous.Id && item.Revision == revision {
+			return true, previous.Revision
+		}
+	}
+	return false, ""
+}
+
+func previousRevision(history []argo.ArgoApplicationHistoryItem, historyId int64) string {
+	for _, item := range history {
+		if item.Id == historyId {
+			return item.Revision
+		}
+	}
+	return ""
+}
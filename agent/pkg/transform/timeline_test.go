@@ -0,0 +1,97 @@
+package transform
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"testing"
+	"time"
+)
+
+func timelineApplication(name string, health string, startedAt string, finishedAt string) argo.ArgoApplication {
+	var app argo.ArgoApplication
+	app.Metadata.Name = name
+	app.Metadata.Namespace = "argocd"
+	app.Status.Health.Status = health
+	app.Status.OperationState.StartedAt = startedAt
+	app.Status.OperationState.FinishedAt = finishedAt
+	return app
+}
+
+func TestPrepareTimelineDurations(t *testing.T) {
+	defer SetClock(time.Now)
+	defer ForgetTimeline("argocd", "timeline-app")
+
+	app := timelineApplication("timeline-app", "Progressing", "2021-01-01T10:00:00Z", "2021-01-01T10:00:30Z")
+	timeline := prepareTimeline(app, 1)
+
+	if timeline.SyncDuration != 30 {
+		t.Errorf("'prepareTimeline' failed, expected '%v', got '%v'", 30, timeline.SyncDuration)
+	}
+	if timeline.HealthyAt != "" {
+		t.Errorf("'prepareTimeline' failed, expected empty healthyAt, got '%v'", timeline.HealthyAt)
+	}
+
+	SetClock(func() time.Time {
+		return time.Date(2021, 1, 1, 10, 2, 0, 0, time.UTC)
+	})
+	app.Status.Health.Status = "Healthy"
+	timeline = prepareTimeline(app, 1)
+
+	if timeline.HealthyAt != "2021-01-01T10:02:00Z" || timeline.TimeToHealthy != 90 {
+		t.Errorf("'prepareTimeline' failed, expected '%v', got '%v' after '%v'", "2021-01-01T10:02:00Z", timeline.HealthyAt, timeline.TimeToHealthy)
+	}
+
+	// time, when application was seen healthy first, is kept
+	SetClock(func() time.Time {
+		return time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC)
+	})
+	timeline = prepareTimeline(app, 1)
+	if timeline.TimeToHealthy != 90 {
+		t.Errorf("'prepareTimeline' failed, expected '%v', got '%v'", 90, timeline.TimeToHealthy)
+	}
+}
+
+func TestPrepareTimelineUnknownHealthyTime(t *testing.T) {
+	defer ForgetTimeline("argocd", "healthy-app")
+
+	app := timelineApplication("healthy-app", "Healthy", "2021-01-01T10:00:00Z", "2021-01-01T10:00:30Z")
+	timeline := prepareTimeline(app, 1)
+	if timeline.HealthyAt != "" {
+		t.Errorf("'prepareTimeline' failed, expected empty healthyAt, got '%v'", timeline.HealthyAt)
+	}
+
+	app.Status.Health.LastTransitionTime = "2021-01-01T10:01:00Z"
+	timeline = prepareTimeline(app, 1)
+	if timeline.HealthyAt != "2021-01-01T10:01:00Z" || timeline.TimeToHealthy != 30 {
+		t.Errorf("'prepareTimeline' failed, expected '%v', got '%v'", "2021-01-01T10:01:00Z", timeline.HealthyAt)
+	}
+}
+
+func TestDetectRollback(t *testing.T) {
+	history := []argo.ArgoApplicationHistoryItem{
+		{Id: 1, Revision: "a"},
+		{Id: 2, Revision: "b"},
+		{Id: 3, Revision: "c"},
+		{Id: 4, Revision: "a"},
+	}
+
+	rollback, previous := detectRollback(history, "a", 4, 3)
+	if !rollback || previous != "c" {
+		t.Errorf("'detectRollback' failed, expected rollback from '%v', got '%v' '%v'", "c", rollback, previous)
+	}
+
+	rollback, _ = detectRollback(history[:3], "c", 3, 2)
+	if rollback {
+		t.Errorf("'detectRollback' failed, new revision isn't rollback")
+	}
+
+	redeploy := append(history[:3:3], argo.ArgoApplicationHistoryItem{Id: 4, Revision: "c"})
+	rollback, _ = detectRollback(redeploy, "c", 4, 3)
+	if rollback {
+		t.Errorf("'detectRollback' failed, sync of same revision isn't rollback")
+	}
+
+	rollback, previous = detectRollback(history[:2], "b", 2, 3)
+	if !rollback {
+		t.Errorf("'detectRollback' failed, expected rollback when history id decreased, got '%v'", previous)
+	}
+}
@@ -1,14 +1,20 @@
 package transform
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/diagnostics"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/dora"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/git"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/tracing"
+	"github.com/google/go-github/github"
 	"github.com/mitchellh/mapstructure"
 	"sort"
+	"time"
 )
 
 type EnvTransformer struct {
@@ -27,9 +33,8 @@ func GetEnvTransformerInstance(argoApi argo.ArgoApi) *EnvTransformer {
 	return envTransformer
 }
 
-func (envTransformer *EnvTransformer) initDeploymentsStatuses(applicationName string) map[string]string {
+func initDeploymentsStatuses(resourceTree *argo.ResourceTree) map[string]string {
 	statuses := make(map[string]string)
-	resourceTree, _ := envTransformer.argoApi.GetResourceTree(applicationName)
 	for _, node := range resourceTree.Nodes {
 		if node.Health.Status == "" {
 			statuses[node.Uid] = "Missing"
@@ -40,20 +45,55 @@ func (envTransformer *EnvTransformer) initDeploymentsStatuses(applicationName st
 	return statuses
 }
 
-func (envTransformer *EnvTransformer) prepareEnvironmentActivity(applicationName string) ([]codefresh2.EnvironmentActivity, error) {
+// workloadsFromCluster returns live state of workloads, that are managed by in-cluster application, from workload source,
+// it returns false when any workload isn't known to source or is of kind, that source doesn't watch,
+// then managed resources should be requested from argocd
+func workloadsFromCluster(destination argo.ApplicationSpecDestination, resourceTree *argo.ResourceTree) ([]argo.ManagedResourceItem, bool) {
+	source := getWorkloadSource()
+	if source == nil || !isInCluster(destination) {
+		return nil, false
+	}
 
-	resource, err := envTransformer.argoApi.GetManagedResources(applicationName)
-	if err != nil {
-		return nil, err
+	items := make([]argo.ManagedResourceItem, 0)
+	for _, node := range resourceTree.Nodes {
+		if len(node.ParentRefs) > 0 || hasNoWorkload(node.Group, node.Kind) {
+			continue
+		}
+		if !source.Serves(node.Group, node.Kind) {
+			// workload of kind, that isn't watched, like argo rollout
+			return nil, false
+		}
+		obj, ok := source.Get(node.Group, node.Kind, node.Namespace, node.Name)
+		if !ok {
+			return nil, false
+		}
+		liveState, err := json.Marshal(obj)
+		if err != nil {
+			return nil, false
+		}
+		items = append(items, argo.ManagedResourceItem{Kind: node.Kind, Name: node.Name, LiveState: string(liveState)})
 	}
+	return items, true
+}
+
+func (envTransformer *EnvTransformer) prepareEnvironmentActivity(ctx context.Context, applicationName string, applicationNamespace string, destination argo.ApplicationSpecDestination, resourceTree *argo.ResourceTree) ([]codefresh2.EnvironmentActivity, error) {
 
-	statuses := envTransformer.initDeploymentsStatuses(applicationName)
+	items, ok := workloadsFromCluster(destination, resourceTree)
+	if !ok {
+		resource, err := envTransformer.argoApi.GetManagedResources(ctx, applicationName, applicationNamespace)
+		if err != nil {
+			return nil, err
+		}
+		items = resource.Items
+	}
+
+	statuses := initDeploymentsStatuses(resourceTree)
 
 	var services = make(map[string]codefresh2.EnvironmentActivity)
 
-	for _, item := range resource.Items {
+	for _, item := range items {
 		var liveState argo.ManagedResourceState
-		err = json.Unmarshal([]byte(item.LiveState), &liveState)
+		err := json.Unmarshal([]byte(item.LiveState), &liveState)
 		if err != nil {
 			logger.GetLogger().Errorf("Failed to unmarshal \"LiveState\" to ManagedResourceState, reason %v", err)
 			continue
@@ -117,7 +157,17 @@ func filterResources(resources interface{}) []interface{} {
 	return result
 }
 
-func (envTransformer *EnvTransformer) PrepareEnvironment(envItem map[string]interface{}) (error, *codefresh2.Environment) {
+// PrepareEnvironment builds codefresh environment from argo application, ctx carries trace of update
+func (envTransformer *EnvTransformer) PrepareEnvironment(ctx context.Context, envItem map[string]interface{}) (error, *codefresh2.Environment) {
+	ctx, span := tracing.Start(ctx, "transform.PrepareEnvironment", tracing.KindInternal)
+	defer span.End()
+
+	err, env := envTransformer.prepareEnvironment(ctx, envItem)
+	span.RecordError(err)
+	return err, env
+}
+
+func (envTransformer *EnvTransformer) prepareEnvironment(ctx context.Context, envItem map[string]interface{}) (error, *codefresh2.Environment) {
 
 	var app argo.ArgoApplication
 	err := mapstructure.Decode(envItem, &app)
@@ -126,20 +176,36 @@ func (envTransformer *EnvTransformer) PrepareEnvironment(envItem map[string]inte
 	}
 
 	name := app.Metadata.Name
+	namespace := app.Metadata.Namespace
 	historyList := app.Status.History
 	revision := app.Status.OperationState.SyncResult.Revision
 	repoUrl := app.Spec.Source.RepoURL
 
-	resources, err := envTransformer.argoApi.GetResourceTreeAll(name)
+	log := logger.GetLogger().WithFields(logger.Fields{
+		logger.AppField:       name,
+		logger.NamespaceField: namespace,
+		logger.ProjectField:   app.Spec.Project,
+		logger.RevisionField:  revision,
+	})
+	log.Debug("Prepare environment")
+
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute("argocd.application", name)
+	span.SetAttribute("argocd.namespace", namespace)
+	span.SetAttribute("argocd.revision", revision)
+
+	resources, err := envTransformer.argoApi.GetResourceTreeAll(ctx, name, namespace)
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "resource-tree", err)
 	if err != nil {
 		return err, nil
 	}
 
 	// we still need send env , even if we have problem with retrieve gitops info
-	err, gitops := getGitoptsInfo(repoUrl, revision)
+	err, gitops := getGitoptsInfo(ctx, repoUrl, revision)
+	diagnostics.ReportError(diagnostics.GitSubsystem, "gitops", err)
 
 	if err != nil {
-		logger.GetLogger().Errorf("Failed to retrieve manifest repo git information , reason: %v", err)
+		log.Errorf("Failed to retrieve manifest repo git information , reason: %v", err)
 	}
 
 	err, historyId := resolveHistoryId(historyList, app.Status.OperationState.SyncResult.Revision, name)
@@ -148,7 +214,14 @@ func (envTransformer *EnvTransformer) PrepareEnvironment(envItem map[string]inte
 		return err, nil
 	}
 
-	activities, err := envTransformer.prepareEnvironmentActivity(name)
+	// tree is shared by resources of environment and statuses of activities
+	resourceTree, err := argo.DecodeResourceTree(resources)
+	if err != nil {
+		return err, nil
+	}
+
+	activities, err := envTransformer.prepareEnvironmentActivity(ctx, name, namespace, app.Spec.Destination, resourceTree)
+	diagnostics.ReportError(diagnostics.ArgoSubsystem, "activities", err)
 	if err != nil {
 		return err, nil
 	}
@@ -156,24 +229,28 @@ func (envTransformer *EnvTransformer) PrepareEnvironment(envItem map[string]inte
 	syncPolicy := codefresh2.SyncPolicy{AutoSync: &app.Spec.SyncPolicy != nil && app.Spec.SyncPolicy.Automated != nil}
 
 	env := codefresh2.Environment{
-		HealthStatus: app.Status.Health.Status,
-		SyncStatus:   app.Status.Sync.Status,
-		SyncRevision: revision,
-		Gitops:       *gitops,
-		HistoryId:    historyId,
-		Name:         name,
-		Activities:   activities,
-		Resources:    filterResources(resources),
-		RepoUrl:      repoUrl,
-		FinishedAt:   app.Status.OperationState.FinishedAt,
-		SyncPolicy:   syncPolicy,
-		Date:         app.Status.OperationState.FinishedAt,
+		HealthStatus:   app.Status.Health.Status,
+		SyncStatus:     app.Status.Sync.Status,
+		SyncRevision:   revision,
+		Gitops:         *gitops,
+		HistoryId:      historyId,
+		Name:           argo.QualifiedName(namespace, name),
+		Activities:     activities,
+		Resources:      filterResources(resources),
+		RepoUrl:        repoUrl,
+		FinishedAt:     app.Status.OperationState.FinishedAt,
+		SyncPolicy:     syncPolicy,
+		Date:           app.Status.OperationState.FinishedAt,
+		ApplicationSet: app.Metadata.ApplicationSetName(),
+		Operation:      prepareOperation(app),
+		Timeline:       prepareTimeline(app, historyId),
+		SyncWindows:    envTransformer.prepareSyncWindows(ctx, app),
 	}
 
-	err, commit := getCommitByRevision(repoUrl, revision)
+	err, commit := getCommitByRevision(ctx, repoUrl, revision)
 
 	if commit != nil {
-		logger.GetLogger().Infof("Retrieve commit message \"%s\" for repo \"%s\" ", *commit.Message, repoUrl)
+		log.Infof("Retrieve commit message \"%s\" for repo \"%s\" ", *commit.Message, repoUrl)
 		env.Commit = *commit
 	}
 
@@ -181,6 +258,73 @@ func (envTransformer *EnvTransformer) PrepareEnvironment(envItem map[string]inte
 
 }
 
+// ObserveDeployment records update of application for DORA metrics, it's called only for updates from event source,
+// so dumps and reconciliations don't count deployments
+func ObserveDeployment(envItem map[string]interface{}, env codefresh2.Environment) {
+	tracker := dora.GetTracker()
+	if tracker == nil {
+		return
+	}
+	var app argo.ArgoApplication
+	err := mapstructure.Decode(envItem, &app)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to decode argo application, reason %v", err)
+		return
+	}
+	tracker.Observe(doraObservation(app, env))
+}
+
+func doraObservation(app argo.ArgoApplication, env codefresh2.Environment) dora.Observation {
+	observation := dora.Observation{
+		Application:  env.Name,
+		Project:      app.Spec.Project,
+		HistoryId:    env.HistoryId,
+		Revision:     env.SyncRevision,
+		Phase:        app.Status.OperationState.Phase,
+		FinishedAt:   app.Status.OperationState.FinishedAt,
+		HealthStatus: env.HealthStatus,
+		Rollback:     env.Timeline.Rollback,
+		History:      app.Status.History,
+	}
+	if env.Commit.Date != nil {
+		observation.CommitDate = *env.Commit.Date
+	}
+	return observation
+}
+
+func prepareOperation(app argo.ArgoApplication) codefresh2.EnvironmentOperation {
+	operationState := app.Status.OperationState
+
+	resources := make([]codefresh2.EnvironmentResourceResult, 0, len(operationState.SyncResult.Resources))
+	for _, resource := range operationState.SyncResult.Resources {
+		resources = append(resources, codefresh2.EnvironmentResourceResult{
+			Group:     resource.Group,
+			Version:   resource.Version,
+			Kind:      resource.Kind,
+			Namespace: resource.Namespace,
+			Name:      resource.Name,
+			Status:    resource.Status,
+			Message:   resource.Message,
+			HookType:  resource.HookType,
+			HookPhase: resource.HookPhase,
+			SyncPhase: resource.SyncPhase,
+		})
+	}
+
+	return codefresh2.EnvironmentOperation{
+		Phase:      operationState.Phase,
+		Message:    operationState.Message,
+		StartedAt:  operationState.StartedAt,
+		FinishedAt: operationState.FinishedAt,
+		RetryCount: operationState.RetryCount,
+		InitiatedBy: codefresh2.OperationInitiator{
+			Username:  operationState.Operation.InitiatedBy.Username,
+			Automated: operationState.Operation.InitiatedBy.Automated,
+		},
+		Resources: resources,
+	}
+}
+
 func resolveHistoryId(historyList []argo.ArgoApplicationHistoryItem, revision string, name string) (error, int64) {
 	if historyList == nil {
 		logger.GetLogger().Errorf("can`t find history id for application %s, because history list is empty", name)
@@ -199,12 +343,15 @@ func resolveHistoryId(historyList []argo.ArgoApplicationHistoryItem, revision st
 	return fmt.Errorf("can`t find history id for application %s", name), 0
 }
 
-func getCommitByRevision(repoUrl string, revision string) (error, *codefresh2.Commit) {
+func getCommitByRevision(ctx context.Context, repoUrl string, revision string) (error, *codefresh2.Commit) {
+	ctx, span := tracing.Start(ctx, "git.GetCommitByRevision", tracing.KindInternal)
+	defer span.End()
+
 	err, gitClient := git.GetInstance(repoUrl)
 	if err != nil {
 		return err, nil
 	}
-	err, commit := gitClient.GetCommitBySha(revision)
+	err, commit := gitClient.GetCommitBySha(ctx, revision)
 	if err != nil {
 		return err, nil
 	}
@@ -213,10 +360,15 @@ func getCommitByRevision(repoUrl string, revision string) (error, *codefresh2.Co
 		Message: commit.Commit.Message,
 	}
 
+	if commitDate := commitTime(commit.Commit); commitDate != nil {
+		date := commitDate.UTC().Format(time.RFC3339)
+		result.Date = &date
+	}
+
 	if commit.Author != nil {
 		result.Avatar = commit.Author.AvatarURL
 	} else {
-		err, usr := gitClient.GetUserByUsername(*commit.Commit.Author.Name)
+		err, usr := gitClient.GetUserByUsername(ctx, *commit.Commit.Author.Name)
 		if err == nil && usr.AvatarURL != nil {
 			result.Avatar = usr.AvatarURL
 		}
@@ -225,7 +377,24 @@ func getCommitByRevision(repoUrl string, revision string) (error, *codefresh2.Co
 	return nil, result
 }
 
-func getGitoptsInfo(repoUrl string, revision string) (error, *git.Gitops) {
+// commitTime prefers time of committer, because author time isn't changed by rebase
+func commitTime(commit *github.Commit) *time.Time {
+	if commit == nil {
+		return nil
+	}
+	if commit.Committer != nil && commit.Committer.Date != nil {
+		return commit.Committer.Date
+	}
+	if commit.Author != nil {
+		return commit.Author.Date
+	}
+	return nil
+}
+
+func getGitoptsInfo(ctx context.Context, repoUrl string, revision string) (error, *git.Gitops) {
+	ctx, span := tracing.Start(ctx, "git.GetGitopsInfo", tracing.KindInternal)
+	defer span.End()
+
 	defaultGitInfo := git.Gitops{
 		Comitters: []git.User{},
 		Prs:       []git.Annotation{},
@@ -236,7 +405,7 @@ func getGitoptsInfo(repoUrl string, revision string) (error, *git.Gitops) {
 		return err, &defaultGitInfo
 	}
 
-	err, commits := gitClient.GetCommitsBySha(revision)
+	err, commits := gitClient.GetCommitsBySha(ctx, revision)
 	if err != nil {
 		return err, &defaultGitInfo
 	}
@@ -246,7 +415,7 @@ func getGitoptsInfo(repoUrl string, revision string) (error, *git.Gitops) {
 		return err, &defaultGitInfo
 	}
 
-	err, _, prs := gitClient.GetIssuesAndPrsByCommits(commits)
+	err, _, prs := gitClient.GetIssuesAndPrsByCommits(ctx, commits)
 	if err != nil {
 		return err, &defaultGitInfo
 	}
@@ -1,6 +1,7 @@
 package transform
 
 import (
+	"context"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"testing"
 )
@@ -12,7 +13,51 @@ func (m MockArgoApi) GetApplicationsWithCredentialsFromStorage() ([]argo.Applica
 	panic("implement me")
 }
 
-func (m MockArgoApi) GetResourceTree(applicationName string) (*argo.ResourceTree, error) {
+func (m MockArgoApi) GetProject(ctx context.Context, name string) (*argo.ProjectItem, error) {
+	panic("implement me")
+}
+
+func (m MockArgoApi) GetVersion() (string, error) {
+	panic("implement me")
+}
+
+func (m MockArgoApi) SyncApplication(applicationName string, applicationNamespace string) error {
+	panic("implement me")
+}
+
+func (m MockArgoApi) RefreshApplication(applicationName string, applicationNamespace string, hard bool) error {
+	panic("implement me")
+}
+
+func (m MockArgoApi) RollbackApplication(applicationName string, applicationNamespace string, historyId int64) error {
+	panic("implement me")
+}
+
+func (m MockArgoApi) TerminateOperation(applicationName string, applicationNamespace string) error {
+	panic("implement me")
+}
+
+func (m MockArgoApi) GetResourceTreeAll(ctx context.Context, applicationName string, applicationNamespace string) (interface{}, error) {
+	panic("implement me")
+}
+
+func (m MockArgoApi) GetManagedResources(ctx context.Context, applicationName string, applicationNamespace string) (*argo.ManagedResource, error) {
+	liveState := "{\"kind\":\"Service\",\"metadata\":{ \"name\":\"test-api\",\"namespace\":\"andrii\",\"uid\":\"46263671-f290-11ea-8d49-42010a8001b0\"},\"spec\":{ \"template\": { \"spec\": { \"containers\":[{\"image\":\"andriicodefresh/test:v7\",\"name\":\"test-api\"}] } }, \"clusterIP\":\"10.27.251.224\",\"ports\":[{\"port\":80,\"protocol\":\"TCP\",\"targetPort\":1700}]}}"
+
+	var resourceItems = make([]argo.ManagedResourceItem, 0)
+	resourceItems = append(resourceItems, argo.ManagedResourceItem{
+		Kind:        "Deployment",
+		TargetState: "",
+		LiveState:   liveState,
+		Name:        "Test",
+	})
+
+	return &argo.ManagedResource{
+		Items: resourceItems,
+	}, nil
+}
+
+func mockResourceTree() *argo.ResourceTree {
 	var nodes = make([]argo.Node, 0)
 	nodes = append(nodes, argo.Node{
 		Kind: "Deploy",
@@ -32,34 +77,27 @@ func (m MockArgoApi) GetResourceTree(applicationName string) (*argo.ResourceTree
 
 	return &argo.ResourceTree{
 		Nodes: nodes,
-	}, nil
+	}
 }
 
-func (m MockArgoApi) GetResourceTreeAll(applicationName string) (interface{}, error) {
-	panic("implement me")
+type mockWorkloadSource struct {
+	workloads map[string]map[string]interface{}
 }
 
-func (m MockArgoApi) GetManagedResources(applicationName string) (*argo.ManagedResource, error) {
-	liveState := "{\"kind\":\"Service\",\"metadata\":{ \"name\":\"test-api\",\"namespace\":\"andrii\",\"uid\":\"46263671-f290-11ea-8d49-42010a8001b0\"},\"spec\":{ \"template\": { \"spec\": { \"containers\":[{\"image\":\"andriicodefresh/test:v7\",\"name\":\"test-api\"}] } }, \"clusterIP\":\"10.27.251.224\",\"ports\":[{\"port\":80,\"protocol\":\"TCP\",\"targetPort\":1700}]}}"
-
-	var resourceItems = make([]argo.ManagedResourceItem, 0)
-	resourceItems = append(resourceItems, argo.ManagedResourceItem{
-		Kind:        "Deployment",
-		TargetState: "",
-		LiveState:   liveState,
-		Name:        "Test",
-	})
+func (m mockWorkloadSource) Serves(group string, kind string) bool {
+	return group == "apps" && kind == "Deployment"
+}
 
-	return &argo.ManagedResource{
-		Items: resourceItems,
-	}, nil
+func (m mockWorkloadSource) Get(group string, kind string, namespace string, name string) (map[string]interface{}, bool) {
+	workload, ok := m.workloads[namespace+"/"+name]
+	return workload, ok
 }
 
 func TestPrepareEnvironment(t *testing.T) {
 
 	envTransformer := GetEnvTransformerInstance(MockArgoApi{})
 
-	services, err := envTransformer.prepareEnvironmentActivity("test")
+	services, err := envTransformer.prepareEnvironmentActivity(context.Background(), "test", "", argo.ApplicationSpecDestination{}, mockResourceTree())
 	if err != nil {
 		t.Error(err)
 	}
@@ -69,3 +107,90 @@ func TestPrepareEnvironment(t *testing.T) {
 	}
 
 }
+
+func TestPrepareOperation(t *testing.T) {
+	var app argo.ArgoApplication
+	app.Status.OperationState.Phase = "Failed"
+	app.Status.OperationState.Message = "one or more objects failed to apply"
+	app.Status.OperationState.RetryCount = 2
+	app.Status.OperationState.Operation.InitiatedBy.Username = "admin"
+	app.Status.OperationState.SyncResult.Resources = []argo.ResourceResult{
+		{Kind: "Deployment", Name: "test-api", Status: "SyncFailed", Message: "field is immutable"},
+		{Kind: "Job", Name: "migrate", Status: "Synced", HookType: "PreSync", HookPhase: "Succeeded"},
+	}
+
+	operation := prepareOperation(app)
+
+	if operation.Phase != "Failed" || operation.Message != app.Status.OperationState.Message || operation.RetryCount != 2 {
+		t.Errorf("'prepareOperation' failed, unexpected operation state %v", operation)
+	}
+
+	if operation.InitiatedBy.Username != "admin" || operation.InitiatedBy.Automated {
+		t.Errorf("'prepareOperation' failed, unexpected initiator %v", operation.InitiatedBy)
+	}
+
+	if len(operation.Resources) != 2 || operation.Resources[1].HookPhase != "Succeeded" {
+		t.Errorf("'prepareOperation' failed, unexpected resources %v", operation.Resources)
+	}
+}
+
+func TestDecodeResourceTree(t *testing.T) {
+	nodes := []interface{}{
+		map[string]interface{}{"group": "apps", "kind": "Deployment", "namespace": "prod", "name": "api", "uid": "1", "health": map[string]interface{}{"status": "Healthy"}},
+		map[string]interface{}{"kind": "Pod", "name": "api-1", "uid": "2", "parentRefs": []interface{}{map[string]interface{}{"kind": "ReplicaSet", "name": "api-1"}}},
+	}
+
+	tree, err := argo.DecodeResourceTree(nodes)
+	if err != nil {
+		t.Fatalf("'DecodeResourceTree' failed, reason %v", err)
+	}
+
+	statuses := initDeploymentsStatuses(tree)
+	if statuses["1"] != "Healthy" || statuses["2"] != "Missing" {
+		t.Errorf("'initDeploymentsStatuses' failed, expected '%v', got '%v'", "1:Healthy 2:Missing", statuses)
+	}
+	if len(tree.Nodes[1].ParentRefs) != 1 || tree.Nodes[0].Name != "api" {
+		t.Errorf("'DecodeResourceTree' failed, unexpected nodes %v", tree.Nodes)
+	}
+}
+
+func TestWorkloadsFromCluster(t *testing.T) {
+	tree := &argo.ResourceTree{Nodes: []argo.Node{
+		{Group: "apps", Kind: "Deployment", Namespace: "prod", Name: "api", Uid: "1"},
+		{Group: "apps", Kind: "ReplicaSet", Namespace: "prod", Name: "api-1", Uid: "2", ParentRefs: []argo.ResourceRef{{Kind: "Deployment", Name: "api"}}},
+		{Kind: "Service", Namespace: "prod", Name: "api", Uid: "3"},
+	}}
+	inCluster := argo.ApplicationSpecDestination{Server: InClusterServer}
+
+	_, ok := workloadsFromCluster(inCluster, tree)
+	if ok {
+		t.Errorf("'workloadsFromCluster' failed, expected fallback to argocd without workload source")
+	}
+
+	SetWorkloadSource(mockWorkloadSource{workloads: map[string]map[string]interface{}{
+		"prod/api": {"metadata": map[string]interface{}{"uid": "1"}},
+	}})
+	defer SetWorkloadSource(nil)
+
+	items, ok := workloadsFromCluster(inCluster, tree)
+	if !ok || len(items) != 1 || items[0].Name != "api" {
+		t.Errorf("'workloadsFromCluster' failed, expected '%v', got '%v %v'", "api", items, ok)
+	}
+
+	_, ok = workloadsFromCluster(argo.ApplicationSpecDestination{Server: "https://remote"}, tree)
+	if ok {
+		t.Errorf("'workloadsFromCluster' failed, expected fallback to argocd for remote cluster")
+	}
+
+	rollout := append(tree.Nodes[:3:3], argo.Node{Group: "argoproj.io", Kind: "Rollout", Namespace: "prod", Name: "canary"})
+	_, ok = workloadsFromCluster(inCluster, &argo.ResourceTree{Nodes: rollout})
+	if ok {
+		t.Errorf("'workloadsFromCluster' failed, expected fallback to argocd for rollout, that isn't watched")
+	}
+
+	tree.Nodes = append(tree.Nodes, argo.Node{Group: "apps", Kind: "Deployment", Namespace: "prod", Name: "worker"})
+	_, ok = workloadsFromCluster(inCluster, tree)
+	if ok {
+		t.Errorf("'workloadsFromCluster' failed, expected fallback to argocd for unknown workload")
+	}
+}
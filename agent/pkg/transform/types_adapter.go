@@ -3,6 +3,7 @@ package transform
 import (
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	codefresh2 "github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"sort"
 )
 
 func AdaptArgoApplications(applications []argo.ApplicationItem) []codefresh2.AgentApplication {
@@ -21,11 +22,13 @@ func AdaptArgoApplications(applications []argo.ApplicationItem) []codefresh2.Age
 		}
 
 		newItem := codefresh2.AgentApplication{
-			Name:      item.Metadata.Name,
-			UID:       item.Metadata.UID,
-			Project:   item.Spec.Project,
-			Server:    server,
-			Namespace: namespace,
+			Name:           argo.QualifiedName(item.Metadata.Namespace, item.Metadata.Name),
+			AppNamespace:   item.Metadata.Namespace,
+			UID:            item.Metadata.UID,
+			Project:        item.Spec.Project,
+			Server:         server,
+			Namespace:      namespace,
+			ApplicationSet: item.Metadata.ApplicationSetName(),
 		}
 		result = append(result, newItem)
 	}
@@ -46,3 +49,54 @@ func AdaptArgoProjects(projects []argo.ProjectItem) []codefresh2.AgentProject {
 
 	return result
 }
+
+func AdaptArgoApplicationSets(applicationSets []argo.ArgoApplicationSet) []codefresh2.AgentApplicationSet {
+	var result = make([]codefresh2.AgentApplicationSet, 0)
+
+	for _, item := range applicationSets {
+		generatorTypes := make([]string, 0)
+		for _, generator := range item.Spec.Generators {
+			for generatorType := range generator {
+				generatorTypes = append(generatorTypes, generatorType)
+			}
+		}
+		sort.Strings(generatorTypes)
+
+		conditions := make([]codefresh2.AgentApplicationSetCondition, 0)
+		for _, condition := range item.Status.Conditions {
+			conditions = append(conditions, codefresh2.AgentApplicationSetCondition{
+				Type:               condition.Type,
+				Status:             condition.Status,
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+				LastTransitionTime: condition.LastTransitionTime,
+			})
+		}
+
+		templateSpec := item.Spec.Template.Spec
+
+		newItem := codefresh2.AgentApplicationSet{
+			Name:           item.Metadata.Name,
+			UID:            item.Metadata.UID,
+			Namespace:      item.Metadata.Namespace,
+			GeneratorTypes: generatorTypes,
+			Generators:     item.Spec.Generators,
+			Template: codefresh2.AgentApplicationSetTemplate{
+				Project:        templateSpec.Project,
+				RepoUrl:        templateSpec.Source.RepoURL,
+				Path:           templateSpec.Source.Path,
+				Chart:          templateSpec.Source.Chart,
+				TargetRevision: templateSpec.Source.TargetRevision,
+			},
+			Conditions: conditions,
+		}
+		result = append(result, newItem)
+	}
+
+	// informer store returns items in random order, keep it stable for filter
+	sort.Slice(result, func(i, j int) bool {
+		return argo.ApplicationKey(result[i].Namespace, result[i].Name) < argo.ApplicationKey(result[j].Namespace, result[j].Name)
+	})
+
+	return result
+}
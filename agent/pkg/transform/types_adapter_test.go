@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"testing"
+)
+
+func TestAdaptArgoApplicationsWithApplicationSet(t *testing.T) {
+	applications := AdaptArgoApplications([]argo.ApplicationItem{
+		{
+			Metadata: argo.ApplicationMetadata{
+				Name: "guestbook-dev",
+				OwnerReferences: []argo.OwnerReference{
+					{Kind: "ApplicationSet", Name: "guestbook"},
+				},
+			},
+		},
+		{
+			Metadata: argo.ApplicationMetadata{
+				Name: "standalone",
+			},
+		},
+	})
+
+	if applications[0].ApplicationSet != "guestbook" {
+		t.Errorf("'AdaptArgoApplications' failed, expected applicationset '%v', got '%v'", "guestbook", applications[0].ApplicationSet)
+	}
+
+	if applications[1].ApplicationSet != "" {
+		t.Errorf("'AdaptArgoApplications' failed, expected no applicationset, got '%v'", applications[1].ApplicationSet)
+	}
+}
+
+func TestAdaptArgoApplicationSets(t *testing.T) {
+	var applicationSet argo.ArgoApplicationSet
+	applicationSet.Metadata.Name = "guestbook"
+	applicationSet.Spec.Generators = []map[string]interface{}{
+		{"list": map[string]interface{}{"elements": []interface{}{}}},
+		{"clusters": map[string]interface{}{}},
+	}
+	applicationSet.Spec.Template.Spec.Source.RepoURL = "https://github.com/argoproj/argocd-example-apps.git"
+	applicationSet.Status.Conditions = []argo.ApplicationSetCondition{
+		{Type: "ErrorOccurred", Status: "False"},
+	}
+
+	applicationSets := AdaptArgoApplicationSets([]argo.ArgoApplicationSet{applicationSet})
+
+	if len(applicationSets) != 1 {
+		t.Fatalf("'AdaptArgoApplicationSets' failed, expected 1 applicationset, got %v", len(applicationSets))
+	}
+
+	result := applicationSets[0]
+	if len(result.GeneratorTypes) != 2 || result.GeneratorTypes[0] != "clusters" || result.GeneratorTypes[1] != "list" {
+		t.Errorf("'AdaptArgoApplicationSets' failed, unexpected generator types %v", result.GeneratorTypes)
+	}
+
+	if result.Template.RepoUrl != applicationSet.Spec.Template.Spec.Source.RepoURL {
+		t.Errorf("'AdaptArgoApplicationSets' failed, expected repo url '%v', got '%v'", applicationSet.Spec.Template.Spec.Source.RepoURL, result.Template.RepoUrl)
+	}
+
+	if len(result.Conditions) != 1 {
+		t.Errorf("'AdaptArgoApplicationSets' failed, expected 1 condition, got %v", len(result.Conditions))
+	}
+}
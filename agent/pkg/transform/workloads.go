@@ -0,0 +1,60 @@
+package transform
+
+import (
+	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
+	"sync"
+)
+
+// InClusterServer is destination server of applications, that are deployed to cluster of argocd
+const InClusterServer = "https://kubernetes.default.svc"
+
+// WorkloadSource returns live manifests of workloads without requests to argocd
+type WorkloadSource interface {
+	// Serves says if workloads of kind are watched by source
+	Serves(group string, kind string) bool
+	Get(group string, kind string, namespace string, name string) (map[string]interface{}, bool)
+}
+
+var (
+	workloadSource     WorkloadSource
+	workloadSourceLock sync.RWMutex
+)
+
+// SetWorkloadSource makes transformer take workloads of in-cluster applications from source instead of argocd managed resources
+func SetWorkloadSource(source WorkloadSource) {
+	workloadSourceLock.Lock()
+	defer workloadSourceLock.Unlock()
+	workloadSource = source
+}
+
+func getWorkloadSource() WorkloadSource {
+	workloadSourceLock.RLock()
+	defer workloadSourceLock.RUnlock()
+	return workloadSource
+}
+
+// configGroups are api groups of resources, that have no pod template, so they never become activities.
+// Resources of other groups, that source doesn't serve, like argo rollouts, are taken from argocd managed resources
+var configGroups = map[string]bool{
+	"":                             true,
+	"rbac.authorization.k8s.io":    true,
+	"networking.k8s.io":            true,
+	"policy":                       true,
+	"autoscaling":                  true,
+	"apiextensions.k8s.io":         true,
+	"admissionregistration.k8s.io": true,
+	"storage.k8s.io":               true,
+	"scheduling.k8s.io":            true,
+	"cert-manager.io":              true,
+	"monitoring.coreos.com":        true,
+}
+
+// hasNoWorkload says if resource of group and kind can't have pod template
+func hasNoWorkload(group string, kind string) bool {
+	// replication controller is only workload of core group
+	return configGroups[group] && !(group == "" && kind == "ReplicationController")
+}
+
+func isInCluster(destination argo.ApplicationSpecDestination) bool {
+	return destination.Server == InClusterServer || (destination.Server == "" && destination.Name == "in-cluster")
+}
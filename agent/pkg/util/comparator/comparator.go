@@ -4,6 +4,7 @@ import (
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
 	"github.com/ulule/deepcopier"
 	"reflect"
+	"sort"
 )
 
 type Comparator interface {
@@ -58,3 +59,24 @@ func (comparator EnvComparator) Compare(obj1 interface{}, obj2 interface{}) bool
 
 	return reflect.DeepEqual(newEnv1, newEnv2) && sameServices
 }
+
+// Normalize drops parts of environment, that are ignored by Compare, so environments can be compared by hash
+func (comparator EnvComparator) Normalize(obj interface{}) interface{} {
+	env, ok := obj.(*codefresh.Environment)
+	if !ok || env == nil {
+		return obj
+	}
+
+	// shallow copy is enough, nested values aren't modified
+	newEnv := *env
+	newEnv.Resources = nil
+
+	activities := make([]codefresh.EnvironmentActivity, len(env.Activities))
+	copy(activities, env.Activities)
+	sort.Slice(activities, func(i, j int) bool {
+		return activities[i].Name < activities[j].Name
+	})
+	newEnv.Activities = activities
+
+	return &newEnv
+}
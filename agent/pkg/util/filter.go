@@ -2,37 +2,50 @@ package util
 
 import (
 	"github.com/codefresh-io/argocd-listener/agent/pkg/logger"
-	"reflect"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/state"
 )
 
-var previousState = make(map[string]interface{})
-
-func ProcessDataWithFilter(itemType string, key *string, data interface{}, comparator func(oldItem interface{}, newItem interface{}) bool, callback func() error) error {
-
+// StateKey returns key of item in state, that is used by ProcessDataWithFilter
+func StateKey(itemType string, key *string) string {
 	stateKey := itemType
 
 	if key != nil {
 		stateKey += "." + *key
 	}
 
-	oldItem := previousState[stateKey]
+	return stateKey
+}
+
+// ProcessDataWithFilter calls callback only if data was changed since last successful call,
+// normalize allows to drop parts of data, that shouldn't trigger callback
+func ProcessDataWithFilter(itemType string, key *string, data interface{}, normalize func(item interface{}) interface{}, callback func() error) error {
+
+	stateKey := StateKey(itemType, key)
 
-	if comparator == nil {
-		// default comparator
-		comparator = reflect.DeepEqual
+	if normalize == nil {
+		normalize = func(item interface{}) interface{} {
+			return item
+		}
 	}
 
-	if comparator(oldItem, data) {
+	hash, err := state.Hash(normalize(data))
+	if err != nil {
+		return err
+	}
+
+	store := state.GetInstance()
+
+	if oldHash, ok := store.Get(stateKey); ok && oldHash == hash {
 		logger.GetLogger().Infof("Filter item with key \"%s\" before send to codefresh", stateKey)
 		return nil
 	}
 
-	err := callback()
+	err = callback()
 
 	if err != nil {
 		return err
 	}
-	previousState[stateKey] = data
+	store.Set(stateKey, hash)
 
 	return nil
 }
@@ -4,8 +4,10 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/apierrors"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/store"
 	"github.com/codefresh-io/argocd-listener/installer/pkg/holder"
 	"github.com/codefresh-io/argocd-listener/installer/pkg/install"
 	"github.com/codefresh-io/argocd-listener/installer/pkg/install/acceptance_tests"
@@ -21,6 +23,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"net/http"
 	"os"
 	"os/user"
 	"path"
@@ -42,15 +45,10 @@ func ensureIntegration() error {
 		return nil
 	}
 
-	codefreshErr, ok := err.(*codefresh.CodefreshError)
-	if !ok {
+	if !integrationExists(err) {
 		return err
 	}
 
-	if codefreshErr.Status != 409 {
-		return codefreshErr
-	}
-
 	needUpdate := installCmdOptions.Argo.Update
 	if !needUpdate {
 		err, needUpdate = prompt.Confirm("You already have integration with this name, do you want to update it")
@@ -72,6 +70,12 @@ func ensureIntegration() error {
 	return nil
 }
 
+// integrationExists says if codefresh rejected creation of integration, because integration with same name exists
+func integrationExists(err error) bool {
+	var apiErr *apierrors.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
 func sendArgoAgentInstalledEvent(status string, reason string) {
 	props := make(map[string]string)
 	props["status"] = status
@@ -154,7 +158,12 @@ var installCmd = &cobra.Command{
 		// Need check if we want support not in cluster mode with Product owner
 		installCmdOptions.Kube.InCluster = true
 
-		questionnaire.AskAboutSyncOptions(&installCmdOptions)
+		store.SetArgoNamespace(kubeOptions.Namespace)
+		err = questionnaire.AskAboutSyncOptions(&installCmdOptions)
+		if err != nil {
+			sendArgoAgentInstalledEvent(FAILED, err.Error())
+			return err
+		}
 
 		installCmdOptions.Codefresh.Token = base64.StdEncoding.EncodeToString([]byte(installCmdOptions.Codefresh.Token))
 		installCmdOptions.Argo.Token = base64.StdEncoding.EncodeToString([]byte(installCmdOptions.Argo.Token))
@@ -205,6 +214,7 @@ func init() {
 	flags.StringVar(&installCmdOptions.Codefresh.Integration, "codefresh-integration", "", "Argocd integration in Codefresh")
 	flags.StringVar(&installCmdOptions.Codefresh.SyncMode, "sync-mode", "", "")
 	flags.StringArrayVar(&installCmdOptions.Codefresh.ApplicationsForSyncArr, "sync-apps", make([]string, 0), "")
+	flags.StringVar(&installCmdOptions.Codefresh.SyncRules, "sync-rules", "", "Json list of rules for select applications for sync, example: [{\"labels\":\"team=a\",\"projects\":[\"default\"]}]")
 
 	flags.StringVar(&installCmdOptions.Kube.ManifestPath, "output", "", "Path to k8s manifest output file, example: /home/user/out.yaml")
 	flags.StringVar(&installCmdOptions.Kube.Namespace, "kube-namespace", viper.GetString("kube-namespace"), "Name of the namespace on which Argo agent should be installed [$KUBE_NAMESPACE]")
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIntegrationExists(t *testing.T) {
+	status := http.StatusConflict
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"message":"Integration already exists"}`))
+	}))
+	defer server.Close()
+
+	api := &codefresh.Api{Host: server.URL, Token: "token"}
+
+	err := api.CreateIntegration("argocd", "https://argocd", "admin", "password", "", "v2.0.0")
+	if !integrationExists(err) {
+		t.Errorf("'integrationExists' failed, expected existing integration for error '%v'", err)
+	}
+
+	status = http.StatusBadRequest
+	err = api.CreateIntegration("argocd", "https://argocd", "admin", "password", "", "v2.0.0")
+	if err == nil || integrationExists(err) {
+		t.Errorf("'integrationExists' failed, expected other error, got '%v'", err)
+	}
+
+	if integrationExists(errors.New("network is unreachable")) {
+		t.Errorf("'integrationExists' failed, expected false for error without status")
+	}
+}
@@ -3,15 +3,17 @@ package questionnaire
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/argo"
 	"github.com/codefresh-io/argocd-listener/agent/pkg/codefresh"
+	"github.com/codefresh-io/argocd-listener/agent/pkg/handler"
 	"github.com/codefresh-io/argocd-listener/installer/pkg/install"
 	"github.com/codefresh-io/argocd-listener/installer/pkg/prompt"
 	"github.com/codefresh-io/argocd-listener/installer/pkg/util"
 	"github.com/elliotchance/orderedmap"
 )
 
-func AskAboutSyncOptions(installOptions *install.InstallCmdOptions) {
+func AskAboutSyncOptions(installOptions *install.InstallCmdOptions) error {
 	var syncMode interface{}
 
 	if installOptions.Codefresh.SyncMode != "" {
@@ -52,7 +54,7 @@ func AskAboutSyncOptions(installOptions *install.InstallCmdOptions) {
 			applicationNames := make([]string, 0)
 
 			for _, prj := range applications {
-				applicationNames = append(applicationNames, prj.Metadata.Name)
+				applicationNames = append(applicationNames, argo.QualifiedName(prj.Metadata.Namespace, prj.Metadata.Name))
 			}
 
 			_, applicationsForSync = prompt.Multiselect(applicationNames, "Please select application for sync")
@@ -61,7 +63,19 @@ func AskAboutSyncOptions(installOptions *install.InstallCmdOptions) {
 		applicationsAsJson, _ := json.Marshal(applicationsForSync)
 
 		installOptions.Codefresh.ApplicationsForSync = base64.StdEncoding.EncodeToString(applicationsAsJson)
+
+		if installOptions.Codefresh.SyncRules != "" {
+			// agent would fail on start with such rules, so they are checked before anything is installed
+			if _, err := handler.ParseSyncRules([]byte(installOptions.Codefresh.SyncRules)); err != nil {
+				return fmt.Errorf("invalid --sync-rules \"%s\", reason %v", installOptions.Codefresh.SyncRules, err)
+			}
+			installOptions.Codefresh.SyncRules = base64.StdEncoding.EncodeToString([]byte(installOptions.Codefresh.SyncRules))
+		}
+	} else {
+		installOptions.Codefresh.SyncRules = ""
 	}
 
 	installOptions.Codefresh.SyncMode = syncMode.(string)
+
+	return nil
 }
@@ -10,6 +10,7 @@ type InstallCmdOptions struct {
 		SyncMode               string
 		ApplicationsForSync    string
 		ApplicationsForSyncArr []string
+		SyncRules              string
 	}
 	Git struct {
 		Integration string
@@ -25,10 +25,19 @@ rules:
     resources:
       - applications
       - appprojects
+      - applicationsets
     verbs:
       - get
       - list
       - watch
+  - apiGroups:
+      - ""
+    resources:
+      - configmaps
+    verbs:
+      - get
+      - create
+      - update
 `
 
 	templatesMap["3_cluster_role_binding.yaml"] = `apiVersion: rbac.authorization.k8s.io/v1
@@ -101,6 +110,8 @@ spec:
           value: "{{ .Agent.Version }}"
         - name: ARGO_HOST
           value: {{ .Argo.Host }}
+        - name: ARGO_NAMESPACE
+          value: {{ .Namespace }}
         - name: ARGO_USERNAME
           value: {{ .Argo.Username }}
         - name: ARGO_PASSWORD
@@ -133,6 +144,8 @@ spec:
           value: "{{ .Codefresh.SyncMode }}"
         - name: APPLICATIONS_FOR_SYNC
           value: "{{ .Codefresh.ApplicationsForSync }}"
+        - name: SYNC_RULES
+          value: "{{ .Codefresh.SyncRules }}"
         - name: CODEFRESH_INTEGRATION
           value: {{ .Codefresh.Integration }}
         - name: GIT_PASSWORD